@@ -0,0 +1,123 @@
+// Command noncord runs the api, the ws gateway and the relayer in one
+// process, on the memory event bus by default, so a self-hosted install or a
+// demo needs nothing but PostgreSQL.
+//
+//	noncord [-migrate]
+//
+// -migrate, or db.migrate in the configuration, applies the embedded
+// migrations before serving. The api and the ws listen on their usual ports,
+// metrics of both are served on api.metrics_port, the relayer runs inside the
+// api and delivered events are purged as by the standalone relayer.
+package main
+
+import (
+	"backend/internal/application/ports"
+	"backend/internal/bootstrap"
+	"backend/internal/domain/events"
+	"backend/internal/infra/archive"
+	"backend/internal/infra/bus"
+	"backend/internal/infra/config"
+	"backend/internal/infra/db/postgres"
+	"backend/internal/infra/telemetry"
+	"backend/internal/processes/retention"
+	"context"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+func main() {
+	migrate := flag.Bool("migrate", false, "apply the embedded migrations before serving")
+	flag.Parse()
+
+	cfg, err := config.Load(config.SERVICE_ALL_IN_ONE)
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	// Every event version still in flight must upcast to the one handlers expect
+	if err := events.Validate(); err != nil {
+		log.Fatalf("Invalid event registry: %v", err)
+	}
+
+	shutdownTracing, err := telemetry.Setup(ctx, "noncord")
+	if err != nil {
+		log.Fatalf("Cannot set up tracing: %v", err)
+	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdownTracing(flushCtx)
+	}()
+
+	pgPool, err := postgres.NewPool(ctx, cfg.DB.URI)
+	if err != nil {
+		log.Fatalf("Cannot connect to db: %v", err)
+	}
+	defer pgPool.Close()
+
+	if *migrate || cfg.DB.Migrate {
+		if err = postgres.Migrate(ctx, pgPool); err != nil {
+			log.Fatalf("Cannot migrate the database: %v", err)
+		}
+	}
+
+	eventBus, err := bus.Open(cfg.EventBus, pgPool)
+	if err != nil {
+		log.Fatalf("Cannot open the event bus: %v", err)
+	}
+	defer eventBus.Close()
+
+	if cfg.Outbox.Retention.RetainFor > 0 {
+		var eventArchive ports.EventArchive
+		if cfg.Outbox.ArchiveDir != "" {
+			eventArchive = archive.NewNDJSONArchive(cfg.Outbox.ArchiveDir)
+		}
+		store := postgres.NewPGOutboxRetention(pgPool, cfg.EventBus.Backend == bus.BACKEND_POSTGRES)
+		go retention.New(store, eventArchive, cfg.Outbox.Retention).Run(ctx)
+	}
+
+	api, err := bootstrap.API(ctx, cfg, pgPool, eventBus)
+	if err != nil {
+		log.Fatal(err)
+	}
+	gateway, err := bootstrap.WS(ctx, cfg, pgPool, eventBus)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Both share the registry, the api's metrics port serves it once
+	gateway.MetricsPort = ""
+
+	services := []*bootstrap.Service{api, gateway}
+	var wg sync.WaitGroup
+	for _, svc := range services {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			log.Printf("%s listening on port %v", svc.Name, svc.Port)
+			if err := svc.Serve(ctx); err != nil {
+				slog.Error("Server stopped", "service", svc.Name, "err", err)
+				// One without the other is of no use, stop both
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	slog.Info("Shutting down")
+	for _, svc := range services {
+		if err = svc.Close(); err != nil {
+			slog.Error("Cannot close the event subscriber", "service", svc.Name, "err", err)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Command noncordctl is the operator's tool. It goes through the application
+// services and the unit of work like the api does, so domain rules hold and
+// every change is published as events.
+//
+//	noncordctl migrate up | status
+//	noncordctl user create -username name -email addr [-password p] [-flag name] [-verified]
+//	noncordctl user disable | enable | verify | unverify | revoke-sessions <user>
+//	noncordctl user flag <user> <flag>
+//	noncordctl server transfer <server id> <user>
+//	noncordctl server delete -force <server id>
+//	noncordctl outbox list [-limit 50] [-offset 0] | show <event id>... | requeue <event id>...
+//	noncordctl perms <user> <channel id>
+//
+// A <user> is a user id or a username.
+package main
+
+import (
+	"backend/internal/application/interfaces"
+	"backend/internal/application/services"
+	"backend/internal/domain/repositories"
+	"backend/internal/infra/config"
+	"backend/internal/infra/db/postgres"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage: noncordctl <command> [arguments]
+
+  migrate up | status
+  user create -username name -email addr [-password p] [-flag name] [-verified]
+  user disable | enable | verify | unverify | revoke-sessions <user>
+  user flag <user> <flag>
+  server transfer <server id> <user>
+  server delete -force <server id>
+  outbox list [-limit n] [-offset n] | show <event id>... | requeue <event id>...
+  perms <user> <channel id>
+
+A <user> is a user id or a username, flags are `+strings.Join(flagNames(), ", ")+`.`)
+	os.Exit(2)
+}
+
+// app holds what the commands run on, wired as in the api.
+type app struct {
+	pool *pgxpool.Pool
+
+	users       interfaces.UserService
+	userQueries interfaces.UserQueries
+	servers     interfaces.ServerService
+	permissions interfaces.PermissionQueries
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	cfg, err := config.Load(config.SERVICE_TOOL)
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	pgPool, err := postgres.NewPool(ctx, cfg.DB.URI)
+	if err != nil {
+		log.Fatalf("Cannot connect to db: %v", err)
+	}
+	defer pgPool.Close()
+
+	uow := postgres.NewBaseUoW(pgPool)
+	a := &app{
+		pool:        pgPool,
+		users:       services.NewUserService(postgres.NewScopedUoW(uow, func(rb repositories.RepoBundle) services.UserRepos { return rb })),
+		userQueries: postgres.NewPGUserQueries(pgPool),
+		servers:     services.NewServerService(postgres.NewScopedUoW(uow, func(rb repositories.RepoBundle) services.ServerRepos { return rb })),
+		permissions: postgres.NewPGPermissionQueries(pgPool),
+	}
+
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "migrate":
+		err = a.migrate(ctx, args)
+	case "user":
+		err = a.user(ctx, args)
+	case "server":
+		err = a.server(ctx, args)
+	case "outbox":
+		err = a.outbox(ctx, args)
+	case "perms":
+		err = a.perms(ctx, args)
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func parseIds(kind string, args []string) ([]uuid.UUID, error) {
+	if len(args) == 0 {
+		usage()
+	}
+	ids := make([]uuid.UUID, 0, len(args))
+	for _, arg := range args {
+		id, err := uuid.Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s id %q: %w", kind, arg, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// resolveUser takes a user id or a username.
+func (a *app) resolveUser(ctx context.Context, arg string) (uuid.UUID, error) {
+	if id, err := uuid.Parse(arg); err == nil {
+		return id, nil
+	}
+	user, err := a.userQueries.GetByUsername(ctx, strings.ToLower(arg))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("user %q: %w", arg, err)
+	}
+	return user.Id, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"backend/internal/infra/db/postgres"
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+func (a *app) migrate(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		usage()
+	}
+
+	switch args[0] {
+	case "up":
+		return postgres.Migrate(ctx, a.pool)
+	case "status":
+		statuses, err := postgres.MigrationStatus(ctx, a.pool)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tSTATE\tAPPLIED AT\tFILE")
+		for _, st := range statuses {
+			appliedAt := "-"
+			if !st.AppliedAt.IsZero() {
+				appliedAt = st.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", st.Source.Version, st.State, appliedAt, st.Source.Path)
+		}
+		return w.Flush()
+	default:
+		usage()
+	}
+	return nil
+}
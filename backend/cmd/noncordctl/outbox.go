@@ -0,0 +1,92 @@
+package main
+
+import (
+	"backend/internal/application/ports"
+	"backend/internal/infra/db/postgres"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// outbox inspects and requeues dead lettered outbox events.
+func (a *app) outbox(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		usage()
+	}
+	reader := postgres.NewPGOutboxReader(a.pool)
+
+	switch args[0] {
+	case "list":
+		return listOutbox(ctx, reader, args[1:])
+	case "show":
+		return showOutbox(ctx, reader, args[1:])
+	case "requeue":
+		return requeueOutbox(ctx, reader, args[1:])
+	default:
+		usage()
+	}
+	return nil
+}
+
+func listOutbox(ctx context.Context, reader ports.OutboxReader, args []string) error {
+	fs := flag.NewFlagSet("outbox list", flag.ExitOnError)
+	limit := fs.Int("limit", 50, "maximum number of events to list")
+	offset := fs.Int("offset", 0, "number of events to skip")
+	fs.Parse(args)
+
+	records, err := reader.ListDeadLettered(ctx, int32(*limit), int32(*offset))
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tEVENT TYPE\tAGGREGATE\tOCCURRED AT\tATTEMPTS\tLAST ERROR")
+	for _, rec := range records {
+		fmt.Fprintf(w, "%s\t%s\t%s/%s\t%s\t%d\t%s\n",
+			rec.ID, rec.EventType, rec.AggregateName, rec.AggregateID,
+			rec.OccurredAt.Format(time.RFC3339), rec.Attempts, rec.LastError)
+	}
+	return w.Flush()
+}
+
+func showOutbox(ctx context.Context, reader ports.OutboxReader, args []string) error {
+	ids, err := parseIds("event", args)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	for _, id := range ids {
+		rec, err := reader.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err = enc.Encode(struct {
+			ports.OutboxRecord
+			Payload json.RawMessage
+		}{rec, rec.Payload}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func requeueOutbox(ctx context.Context, reader ports.OutboxReader, args []string) error {
+	ids, err := parseIds("event", args)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err = reader.Requeue(ctx, id); err != nil {
+			return fmt.Errorf("requeue %s: %w", id, err)
+		}
+		fmt.Println("requeued", id)
+	}
+	return nil
+}
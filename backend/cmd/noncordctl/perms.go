@@ -0,0 +1,33 @@
+package main
+
+import (
+	"backend/internal/application/query"
+	"context"
+	"fmt"
+	"strings"
+)
+
+func (a *app) perms(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		usage()
+	}
+	userId, err := a.resolveUser(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	ids, err := parseIds("channel", args[1:])
+	if err != nil {
+		return err
+	}
+
+	res, err := a.permissions.GetChannelPermissions(ctx, query.GetChannelPermissions{UserId: userId, ChannelId: ids[0]})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("server:      %s\n", res.ServerId)
+	fmt.Printf("owner:       %t\n", res.Owner)
+	fmt.Printf("permissions: %#x\n", uint64(res.Permissions))
+	fmt.Printf("             %s\n", strings.Join(res.Permissions.ToFlagArray(), ", "))
+	return nil
+}
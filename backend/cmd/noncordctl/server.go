@@ -0,0 +1,64 @@
+package main
+
+import (
+	"backend/internal/application/command"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+)
+
+// errNotForced refuses a destructive command the operator did not confirm.
+var errNotForced = errors.New("refusing without -force")
+
+// Server commands are forced, operators act on servers they do not own.
+func (a *app) server(ctx context.Context, args []string) error {
+	if len(args) < 2 {
+		usage()
+	}
+
+	switch args[0] {
+	case "transfer":
+		if len(args) != 3 {
+			usage()
+		}
+		ids, err := parseIds("server", args[1:2])
+		if err != nil {
+			return err
+		}
+		newOwner, err := a.resolveUser(ctx, args[2])
+		if err != nil {
+			return err
+		}
+		err = a.servers.TransferOwnership(ctx, command.TransferServerCommand{
+			ServerId: ids[0],
+			NewOwner: newOwner,
+			Force:    true,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Println("transferred", ids[0], "to", newOwner)
+	case "delete":
+		fs := flag.NewFlagSet("server delete", flag.ExitOnError)
+		force := fs.Bool("force", false, "confirm the deletion, members lose the server and its channels")
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			usage()
+		}
+		ids, err := parseIds("server", fs.Args())
+		if err != nil {
+			return err
+		}
+		if !*force {
+			return fmt.Errorf("delete server %s: %w", ids[0], errNotForced)
+		}
+		if err = a.servers.Delete(ctx, command.DeleteServerCommand{ServerId: ids[0], Force: true}); err != nil {
+			return err
+		}
+		fmt.Println("deleted", ids[0])
+	default:
+		usage()
+	}
+	return nil
+}
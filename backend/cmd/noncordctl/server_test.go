@@ -0,0 +1,74 @@
+package main
+
+import (
+	"backend/internal/application/command"
+	"backend/internal/application/interfaces"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// fakeServers records the commands noncordctl runs
+type fakeServers struct {
+	interfaces.ServerService
+	deleted     []command.DeleteServerCommand
+	transferred []command.TransferServerCommand
+}
+
+func (s *fakeServers) Delete(ctx context.Context, cmd command.DeleteServerCommand) error {
+	s.deleted = append(s.deleted, cmd)
+	return nil
+}
+
+func (s *fakeServers) TransferOwnership(ctx context.Context, cmd command.TransferServerCommand) error {
+	s.transferred = append(s.transferred, cmd)
+	return nil
+}
+
+func TestServerCommand(t *testing.T) {
+	serverId, userId := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name            string
+		args            []string
+		wantErr         error
+		wantDeleted     []command.DeleteServerCommand
+		wantTransferred []command.TransferServerCommand
+	}{
+		{
+			name:    "delete without -force",
+			args:    []string{"delete", serverId.String()},
+			wantErr: errNotForced,
+		},
+		{
+			name:        "delete with -force",
+			args:        []string{"delete", "-force", serverId.String()},
+			wantDeleted: []command.DeleteServerCommand{{ServerId: serverId, Force: true}},
+		},
+		{
+			name:            "transfer",
+			args:            []string{"transfer", serverId.String(), userId.String()},
+			wantTransferred: []command.TransferServerCommand{{ServerId: serverId, NewOwner: userId, Force: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			servers := &fakeServers{}
+			a := &app{servers: servers}
+
+			err := a.server(context.Background(), tt.args)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if len(servers.deleted) != len(tt.wantDeleted) || (len(tt.wantDeleted) > 0 && servers.deleted[0] != tt.wantDeleted[0]) {
+				t.Errorf("deleted %+v, want %+v", servers.deleted, tt.wantDeleted)
+			}
+			if len(servers.transferred) != len(tt.wantTransferred) || (len(tt.wantTransferred) > 0 && servers.transferred[0] != tt.wantTransferred[0]) {
+				t.Errorf("transferred %+v, want %+v", servers.transferred, tt.wantTransferred)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"backend/internal/application/command"
+	"backend/internal/application/common"
+	"backend/internal/domain/entities"
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var userFlags = map[string]entities.UserFlags{
+	"user":      entities.UserFlagUser,
+	"bot":       entities.UserFlagBot,
+	"verified":  entities.UserFlagVerified,
+	"moderator": entities.UserFlagModerator,
+	"staff":     entities.UserFlagStaff,
+	"qa":        entities.UserFlagQA,
+	"dev":       entities.UserFlagDev,
+}
+
+// flagNames lists the flags in their numeric order.
+func flagNames() []string {
+	names := make([]string, len(userFlags))
+	for name, f := range userFlags {
+		names[f] = name
+	}
+	return names
+}
+
+// parseFlag takes a flag name or its number.
+func parseFlag(arg string) (entities.UserFlags, error) {
+	if f, ok := userFlags[strings.ToLower(arg)]; ok {
+		return f, nil
+	}
+	n, err := strconv.ParseUint(arg, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("unknown user flag %q, expected one of %s", arg, strings.Join(flagNames(), ", "))
+	}
+	return entities.UserFlags(n), nil
+}
+
+func (a *app) user(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		usage()
+	}
+	if args[0] == "create" {
+		return a.createUser(ctx, args[1:])
+	}
+	if len(args) < 2 {
+		usage()
+	}
+
+	userId, err := a.resolveUser(ctx, args[1])
+	if err != nil {
+		return err
+	}
+	yes, no := true, false
+	cmd := command.UpdateUserCommand{UserId: userId}
+
+	switch args[0] {
+	case "disable":
+		cmd.Disabled = &yes
+	case "enable":
+		cmd.Disabled = &no
+	case "verify":
+		cmd.Verified = &yes
+	case "unverify":
+		cmd.Verified = &no
+	case "flag":
+		if len(args) != 3 {
+			usage()
+		}
+		flags, err := parseFlag(args[2])
+		if err != nil {
+			return err
+		}
+		cmd.Flags = &flags
+	case "revoke-sessions":
+		res, err := a.users.RevokeSessions(ctx, command.RevokeSessionsCommand{UserId: userId})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("revoked %d sessions of %s\n", res.Revoked, userId)
+		return nil
+	default:
+		usage()
+	}
+
+	res, err := a.users.Update(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	printUser(res.Result)
+	return nil
+}
+
+func (a *app) createUser(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("user create", flag.ExitOnError)
+	username := fs.String("username", "", "username, also the display name")
+	email := fs.String("email", "", "email address")
+	password := fs.String("password", "", "password, read from stdin when empty")
+	userFlag := fs.String("flag", "user", "user flag")
+	verified := fs.Bool("verified", false, "create the user verified")
+	fs.Parse(args)
+	if *username == "" || *email == "" {
+		usage()
+	}
+
+	flags, err := parseFlag(*userFlag)
+	if err != nil {
+		return err
+	}
+	if *password == "" {
+		fmt.Fprint(os.Stderr, "password: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("cannot read the password: %w", err)
+		}
+		*password = strings.TrimRight(line, "\r\n")
+	}
+
+	res, err := a.users.Create(ctx, command.CreateUserCommand{
+		Username: *username,
+		Email:    *email,
+		Password: *password,
+		Flags:    flags,
+		Verified: *verified,
+	})
+	if err != nil {
+		return err
+	}
+	printUser(res.Result)
+	return nil
+}
+
+func printUser(u *common.UserResult) {
+	name := strconv.Itoa(int(u.Flags))
+	if int(u.Flags) < len(userFlags) {
+		name = flagNames()[u.Flags]
+	}
+	fmt.Printf("%s %s <%s> flag=%s disabled=%t verified=%t\n", u.Id, u.Username, u.Email, name, u.Disabled, u.Verified)
+}
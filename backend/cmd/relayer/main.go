@@ -1,44 +1,192 @@
+// Command relayer relays the outbox to the event bus and, when retention is
+// enabled, purges the events it delivered.
+//
+//	relayer                                     relay, purging in the background when enabled
+//	relayer purge -older-than 168h [-archive-dir dir]
+//	relayer import [-redeliver] <archive file>...
+//
+// Settings are loaded by the config package: outbox.retention.retain_for sets
+// how long delivered events are kept (default 0, keeping them forever, so
+// purging is opt-in) and outbox.archive_dir, when set, where purged events are
+// archived. Metrics and
+// the /healthz and /readyz probes are served on relayer.metrics_port (default
+// 9102).
 package main
 
 import (
+	"backend/internal/application/ports"
+	"backend/internal/infra/archive"
+	"backend/internal/infra/bus"
+	"backend/internal/infra/config"
 	"backend/internal/infra/db/postgres"
-	rabbitmq "backend/internal/infra/rabbitMQ"
+	"backend/internal/infra/telemetry"
+	"backend/internal/interface/health"
+	"backend/internal/interface/rest"
 	"backend/internal/processes/relayer"
+	"backend/internal/processes/retention"
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/rabbitmq/amqp091-go"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: relayer [purge [-older-than d] [-archive-dir dir] | import [-redeliver] <archive file>...]")
+	os.Exit(2)
+}
+
 func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
-	pgxConn, err := pgxpool.New(ctx, os.Getenv("DB_URI"))
+	// purge and import only need the database
+	service := config.SERVICE_RELAYER
+	if len(os.Args) > 1 {
+		service = config.SERVICE_TOOL
+	}
+	cfg, err := config.Load(service)
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	shutdownTracing, err := telemetry.Setup(ctx, "relayer")
+	if err != nil {
+		log.Fatalf("Cannot set up tracing: %v", err)
+	}
+	defer func() {
+		// Flush the spans still buffered, the signal context is already done
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdownTracing(flushCtx)
+	}()
+
+	pgxConn, err := postgres.NewPool(ctx, cfg.DB.URI)
 	if err != nil {
 		log.Fatalf("Cannot connect to db: %v", err)
 	}
+	defer pgxConn.Close()
+
+	retentionConfig := cfg.Outbox.Retention
+	store := postgres.NewPGOutboxRetention(pgxConn, cfg.EventBus.Backend == bus.BACKEND_POSTGRES)
 
-	amqpConn, err := amqp091.Dial(os.Getenv("AMQP_URI"))
+	if len(os.Args) > 1 {
+		args := os.Args[2:]
+		switch os.Args[1] {
+		case "purge":
+			err = purge(ctx, store, retentionConfig, cfg.Outbox.ArchiveDir, args)
+		case "import":
+			err = importArchives(ctx, store, args)
+		default:
+			usage()
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	prometheus.MustRegister(postgres.NewOutboxCollector(pgxConn))
+	checker := health.New().Add("postgres", pgxConn.Ping)
+
+	if retentionConfig.RetainFor > 0 {
+		purger := retention.New(store, newArchive(cfg.Outbox.ArchiveDir), retentionConfig)
+		go purger.Run(ctx)
+	}
+
+	if cfg.EventBus.Backend == bus.BACKEND_POSTGRES {
+		slog.Info("Nothing to relay, subscribers of the postgres event bus read the outbox directly")
+		if retentionConfig.RetainFor > 0 {
+			serveHTTP(ctx, cfg.Relayer.MetricsPort, checker)
+		}
+		return
+	}
+	eventBus, err := bus.Open(cfg.EventBus, pgxConn)
 	if err != nil {
-		log.Fatalf("Cannot connect to rabbitMQ: %v", err)
+		log.Fatalf("Cannot connect to the event bus: %v", err)
 	}
-	mq := rabbitmq.NewRMQEventPublisher(amqpConn)
 	outboxReader := postgres.NewPGOutboxReader(pgxConn)
-	relayerConfig := relayer.Config{
-		BatchSize:    100,
-		StaleAfter:   time.Minute,
-		PollInterval: 100 * time.Millisecond,
-	}
-	relayer := relayer.New(outboxReader, mq, relayerConfig)
+	shardLeaser := postgres.NewPGShardLeaser(pgxConn)
+	hostname, _ := os.Hostname()
+	relayerConfig := cfg.Relayer.Config
+	relayerConfig.InstanceId = hostname + "." + uuid.NewString()
+	relayer := relayer.New(outboxReader, eventBus.Publisher(), shardLeaser, postgres.NewPGOutboxListener(pgxConn), relayerConfig)
+
+	checker.
+		Add("event_bus", eventBus.Ping).
+		Add("relayer", health.Recent(relayer.LastClaim, cfg.Relayer.MaxClaimAge))
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		serveHTTP(ctx, cfg.Relayer.MetricsPort, checker)
+	}()
 
-	slog.Info("Running the relayer", "config", relayerConfig)
-	if err = relayer.Run(ctx); err != nil {
+	slog.Info("Running the relayer", "config", relayerConfig, "retention", retentionConfig.RetainFor)
+	if err = relayer.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
 		log.Fatal(err)
 	}
+	<-served
+	eventBus.Close()
+}
+
+// serveHTTP serves /metrics, /healthz and /readyz on port until ctx is done,
+// the relayer has no other HTTP endpoint.
+func serveHTTP(ctx context.Context, port string, checker *health.Checker) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", telemetry.MetricsHandler())
+	checker.Register(mux)
+
+	srv := &http.Server{Addr: fmt.Sprintf(":%v", port), Handler: mux}
+	if err := rest.Serve(ctx, srv, 5*time.Second); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("Cannot serve metrics and health checks", "port", port, "err", err)
+	}
+}
+
+// newArchive archives to dir, nil purges without archiving when it is empty.
+func newArchive(dir string) ports.EventArchive {
+	if dir != "" {
+		return archive.NewNDJSONArchive(dir)
+	}
+	return nil
+}
+
+func purge(ctx context.Context, store ports.OutboxRetention, cfg retention.Config, archiveDir string, args []string) error {
+	fs := flag.NewFlagSet("purge", flag.ExitOnError)
+	olderThan := fs.Duration("older-than", cfg.RetainFor, "purge delivered events older than that")
+	dir := fs.String("archive-dir", archiveDir, "archive purged events there first")
+	fs.Parse(args)
+	if *olderThan <= 0 {
+		return fmt.Errorf("-older-than must be positive")
+	}
+
+	cfg.RetainFor = *olderThan
+	count, err := retention.New(store, newArchive(*dir), cfg).Purge(ctx)
+	fmt.Println("purged", count, "events")
+	return err
+}
+
+func importArchives(ctx context.Context, store ports.OutboxRetention, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	redeliver := fs.Bool("redeliver", false, "deliver the imported events to subscribers again")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		usage()
+	}
+
+	importer := retention.New(store, archive.NewNDJSONArchive(""), retention.Config{})
+	for _, path := range fs.Args() {
+		imported, skipped, err := importer.Import(ctx, path, *redeliver)
+		fmt.Printf("%s: imported %d, skipped %d already in the outbox\n", path, imported, skipped)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,191 @@
+// Command replay feeds archived and retained outbox events to the handlers of
+// one consumer, to rebuild what it derives from them, or republishes them to
+// RabbitMQ marked with the x-replay header. Replayable consumers handle a
+// republished event once per run, the others and the ws gateway drop it.
+//
+//	replay -consumer announcement.join_message [-skip-processed] [filters]
+//	replay -republish [filters]
+//	replay -dry-run [filters]
+//
+// Filters: -aggregate <id> -type <event type>[,...] -since <time> -until <time>,
+// times as RFC 3339 or 2006-01-02. Archives are read from -archive-dir,
+// outbox.archive_dir by default, then the outbox unless -no-outbox is set.
+package main
+
+import (
+	"backend/internal/application/ports"
+	"backend/internal/application/services"
+	"backend/internal/application/workers"
+	"backend/internal/domain/events"
+	"backend/internal/domain/repositories"
+	"backend/internal/infra/archive"
+	"backend/internal/infra/config"
+	"backend/internal/infra/db/postgres"
+	rabbitmq "backend/internal/infra/rabbitMQ"
+	"backend/internal/processes/replay"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func parseTime(name, value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	log.Fatalf("Invalid -%s %q, expected RFC 3339 or 2006-01-02", name, value)
+	return time.Time{}
+}
+
+func main() {
+	cfg, err := config.Load(config.SERVICE_TOOL)
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+
+	aggregate := flag.String("aggregate", "", "only events of this aggregate id")
+	eventTypes := flag.String("type", "", "only events of these comma separated types")
+	since := flag.String("since", "", "only events occurred at or after this time")
+	until := flag.String("until", "", "only events occurred before this time")
+	archiveDir := flag.String("archive-dir", cfg.Outbox.ArchiveDir, "read archived events from there first")
+	noOutbox := flag.Bool("no-outbox", false, "only replay archived events")
+	consumerName := flag.String("consumer", "", "feed the events to the handlers of this consumer")
+	skipProcessed := flag.Bool("skip-processed", false, "with -consumer, skip events its inbox already recorded")
+	republish := flag.Bool("republish", false, "republish the events to RabbitMQ")
+	dryRun := flag.Bool("dry-run", false, "only list the events that would be replayed")
+	flag.Parse()
+
+	if !*dryRun && (*consumerName == "") == !*republish {
+		fmt.Fprintln(os.Stderr, "usage: replay -consumer <name> | -republish | -dry-run [filters]")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := events.Validate(); err != nil {
+		log.Fatalf("Invalid event registry: %v", err)
+	}
+
+	filter := ports.EventFilter{
+		Since: parseTime("since", *since),
+		Until: parseTime("until", *until),
+	}
+	if *aggregate != "" {
+		id, err := uuid.Parse(*aggregate)
+		if err != nil {
+			log.Fatalf("Invalid -aggregate: %v", err)
+		}
+		filter.AggregateID = id
+	}
+	if *eventTypes != "" {
+		filter.EventTypes = strings.Split(*eventTypes, ",")
+	}
+
+	pgxConn, err := postgres.NewPool(ctx, cfg.DB.URI)
+	if err != nil {
+		log.Fatalf("Cannot connect to db: %v", err)
+	}
+	defer pgxConn.Close()
+
+	var deliver func(context.Context, ports.OutboxRecord) error
+	switch {
+	case *dryRun:
+		deliver = func(ctx context.Context, rec ports.OutboxRecord) error {
+			fmt.Printf("%s\t%s\t%s/%s\t%s\n", rec.ID, rec.EventType, rec.AggregateName, rec.AggregateID, rec.OccurredAt.Format(time.RFC3339))
+			return nil
+		}
+	case *consumerName != "":
+		deliver, filter.EventTypes = toConsumer(pgxConn, *consumerName, *skipProcessed, filter.EventTypes)
+	default:
+		if cfg.EventBus.AMQPURI == "" {
+			log.Fatal("-republish needs event_bus.amqp_uri (AMQP_URI)")
+		}
+		conn, err := rabbitmq.Dial(cfg.EventBus.AMQPURI)
+		if err != nil {
+			log.Fatalf("Cannot connect to RabbitMQ: %v", err)
+		}
+		defer conn.Close()
+		publisher := rabbitmq.NewRMQEventPublisher(conn, 1)
+		defer publisher.Close(context.WithoutCancel(ctx))
+		// Replayable consumers record this run apart from the live delivery
+		// and handle the events again, the others and the ws gateway drop them
+		run := uuid.New()
+		fmt.Fprintln(os.Stderr, "replay run", run)
+		deliver = func(ctx context.Context, rec ports.OutboxRecord) error {
+			err := publisher.Publish(ctx, replay.Message(rec, run))
+			if errors.Is(err, ports.ErrUnroutable) {
+				slog.Warn("No queue bound for the replayed event", "eventId", rec.ID, "eventType", rec.EventType)
+				return nil
+			}
+			return err
+		}
+	}
+
+	var eventArchive ports.EventArchive
+	if *archiveDir != "" {
+		eventArchive = archive.NewNDJSONArchive(*archiveDir)
+	}
+	replayer := replay.New(postgres.NewPGOutboxReader(pgxConn), eventArchive, replay.Config{
+		BatchSize:  500,
+		FromOutbox: !*noOutbox,
+	})
+	count, err := replayer.Replay(ctx, filter, deliver)
+	fmt.Fprintln(os.Stderr, "replayed", count, "events")
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// toConsumer delivers to the handlers of the named consumer and narrows the
+// event types to those it handles.
+func toConsumer(pool *pgxpool.Pool, name string, skipProcessed bool, eventTypes []string) (func(context.Context, ports.OutboxRecord) error, []string) {
+	uow := postgres.NewBaseUoW(pool)
+	messageService := services.NewMessageService(postgres.NewScopedUoW(uow, func(rb repositories.RepoBundle) services.MessageRepos { return rb }), postgres.NewPGPermissionQueries(pool))
+	inbox := workers.NewInbox(postgres.NewScopedUoW(uow, func(rb repositories.RepoBundle) workers.InboxRepos { return rb }))
+
+	consumers := workers.Consumers(messageService)
+	i := slices.IndexFunc(consumers, func(c workers.Consumer) bool { return c.Name == name })
+	if i < 0 {
+		names := make([]string, len(consumers))
+		for j, c := range consumers {
+			names[j] = c.Name
+		}
+		log.Fatalf("Unknown consumer %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+	consumer := consumers[i]
+
+	handled := make([]string, 0, len(consumer.Handlers))
+	for eventType := range consumer.Handlers {
+		if len(eventTypes) == 0 || slices.Contains(eventTypes, eventType) {
+			handled = append(handled, eventType)
+		}
+	}
+	if len(handled) == 0 {
+		log.Fatalf("Consumer %q handles none of the requested event types", name)
+	}
+
+	return func(ctx context.Context, rec ports.OutboxRecord) error {
+		handler := consumer.Handlers[rec.EventType]
+		if skipProcessed {
+			handler = inbox.Wrap(consumer.Name, handler)
+		}
+		return handler(ctx, replay.Message(rec, uuid.Nil))
+	}, handled
+}
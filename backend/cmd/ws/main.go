@@ -1,26 +1,19 @@
 package main
 
 import (
-	"backend/internal/application/services"
-	"backend/internal/domain/repositories"
-	"backend/internal/infra/cache/inmemcache"
+	"backend/internal/bootstrap"
+	"backend/internal/domain/events"
+	"backend/internal/infra/bus"
+	"backend/internal/infra/config"
 	"backend/internal/infra/db/postgres"
-	rabbitmq "backend/internal/infra/rabbitMQ"
-	"backend/internal/interface/ws"
+	"backend/internal/infra/telemetry"
 	"context"
-	"fmt"
 	"log"
 	"log/slog"
-	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
-
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/cors"
-	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/rabbitmq/amqp091-go"
 )
 
 func main() {
@@ -29,22 +22,37 @@ func main() {
 	ctx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
 	defer stop()
 
-	pgPool, err := pgxpool.New(ctx, os.Getenv("DB_URI"))
+	cfg, err := config.Load(config.SERVICE_WS)
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+
+	// Every event version still in flight must upcast to the one handlers expect
+	if err := events.Validate(); err != nil {
+		log.Fatalf("Invalid event registry: %v", err)
+	}
 
+	shutdownTracing, err := telemetry.Setup(ctx, "ws")
 	if err != nil {
-		cancel()
-		log.Fatalf("Cannot connect to db: %v", err)
+		log.Fatalf("Cannot set up tracing: %v", err)
 	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdownTracing(flushCtx)
+	}()
 
-	uow := postgres.NewBaseUoW(pgPool)
+	pgPool, err := postgres.NewPool(ctx, cfg.DB.URI)
 
-	visiblityQueries := services.NewVisibilityQueries(postgres.NewScopedUoW(uow, func(rb repositories.RepoBundle) services.PermissionRepos { return rb }))
-	authService := services.NewAuthService(postgres.NewScopedUoW(uow, func(rb repositories.RepoBundle) services.AuthRepos { return rb }), os.Getenv("SECRET"))
+	if err != nil {
+		cancel()
+		log.Fatalf("Cannot connect to db: %v", err)
+	}
 
-	rabbitMQConn, err := amqp091.Dial(os.Getenv("AMQP_URI"))
+	eventBus, err := bus.Open(cfg.EventBus, pgPool)
 	if err != nil {
 		cancel()
-		log.Fatalf("Cannot connect to rabbitMQ: %v", err)
+		log.Fatalf("Cannot connect to the event bus: %v", err)
 	}
 
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
@@ -52,41 +60,21 @@ func main() {
 	}))
 	slog.SetDefault(logger)
 
-	userResolver := postgres.NewPGNicknameResolver(pgPool)
-	cacheStore := inmemcache.NewInMemoryCache(15*time.Minute, 2*time.Minute)
-
-	eventSub, err := rabbitmq.NewRMQEventSubscriber(ctx, rabbitMQConn, "websocket", "noncord.event", true)
+	gateway, err := bootstrap.WS(ctx, cfg, pgPool, eventBus)
 	if err != nil {
 		cancel()
-		log.Fatalf("Cannot connect to rabbitMQ: %v", err)
+		log.Fatal(err)
 	}
-	defer eventSub.Close()
 
-	wsHub, err := ws.NewHub(ctx, authService, visiblityQueries, eventSub, cacheStore, userResolver)
-	if err != nil {
-		cancel()
-		log.Fatalf("Cannot connect to rabbitMQ: %v", err)
+	log.Printf("listening on port %v, metrics on port %v", gateway.Port, gateway.MetricsPort)
+	if err = gateway.Serve(ctx); err != nil {
+		slog.Error("Server stopped", "err", err)
 	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "9999"
+	slog.Info("Shutting down")
+	if err = gateway.Close(); err != nil {
+		slog.Error("Cannot close the event subscriber", "err", err)
 	}
-
-	r := chi.NewRouter()
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"https://*", "http://*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: false,
-		MaxAge:           300,
-	}))
-
-	r.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		ws.ServeWs(wsHub, authService, w, r)
-	})
-
-	log.Printf("listening on port %v", port)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%v", port), r))
+	eventBus.Close()
+	pgPool.Close()
 }
@@ -6,6 +6,10 @@ import (
 
 type AuthenticateCommand struct {
 	AccessToken string
+	// RequireActive also looks the user up and refuses a disabled one, whose
+	// access token stays valid until it expires. Meant for long-lived
+	// connections, a request is short enough to rely on the token.
+	RequireActive bool
 }
 
 type AuthenticateCommandResult struct {
@@ -5,4 +5,7 @@ import "github.com/google/uuid"
 type DeleteServerCommand struct {
 	UserId   uuid.UUID
 	ServerId uuid.UUID
+
+	// Force skips the owner check, for operators
+	Force bool
 }
@@ -0,0 +1,9 @@
+package command
+
+import "github.com/google/uuid"
+
+type ReactMessageCommand struct {
+	MessageId uuid.UUID
+	UserId    uuid.UUID
+	EmoteId   uuid.UUID
+}
@@ -0,0 +1,27 @@
+package command
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type IssueStreamTicketCommand struct {
+	UserId uuid.UUID
+	TTL    time.Duration
+}
+
+type IssueStreamTicketCommandResult struct {
+	Ticket    string
+	ExpiresAt time.Time
+}
+
+type RedeemStreamTicketCommand struct {
+	Ticket string
+}
+
+type RedeemStreamTicketCommandResult struct {
+	UserId    uuid.UUID
+	TicketId  uuid.UUID
+	ExpiresAt time.Time
+}
@@ -0,0 +1,12 @@
+package command
+
+import "github.com/google/uuid"
+
+type TransferServerCommand struct {
+	UserId   uuid.UUID
+	ServerId uuid.UUID
+	NewOwner uuid.UUID
+
+	// Force skips the owner check, for operators
+	Force bool
+}
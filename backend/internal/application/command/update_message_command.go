@@ -1,7 +1,17 @@
 package command
 
+import (
+	"backend/internal/application/common"
+
+	"github.com/google/uuid"
+)
+
 type UpdateMessageCommand struct {
+	MessageId uuid.UUID
+	UserId    uuid.UUID
+	Content   string
 }
 
 type UpdateMessageCommandResult struct {
+	Result *common.Message
 }
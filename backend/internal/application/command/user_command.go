@@ -0,0 +1,41 @@
+package command
+
+import (
+	"backend/internal/application/common"
+	"backend/internal/domain/entities"
+
+	"github.com/google/uuid"
+)
+
+type CreateUserCommand struct {
+	Username string
+	Email    string
+	Password string
+	Flags    entities.UserFlags
+	Verified bool
+}
+
+type CreateUserCommandResult struct {
+	Result *common.UserResult
+}
+
+type UpdateUserCommand struct {
+	UserId uuid.UUID
+
+	// Disabling a user also revokes their sessions
+	Disabled *bool
+	Verified *bool
+	Flags    *entities.UserFlags
+}
+
+type UpdateUserCommandResult struct {
+	Result *common.UserResult
+}
+
+type RevokeSessionsCommand struct {
+	UserId uuid.UUID
+}
+
+type RevokeSessionsCommandResult struct {
+	Revoked int
+}
@@ -11,4 +11,6 @@ type AuthService interface {
 	Logout(context.Context, command.LogoutCommand) error
 	Refresh(context.Context, command.RefreshCommand) (command.RefreshCommandResult, error)
 	Authenticate(context.Context, command.AuthenticateCommand) (command.AuthenticateCommandResult, error)
+	IssueStreamTicket(context.Context, command.IssueStreamTicketCommand) (command.IssueStreamTicketCommandResult, error)
+	RedeemStreamTicket(context.Context, command.RedeemStreamTicketCommand) (command.RedeemStreamTicketCommandResult, error)
 }
@@ -11,6 +11,8 @@ type MessageService interface {
 	CreateSystemMessage(context.Context, command.CreateSystemMessageCommand) error
 	Update(context.Context, command.UpdateMessageCommand) (command.UpdateMessageCommandResult, error)
 	Delete(context.Context, command.DeleteMessageCommand) error
+	AddReaction(context.Context, command.ReactMessageCommand) error
+	RemoveReaction(context.Context, command.ReactMessageCommand) error
 }
 
 type MessageQueries interface {
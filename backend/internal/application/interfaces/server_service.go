@@ -14,6 +14,7 @@ type ServerService interface {
 	UpsertRole(context.Context, command.UpsertRoleCommand) (command.UpsertRoleCommandResult, error)
 	ReorderRoles(context.Context) error
 	Delete(context.Context, command.DeleteServerCommand) error
+	TransferOwnership(context.Context, command.TransferServerCommand) error
 	DeleteRole(context.Context, command.DeleteRoleCommand) error
 }
 
@@ -1,6 +1,7 @@
 package interfaces
 
 import (
+	"backend/internal/application/command"
 	"backend/internal/application/common"
 	"context"
 
@@ -8,8 +9,12 @@ import (
 )
 
 type UserService interface {
+	Create(context.Context, command.CreateUserCommand) (command.CreateUserCommandResult, error)
+	Update(context.Context, command.UpdateUserCommand) (command.UpdateUserCommandResult, error)
+	RevokeSessions(context.Context, command.RevokeSessionsCommand) (command.RevokeSessionsCommandResult, error)
 }
 
 type UserQueries interface {
 	GetBasic(context.Context, uuid.UUID) (common.UserResult, error)
+	GetByUsername(context.Context, string) (common.UserResult, error)
 }
@@ -17,3 +17,8 @@ type VisibilityQueries interface {
 	GetVisibleChannelsInServer(ctx context.Context, params query.GetVisibleChannelsInServer) (uuid.UUIDs, error)
 	GetVisibleServers(ctx context.Context, userId uuid.UUID) (uuid.UUIDs, error)
 }
+
+type PermissionQueries interface {
+	// GetChannelPermissions resolves the roles and overwrites of a member in a channel
+	GetChannelPermissions(ctx context.Context, params query.GetChannelPermissions) (query.GetChannelPermissionsResult, error)
+}
@@ -2,6 +2,9 @@ package ports
 
 import (
 	"context"
+	"errors"
+	"maps"
+	"slices"
 
 	"github.com/google/uuid"
 )
@@ -13,8 +16,46 @@ type EventMessage struct {
 	EventType   string
 }
 
+// HeaderCarrier lets an OpenTelemetry propagator read and write the trace
+// context in message headers, it implements propagation.TextMapCarrier.
+type HeaderCarrier map[string]any
+
+func (c HeaderCarrier) Get(key string) string {
+	v, _ := c[key].(string)
+	return v
+}
+
+func (c HeaderCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c HeaderCarrier) Keys() []string {
+	return slices.Collect(maps.Keys(c))
+}
+
+// HEADER_REPLAY is set to "true" on events replayed from history, so live
+// consumers can tell them apart from new ones. HEADER_REPLAY_RUN identifies
+// the replay, an inbox records the event once per run rather than once.
+const (
+	HEADER_REPLAY     = "x-replay"
+	HEADER_REPLAY_RUN = "x-replay-run"
+)
+
+// IsReplay tells whether the event was replayed from history.
+func (m EventMessage) IsReplay() bool {
+	v, _ := m.Headers[HEADER_REPLAY].(string)
+	return v == "true"
+}
+
+// ErrUnroutable is returned for a message the broker accepted but could not
+// route to any subscriber.
+var ErrUnroutable = errors.New("no subscriber bound for the event")
+
 type EventPublisher interface {
 	Publish(ctx context.Context, msg EventMessage) error
+	// PublishBatch publishes msgs and waits until the broker confirmed all of
+	// them, the result holds the error of each message at its index.
+	PublishBatch(ctx context.Context, msgs []EventMessage) []error
 	Close(ctx context.Context) error
 }
 
@@ -0,0 +1,11 @@
+package ports
+
+import "context"
+
+// OutboxListener wakes the relayer when new events are committed to the outbox.
+type OutboxListener interface {
+	// Listen signals on the returned channel after every commit that wrote
+	// to the outbox, bursts are coalesced. The channel is closed when the
+	// underlying connection drops or ctx is done.
+	Listen(ctx context.Context) (<-chan struct{}, error)
+}
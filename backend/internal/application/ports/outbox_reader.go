@@ -2,6 +2,8 @@ package ports
 
 import (
 	"context"
+	"slices"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,17 +16,74 @@ type OutboxRecord struct {
 	EventType     string
 	SchemaVersion int32
 	OccurredAt    time.Time
+	Seq           int64
 
-	Payload     []byte
-	Status      string
-	Attempts    int32
-	ClaimedAt   *time.Time
-	PublishedAt *time.Time
+	Payload       []byte
+	Status        string
+	Attempts      int32
+	ClaimedAt     *time.Time
+	PublishedAt   *time.Time
+	NextAttemptAt time.Time
+	LastError     string
+	// TraceContext is the W3C trace context of the transaction that wrote
+	// the record, e.g. {"traceparent": "00-..."}
+	TraceContext map[string]string
+}
+
+// Message is the record as delivered to subscribers, whichever bus carries it.
+func (r OutboxRecord) Message() EventMessage {
+	msg := EventMessage{
+		AggregateId: r.AggregateID,
+		EventType:   r.EventType,
+		Payload:     r.Payload,
+		Headers: map[string]any{
+			"event_type":     r.EventType,
+			"aggregate_name": r.AggregateName,
+			"schema_version": strconv.Itoa(int(r.SchemaVersion)),
+			"occurred_at":    r.OccurredAt.UTC().Format(time.RFC3339Nano),
+			"event_id":       r.ID.String(),
+		},
+	}
+	for key, value := range r.TraceContext {
+		msg.Headers[key] = value
+	}
+	return msg
+}
+
+// EventFilter selects outbox records, zero fields match every record.
+type EventFilter struct {
+	AggregateID uuid.UUID
+	EventTypes  []string
+	Since       time.Time // inclusive
+	Until       time.Time // exclusive
+}
+
+func (f EventFilter) Match(rec OutboxRecord) bool {
+	return (f.AggregateID == uuid.Nil || rec.AggregateID == f.AggregateID) &&
+		(len(f.EventTypes) == 0 || slices.Contains(f.EventTypes, rec.EventType)) &&
+		(f.Since.IsZero() || !rec.OccurredAt.Before(f.Since)) &&
+		(f.Until.IsZero() || rec.OccurredAt.Before(f.Until))
 }
 
 type OutboxReader interface {
-	ClaimBatch(ctx context.Context, limit int32, staleAfter time.Duration) ([]OutboxRecord, error)
+	// ClaimBatch claims records whose aggregate hashes to one of shards, a
+	// shardCount of 0 claims from the whole outbox. Only the oldest
+	// undelivered record of an aggregate is claimed, so a batch holds at most
+	// one record per aggregate.
+	ClaimBatch(ctx context.Context, limit int32, staleAfter time.Duration, shardCount int32, shards []int32) ([]OutboxRecord, error)
 	MarkDispatched(ctx context.Context, id uuid.UUID) error
+	// Retry puts the record back to pending, claimable again after delay.
+	Retry(ctx context.Context, id uuid.UUID, delay time.Duration, lastErr string) error
+	// DeadLetter parks the record until an operator requeues it.
+	DeadLetter(ctx context.Context, id uuid.UUID, lastErr string) error
+
+	// List returns the records matching filter whose seq is after afterSeq,
+	// in seq order.
+	List(ctx context.Context, filter EventFilter, afterSeq int64, limit int32) ([]OutboxRecord, error)
+	ListDeadLettered(ctx context.Context, limit, offset int32) ([]OutboxRecord, error)
+	Get(ctx context.Context, id uuid.UUID) (OutboxRecord, error)
+	// Retained returns those of ids the outbox still holds.
+	Retained(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error)
+	// Requeue resets a dead lettered record so it gets a fresh set of attempts.
 	Requeue(ctx context.Context, id uuid.UUID) error
-	MarkFailed(ctx context.Context, id uuid.UUID) error
 }
@@ -0,0 +1,31 @@
+package ports
+
+import (
+	"context"
+	"time"
+)
+
+// OutboxRetention removes delivered events from the outbox and restores them
+// from an archive.
+type OutboxRetention interface {
+	// Purge deletes up to limit delivered records that occurred before
+	// cutoff, oldest first, and returns how many it deleted. archive, when
+	// not nil, gets the records before the delete commits; an error from it
+	// keeps them in the outbox.
+	Purge(ctx context.Context, cutoff time.Time, limit int32, archive func([]OutboxRecord) error) (int, error)
+	// Import inserts an archived record back into the outbox, false when the
+	// outbox still holds it. A dispatched record comes back as history only,
+	// a pending one is delivered again.
+	Import(ctx context.Context, rec OutboxRecord) (bool, error)
+}
+
+// EventArchive keeps outbox records once they are purged.
+type EventArchive interface {
+	Write(ctx context.Context, records []OutboxRecord) error
+	// Files lists the archive files that may hold events occurred between
+	// since and until, oldest first. Zero bounds are open.
+	Files(ctx context.Context, since, until time.Time) ([]string, error)
+	// Read calls fn with every record of the archive file at path, in the
+	// order they were written.
+	Read(ctx context.Context, path string, fn func(OutboxRecord) error) error
+}
@@ -0,0 +1,17 @@
+package ports
+
+import (
+	"context"
+	"time"
+)
+
+// ShardLeaser hands out outbox shards to relayer instances. Leases expire on
+// their own, so shards of a crashed relayer are picked up by the others.
+type ShardLeaser interface {
+	// Acquire heartbeats the owner, renews the leases it already holds and
+	// rebalances so each live relayer holds about shardCount/live shards.
+	// It returns every shard the owner holds once it is done.
+	Acquire(ctx context.Context, owner string, shardCount int32, lease time.Duration) ([]int32, error)
+	// Release gives up every lease of the owner.
+	Release(ctx context.Context, owner string) error
+}
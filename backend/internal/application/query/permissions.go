@@ -17,3 +17,14 @@ type CheckServerPerm struct {
 	ServerId   uuid.UUID
 	Permission entities.ServerPermissionBits
 }
+
+type GetChannelPermissions struct {
+	UserId    uuid.UUID
+	ChannelId uuid.UUID
+}
+
+type GetChannelPermissionsResult struct {
+	ServerId    uuid.UUID
+	Owner       bool
+	Permissions entities.ServerPermissionBits
+}
@@ -19,28 +19,48 @@ import (
 type AuthRepos interface {
 	User() repositories.UserRepo
 	Session() repositories.SessionRepo
+	StreamTicket() repositories.StreamTicketRepo
+}
+
+type AuthConfig struct {
+	Secret         string        `yaml:"secret" env:"SECRET"` // signs the access tokens
+	AccessTokenTTL time.Duration `yaml:"access_token_ttl" env:"AUTH_ACCESS_TOKEN_TTL"`
+	SessionTTL     time.Duration `yaml:"session_ttl" env:"AUTH_SESSION_TTL"` // refresh token lifetime, renewed on every refresh
+}
+
+var DefaultAuthConfig = AuthConfig{
+	AccessTokenTTL: 30 * time.Minute,
+	SessionTTL:     30 * 24 * time.Hour,
 }
 
 type AuthService struct {
-	uow    repositories.UnitOfWork[AuthRepos]
-	secret string
+	uow repositories.UnitOfWork[AuthRepos]
+	cfg AuthConfig
 }
 
-func NewAuthService(uow repositories.UnitOfWork[AuthRepos], secret string) interfaces.AuthService {
+func NewAuthService(uow repositories.UnitOfWork[AuthRepos], cfg AuthConfig) interfaces.AuthService {
 	return &AuthService{
-		uow:    uow,
-		secret: secret,
+		uow: uow,
+		cfg: cfg,
 	}
 }
 
-func (s *AuthService) Register(ctx context.Context, cmd command.RegisterCommand) (res command.RegisterCommandResult, err error) {
-	if len(cmd.Password) < 8 || len(cmd.Password) > 72 {
-		return res, entities.NewError(entities.ErrCodeValidationError, "password must be between 8 and 72 characters long", nil)
+func hashPassword(password string) (string, error) {
+	if len(password) < 8 || len(password) > 72 {
+		return "", entities.NewError(entities.ErrCodeValidationError, "password must be between 8 and 72 characters long", nil)
 	}
 
-	password, err := bcrypt.GenerateFromPassword([]byte(cmd.Password), bcrypt.DefaultCost)
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		return res, entities.NewError(entities.ErrCodeDepFail, "cannot create new password", err)
+		return "", entities.NewError(entities.ErrCodeDepFail, "cannot create new password", err)
+	}
+	return string(hash), nil
+}
+
+func (s *AuthService) Register(ctx context.Context, cmd command.RegisterCommand) (res command.RegisterCommandResult, err error) {
+	password, err := hashPassword(cmd.Password)
+	if err != nil {
+		return res, err
 	}
 
 	user := entities.NewUser(entities.NewUserParam{
@@ -48,7 +68,7 @@ func (s *AuthService) Register(ctx context.Context, cmd command.RegisterCommand)
 		Email:       strings.ToLower(cmd.Email),
 		DisplayName: cmd.Username,
 		AboutMe:     "",
-		Password:    string(password),
+		Password:    password,
 		AvatarUrl:   "",
 		BannerUrl:   "",
 		Flags:       entities.UserFlagUser,
@@ -103,19 +123,23 @@ func (s *AuthService) Login(ctx context.Context, param command.LoginCommand) (re
 			return entities.NewError(entities.ErrCodeUnauth, "invalid password", err)
 		}
 
+		if user.Disabled {
+			return entities.NewError(entities.ErrCodeForbidden, "user is disabled", nil)
+		}
+
 		now := time.Now()
-		session := entities.NewSession(user.Id, now.Add(time.Hour*24*30), param.UserAgent)
+		session := entities.NewSession(user.Id, now.Add(s.cfg.SessionTTL), param.UserAgent)
 		accessTokenClaim := jwt.NewWithClaims(jwt.SigningMethodHS256, AccessTokenClaim{
 			UserId:    uuid.UUID(user.Id).String(),
 			Username:  user.Username,
 			UserFlags: user.Flags,
 			RegisteredClaims: jwt.RegisteredClaims{
 				Issuer:    "Noncord",
-				ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute * 30)),
+				ExpiresAt: jwt.NewNumericDate(now.Add(s.cfg.AccessTokenTTL)),
 				IssuedAt:  jwt.NewNumericDate(now),
 			},
 		})
-		accessToken, err := accessTokenClaim.SignedString([]byte(s.secret))
+		accessToken, err := accessTokenClaim.SignedString([]byte(s.cfg.Secret))
 		if err != nil {
 			return entities.NewError(entities.ErrCodeDepFail, "fail to generate access token", err)
 		}
@@ -159,10 +183,14 @@ func (s *AuthService) Refresh(ctx context.Context, param command.RefreshCommand)
 			return entities.GetErrOrDefault(err, entities.ErrCodeDepFail, "cannot get user")
 		}
 
+		if user.Disabled {
+			return entities.NewError(entities.ErrCodeForbidden, "user is disabled", nil)
+		}
+
 		now := time.Now()
 		session.Token = entities.RandomToken()
 		session.RotationCount += 1
-		session.ExpiresAt = now.Add(30 * 24 * time.Hour)
+		session.ExpiresAt = now.Add(s.cfg.SessionTTL)
 
 		accessTokenClaim := jwt.NewWithClaims(jwt.SigningMethodHS256, AccessTokenClaim{
 			UserId:    uuid.UUID(user.Id).String(),
@@ -170,11 +198,11 @@ func (s *AuthService) Refresh(ctx context.Context, param command.RefreshCommand)
 			UserFlags: user.Flags,
 			RegisteredClaims: jwt.RegisteredClaims{
 				Issuer:    "Noncord",
-				ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute * 30)),
+				ExpiresAt: jwt.NewNumericDate(now.Add(s.cfg.AccessTokenTTL)),
 				IssuedAt:  jwt.NewNumericDate(now),
 			},
 		})
-		accessToken, err := accessTokenClaim.SignedString([]byte(s.secret))
+		accessToken, err := accessTokenClaim.SignedString([]byte(s.cfg.Secret))
 		if err != nil {
 			return entities.NewError(entities.ErrCodeDepFail, "fail to generate access token", err)
 		}
@@ -198,7 +226,7 @@ func (s *AuthService) Authenticate(ctx context.Context, param command.Authentica
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, entities.NewError(entities.ErrCodeUnauth, "invalid token", nil)
 		}
-		return []byte(s.secret), nil
+		return []byte(s.cfg.Secret), nil
 	}, jwt.WithValidMethods([]string{"HS256"}))
 
 	if err != nil {
@@ -231,7 +259,98 @@ func (s *AuthService) Authenticate(ctx context.Context, param command.Authentica
 	if err != nil {
 		return res, entities.GetErrOrDefault(err, entities.ErrCodeUnauth, "invalid token, invalid user id")
 	}
+
+	if param.RequireActive {
+		err = s.uow.Do(ctx, func(ctx context.Context, repos AuthRepos) error {
+			user, err := repos.User().Find(ctx, entities.UserId(userId))
+			if err != nil {
+				return entities.GetErrOrDefault(err, entities.ErrCodeUnauth, "cannot get user")
+			}
+			if user.Disabled {
+				return entities.NewError(entities.ErrCodeForbidden, "user is disabled", nil)
+			}
+			return nil
+		})
+		if err != nil {
+			return res, err
+		}
+	}
 	res.UserId = (*uuid.UUID)(&userId)
 
 	return res, nil
 }
+
+// streamTicketAudience keeps stream tickets and access tokens apart, an
+// access token has no audience and a ticket has no userId claim
+const streamTicketAudience = "stream"
+
+// IssueStreamTicket signs a short-lived ticket for clients that have to put
+// their credentials in a URL, such as EventSource. It is meant to be redeemed
+// once, see RedeemStreamTicket.
+func (s *AuthService) IssueStreamTicket(ctx context.Context, param command.IssueStreamTicketCommand) (res command.IssueStreamTicketCommandResult, err error) {
+	now := time.Now()
+	expiresAt := now.Add(param.TTL)
+	ticket, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    "Noncord",
+		Subject:   param.UserId.String(),
+		Audience:  jwt.ClaimStrings{streamTicketAudience},
+		ID:        uuid.NewString(),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+		IssuedAt:  jwt.NewNumericDate(now),
+	}).SignedString([]byte(s.cfg.Secret))
+	if err != nil {
+		return res, entities.NewError(entities.ErrCodeDepFail, "fail to generate stream ticket", err)
+	}
+
+	res.Ticket = ticket
+	res.ExpiresAt = expiresAt
+	return res, nil
+}
+
+func (s *AuthService) RedeemStreamTicket(ctx context.Context, param command.RedeemStreamTicketCommand) (res command.RedeemStreamTicketCommandResult, err error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err = jwt.ParseWithClaims(param.Ticket, claims, func(token *jwt.Token) (any, error) {
+		return []byte(s.cfg.Secret), nil
+	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithAudience(streamTicketAudience), jwt.WithExpirationRequired())
+	if err != nil {
+		return res, entities.NewError(entities.ErrCodeUnauth, "invalid stream ticket", err)
+	}
+
+	userId, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return res, entities.NewError(entities.ErrCodeUnauth, "invalid stream ticket, invalid user id", err)
+	}
+	ticketId, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return res, entities.NewError(entities.ErrCodeUnauth, "invalid stream ticket, invalid id", err)
+	}
+
+	// Recorded in the database rather than per gateway instance, so a ticket
+	// leaked through a URL can't open a second stream on another instance
+	err = s.uow.Do(ctx, func(ctx context.Context, repos AuthRepos) error {
+		user, err := repos.User().Find(ctx, entities.UserId(userId))
+		if err != nil {
+			return entities.GetErrOrDefault(err, entities.ErrCodeUnauth, "cannot get user")
+		}
+		if user.Disabled {
+			return entities.NewError(entities.ErrCodeForbidden, "user is disabled", nil)
+		}
+
+		first, err := repos.StreamTicket().Redeem(ctx, ticketId, claims.ExpiresAt.Time)
+		if err != nil {
+			return entities.NewError(entities.ErrCodeDepFail, "cannot redeem stream ticket", err)
+		}
+		if !first {
+			return entities.NewError(entities.ErrCodeUnauth, "stream ticket already redeemed", nil)
+		}
+		return nil
+	})
+	if err != nil {
+		return res, err
+	}
+
+	res.UserId = userId
+	res.TicketId = ticketId
+	res.ExpiresAt = claims.ExpiresAt.Time
+	return res, nil
+}
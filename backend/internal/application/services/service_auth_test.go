@@ -0,0 +1,150 @@
+package services
+
+import (
+	"backend/internal/application/command"
+	"backend/internal/domain/entities"
+	"backend/internal/domain/repositories"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+type memUserRepo struct {
+	repositories.UserRepo
+	users map[entities.UserId]*entities.User
+}
+
+func (r memUserRepo) Find(ctx context.Context, id entities.UserId) (*entities.User, error) {
+	if u, ok := r.users[id]; ok {
+		return u, nil
+	}
+	return nil, entities.NewError(entities.ErrCodeNoObject, "user not found", nil)
+}
+
+func (r memUserRepo) Save(ctx context.Context, user *entities.User) error {
+	r.users[user.Id] = user
+	return nil
+}
+
+// memStreamTickets is shared by every "instance" of the service
+type memStreamTickets map[uuid.UUID]time.Time
+
+func (m memStreamTickets) Redeem(ctx context.Context, ticketId uuid.UUID, expiresAt time.Time) (bool, error) {
+	if _, ok := m[ticketId]; ok {
+		return false, nil
+	}
+	m[ticketId] = expiresAt
+	return true, nil
+}
+
+type memAuthRepos struct {
+	AuthRepos
+	users   memUserRepo
+	tickets memStreamTickets
+}
+
+func (r memAuthRepos) User() repositories.UserRepo                 { return r.users }
+func (r memAuthRepos) StreamTicket() repositories.StreamTicketRepo { return r.tickets }
+
+type memAuthUoW struct{ repos memAuthRepos }
+
+func (u memAuthUoW) Do(ctx context.Context, fn func(ctx context.Context, repos AuthRepos) error) error {
+	return fn(ctx, u.repos)
+}
+
+func TestRedeemStreamTicket(t *testing.T) {
+	active, disabled := uuid.New(), uuid.New()
+	cfg := AuthConfig{Secret: "test secret", AccessTokenTTL: time.Minute}
+
+	tests := []struct {
+		name     string
+		ticket   func(s *AuthService) string
+		redeems  int // redemptions before the checked one
+		wantCode entities.ChatErrorCode
+	}{
+		{
+			name: "fresh ticket",
+			ticket: func(s *AuthService) string {
+				res, _ := s.IssueStreamTicket(context.Background(), command.IssueStreamTicketCommand{UserId: active, TTL: time.Minute})
+				return res.Ticket
+			},
+		},
+		{
+			name: "ticket redeemed on another instance",
+			ticket: func(s *AuthService) string {
+				res, _ := s.IssueStreamTicket(context.Background(), command.IssueStreamTicketCommand{UserId: active, TTL: time.Minute})
+				return res.Ticket
+			},
+			redeems:  1,
+			wantCode: entities.ErrCodeUnauth,
+		},
+		{
+			name: "disabled user",
+			ticket: func(s *AuthService) string {
+				res, _ := s.IssueStreamTicket(context.Background(), command.IssueStreamTicketCommand{UserId: disabled, TTL: time.Minute})
+				return res.Ticket
+			},
+			wantCode: entities.ErrCodeForbidden,
+		},
+		{
+			name: "unknown user",
+			ticket: func(s *AuthService) string {
+				res, _ := s.IssueStreamTicket(context.Background(), command.IssueStreamTicketCommand{UserId: uuid.New(), TTL: time.Minute})
+				return res.Ticket
+			},
+			wantCode: entities.ErrCodeNoObject,
+		},
+		{
+			name: "expired ticket",
+			ticket: func(s *AuthService) string {
+				res, _ := s.IssueStreamTicket(context.Background(), command.IssueStreamTicketCommand{UserId: active, TTL: -time.Minute})
+				return res.Ticket
+			},
+			wantCode: entities.ErrCodeUnauth,
+		},
+		{
+			name: "access token",
+			ticket: func(s *AuthService) string {
+				token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, AccessTokenClaim{
+					UserId:           active.String(),
+					RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))},
+				}).SignedString([]byte(s.cfg.Secret))
+				return token
+			},
+			wantCode: entities.ErrCodeUnauth,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repos := memAuthRepos{
+				users: memUserRepo{users: map[entities.UserId]*entities.User{
+					entities.UserId(active):   {Id: entities.UserId(active)},
+					entities.UserId(disabled): {Id: entities.UserId(disabled), Disabled: true},
+				}},
+				tickets: memStreamTickets{},
+			}
+			// Two instances behind one database
+			issuer := &AuthService{uow: memAuthUoW{repos}, cfg: cfg}
+			other := &AuthService{uow: memAuthUoW{repos}, cfg: cfg}
+
+			ticket := tt.ticket(issuer)
+			for range tt.redeems {
+				if _, err := issuer.RedeemStreamTicket(context.Background(), command.RedeemStreamTicketCommand{Ticket: ticket}); err != nil {
+					t.Fatalf("first redemption: %v", err)
+				}
+			}
+
+			res, err := other.RedeemStreamTicket(context.Background(), command.RedeemStreamTicketCommand{Ticket: ticket})
+			if got := errorCode(err); got != tt.wantCode {
+				t.Fatalf("err = %v, want code %q", err, tt.wantCode)
+			}
+			if err == nil && res.UserId != active {
+				t.Errorf("user = %s, want %s", res.UserId, active)
+			}
+		})
+	}
+}
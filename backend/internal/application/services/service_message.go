@@ -4,9 +4,12 @@ import (
 	"backend/internal/application/command"
 	"backend/internal/application/interfaces"
 	"backend/internal/application/mapper"
+	"backend/internal/application/query"
 	"backend/internal/domain/entities"
 	"backend/internal/domain/repositories"
 	"context"
+
+	"github.com/google/uuid"
 )
 
 type MessageRepos interface {
@@ -16,11 +19,12 @@ type MessageRepos interface {
 }
 
 type MessageService struct {
-	uow repositories.UnitOfWork[MessageRepos]
+	uow         repositories.UnitOfWork[MessageRepos]
+	permissions interfaces.PermissionQueries
 }
 
-func NewMessageService(uow repositories.UnitOfWork[MessageRepos]) interfaces.MessageService {
-	return &MessageService{uow}
+func NewMessageService(uow repositories.UnitOfWork[MessageRepos], permissions interfaces.PermissionQueries) interfaces.MessageService {
+	return &MessageService{uow, permissions}
 }
 
 func (s *MessageService) Create(ctx context.Context, params command.CreateMessageCommand) (res command.CreateMessageCommandResult, err error) {
@@ -78,9 +82,36 @@ func (s *MessageService) CreateSystemMessage(ctx context.Context, params command
 	})
 }
 
-func (s *MessageService) Update(context.Context, command.UpdateMessageCommand) (res command.UpdateMessageCommandResult, err error) {
-	// TODO: here
-	return res, entities.NewError(entities.ErrCodeForbidden, "method not implemented", nil)
+func (s *MessageService) Update(ctx context.Context, params command.UpdateMessageCommand) (res command.UpdateMessageCommandResult, err error) {
+	err = s.uow.Do(ctx, func(ctx context.Context, repos MessageRepos) error {
+		msg, err := repos.Message().Find(ctx, entities.MessageId(params.MessageId))
+		if err != nil {
+			return entities.GetErrOrDefault(err, entities.ErrCodeDepFail, "cannot get message")
+		}
+
+		if msg.ChannelId == nil {
+			return entities.NewError(entities.ErrCodeForbidden, "dm group not implemented", nil)
+		}
+		if !msg.IsAuthor(entities.UserId(params.UserId)) {
+			return entities.NewError(entities.ErrCodeForbidden, "only the author can edit a message", nil)
+		}
+
+		if err = msg.UpdateContent(params.Content); err != nil {
+			return err
+		}
+
+		msg, err = repos.Message().Save(ctx, msg)
+		if err != nil {
+			return entities.GetErrOrDefault(err, entities.ErrCodeDepFail, "failed to save message")
+		}
+
+		res = command.UpdateMessageCommandResult{
+			Result: mapper.MessageToResult(msg),
+		}
+		return nil
+	})
+
+	return res, err
 }
 
 func (s *MessageService) Delete(ctx context.Context, params command.DeleteMessageCommand) error {
@@ -92,7 +123,10 @@ func (s *MessageService) Delete(ctx context.Context, params command.DeleteMessag
 
 		if msg.ChannelId != nil {
 			if !msg.IsAuthor(entities.UserId(params.UserId)) && !params.HasMessageManagement {
-				return entities.NewError(entities.ErrCodeForbidden, "user don't have permission to delete message", nil)
+				// Callers that did not resolve the permission leave it to us
+				if err = s.checkChannelPermissions(ctx, params.UserId, *msg.ChannelId, entities.PermViewChannel, entities.PermManageMessages); err != nil {
+					return err
+				}
 			}
 
 			err = msg.Delete()
@@ -110,3 +144,61 @@ func (s *MessageService) Delete(ctx context.Context, params command.DeleteMessag
 		}
 	})
 }
+
+func (s *MessageService) AddReaction(ctx context.Context, params command.ReactMessageCommand) error {
+	return s.uow.Do(ctx, func(ctx context.Context, repos MessageRepos) error {
+		msg, err := s.findReactable(ctx, repos, params)
+		if err != nil {
+			return err
+		}
+
+		msg.AddReaction(entities.UserId(params.UserId), entities.EmoteId(params.EmoteId))
+		_, err = repos.Message().Save(ctx, msg)
+		return entities.GetErrOrDefault(err, entities.ErrCodeDepFail, "cannot add reaction")
+	})
+}
+
+func (s *MessageService) RemoveReaction(ctx context.Context, params command.ReactMessageCommand) error {
+	return s.uow.Do(ctx, func(ctx context.Context, repos MessageRepos) error {
+		msg, err := s.findReactable(ctx, repos, params)
+		if err != nil {
+			return err
+		}
+
+		msg.RemoveReaction(entities.UserId(params.UserId), entities.EmoteId(params.EmoteId))
+		_, err = repos.Message().Save(ctx, msg)
+		return entities.GetErrOrDefault(err, entities.ErrCodeDepFail, "cannot remove reaction")
+	})
+}
+
+func (s *MessageService) findReactable(ctx context.Context, repos MessageRepos, params command.ReactMessageCommand) (*entities.Message, error) {
+	msg, err := repos.Message().Find(ctx, entities.MessageId(params.MessageId))
+	if err != nil {
+		return nil, entities.GetErrOrDefault(err, entities.ErrCodeDepFail, "cannot get message")
+	}
+	if msg.ChannelId == nil {
+		return nil, entities.NewError(entities.ErrCodeForbidden, "dm group not implemented", nil)
+	}
+	if err = s.checkChannelPermissions(ctx, params.UserId, *msg.ChannelId, entities.PermViewChannel, entities.PermAddReactions); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// checkChannelPermissions returns a Forbidden error unless the user holds
+// every perm in the channel, as the owner or an administrator always does
+func (s *MessageService) checkChannelPermissions(ctx context.Context, userId uuid.UUID, channelId entities.ChannelId, perms ...entities.ServerPermissionBits) error {
+	res, err := s.permissions.GetChannelPermissions(ctx, query.GetChannelPermissions{UserId: userId, ChannelId: uuid.UUID(channelId)})
+	if err != nil {
+		if derr, ok := err.(*entities.ChatError); ok && derr.Code == entities.ErrCodeNoObject {
+			return entities.NewError(entities.ErrCodeForbidden, "user cannot see the channel", nil)
+		}
+		return entities.GetErrOrDefault(err, entities.ErrCodeDepFail, "cannot get channel permissions")
+	}
+
+	if res.Owner || res.Permissions.HasAll(entities.PermAdministrator) || res.Permissions.HasAll(perms...) {
+		return nil
+	}
+	return entities.NewError(entities.ErrCodeForbidden, "missing channel permission", nil)
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"backend/internal/application/command"
+	"backend/internal/application/query"
+	"backend/internal/domain/entities"
+	"backend/internal/domain/repositories"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memMessageRepo struct {
+	repositories.MessageRepo
+	msgs  map[entities.MessageId]*entities.Message
+	saved int
+}
+
+func (r *memMessageRepo) Find(ctx context.Context, id entities.MessageId) (*entities.Message, error) {
+	if m, ok := r.msgs[id]; ok {
+		return m, nil
+	}
+	return nil, entities.NewError(entities.ErrCodeNoObject, "message not found", nil)
+}
+
+func (r *memMessageRepo) Save(ctx context.Context, msg *entities.Message) (*entities.Message, error) {
+	r.saved++
+	return msg, nil
+}
+
+type memMessageRepos struct {
+	MessageRepos
+	messages *memMessageRepo
+}
+
+func (r memMessageRepos) Message() repositories.MessageRepo { return r.messages }
+
+type memMessageUoW struct{ repos memMessageRepos }
+
+func (u memMessageUoW) Do(ctx context.Context, fn func(ctx context.Context, repos MessageRepos) error) error {
+	return fn(ctx, u.repos)
+}
+
+// fixedPermissions answers every channel with the same permissions
+type fixedPermissions struct {
+	res query.GetChannelPermissionsResult
+	err error
+}
+
+func (p fixedPermissions) GetChannelPermissions(ctx context.Context, params query.GetChannelPermissions) (query.GetChannelPermissionsResult, error) {
+	return p.res, p.err
+}
+
+func member(perms ...entities.ServerPermissionBits) fixedPermissions {
+	return fixedPermissions{res: query.GetChannelPermissionsResult{Permissions: entities.CreatePermission(perms...)}}
+}
+
+func errorCode(err error) entities.ChatErrorCode {
+	var derr *entities.ChatError
+	if errors.As(err, &derr) {
+		return derr.Code
+	}
+	if err != nil {
+		return "unknown"
+	}
+	return ""
+}
+
+func TestMessageServicePermissions(t *testing.T) {
+	author, other := uuid.New(), uuid.New()
+	channelId := entities.ChannelId(uuid.New())
+	groupId := entities.DMGroupId(uuid.New())
+
+	tests := []struct {
+		name     string
+		perms    fixedPermissions
+		inGroup  bool
+		run      func(s *MessageService, msgId uuid.UUID) error
+		wantCode entities.ChatErrorCode
+	}{
+		{
+			name:  "react with view and add reactions",
+			perms: member(entities.PermViewChannel, entities.PermAddReactions),
+			run: func(s *MessageService, msgId uuid.UUID) error {
+				return s.AddReaction(context.Background(), command.ReactMessageCommand{MessageId: msgId, UserId: other, EmoteId: uuid.New()})
+			},
+		},
+		{
+			name:  "remove a reaction with view and add reactions",
+			perms: member(entities.PermViewChannel, entities.PermAddReactions),
+			run: func(s *MessageService, msgId uuid.UUID) error {
+				return s.RemoveReaction(context.Background(), command.ReactMessageCommand{MessageId: msgId, UserId: other, EmoteId: uuid.New()})
+			},
+		},
+		{
+			name:  "react without add reactions",
+			perms: member(entities.PermViewChannel),
+			run: func(s *MessageService, msgId uuid.UUID) error {
+				return s.AddReaction(context.Background(), command.ReactMessageCommand{MessageId: msgId, UserId: other, EmoteId: uuid.New()})
+			},
+			wantCode: entities.ErrCodeForbidden,
+		},
+		{
+			name:  "react without view channel",
+			perms: member(entities.PermAddReactions),
+			run: func(s *MessageService, msgId uuid.UUID) error {
+				return s.AddReaction(context.Background(), command.ReactMessageCommand{MessageId: msgId, UserId: other, EmoteId: uuid.New()})
+			},
+			wantCode: entities.ErrCodeForbidden,
+		},
+		{
+			name:  "remove a reaction without view channel",
+			perms: member(entities.PermAddReactions),
+			run: func(s *MessageService, msgId uuid.UUID) error {
+				return s.RemoveReaction(context.Background(), command.ReactMessageCommand{MessageId: msgId, UserId: other, EmoteId: uuid.New()})
+			},
+			wantCode: entities.ErrCodeForbidden,
+		},
+		{
+			name:  "react outside the server",
+			perms: fixedPermissions{err: entities.NewError(entities.ErrCodeNoObject, "user not in the channel's server", nil)},
+			run: func(s *MessageService, msgId uuid.UUID) error {
+				return s.AddReaction(context.Background(), command.ReactMessageCommand{MessageId: msgId, UserId: other, EmoteId: uuid.New()})
+			},
+			wantCode: entities.ErrCodeForbidden,
+		},
+		{
+			name:  "permissions unavailable",
+			perms: fixedPermissions{err: entities.NewError(entities.ErrCodeDepFail, "cannot get membership", nil)},
+			run: func(s *MessageService, msgId uuid.UUID) error {
+				return s.AddReaction(context.Background(), command.ReactMessageCommand{MessageId: msgId, UserId: other, EmoteId: uuid.New()})
+			},
+			wantCode: entities.ErrCodeDepFail,
+		},
+		{
+			name:  "owner reacts without roles",
+			perms: fixedPermissions{res: query.GetChannelPermissionsResult{Owner: true}},
+			run: func(s *MessageService, msgId uuid.UUID) error {
+				return s.AddReaction(context.Background(), command.ReactMessageCommand{MessageId: msgId, UserId: other, EmoteId: uuid.New()})
+			},
+		},
+		{
+			name:  "administrator reacts",
+			perms: member(entities.PermAdministrator),
+			run: func(s *MessageService, msgId uuid.UUID) error {
+				return s.AddReaction(context.Background(), command.ReactMessageCommand{MessageId: msgId, UserId: other, EmoteId: uuid.New()})
+			},
+		},
+		{
+			name:    "react in a dm group",
+			perms:   member(entities.PermAdministrator),
+			inGroup: true,
+			run: func(s *MessageService, msgId uuid.UUID) error {
+				return s.AddReaction(context.Background(), command.ReactMessageCommand{MessageId: msgId, UserId: other, EmoteId: uuid.New()})
+			},
+			wantCode: entities.ErrCodeForbidden,
+		},
+		{
+			name:  "author deletes",
+			perms: member(),
+			run: func(s *MessageService, msgId uuid.UUID) error {
+				return s.Delete(context.Background(), command.DeleteMessageCommand{MessageId: msgId, UserId: author})
+			},
+		},
+		{
+			name:  "moderator deletes",
+			perms: member(entities.PermViewChannel, entities.PermManageMessages),
+			run: func(s *MessageService, msgId uuid.UUID) error {
+				return s.Delete(context.Background(), command.DeleteMessageCommand{MessageId: msgId, UserId: other})
+			},
+		},
+		{
+			name:  "caller vouches for message management",
+			perms: member(),
+			run: func(s *MessageService, msgId uuid.UUID) error {
+				return s.Delete(context.Background(), command.DeleteMessageCommand{MessageId: msgId, UserId: other, HasMessageManagement: true})
+			},
+		},
+		{
+			name:  "member deletes someone else's message",
+			perms: member(entities.PermViewChannel, entities.PermSendMessage),
+			run: func(s *MessageService, msgId uuid.UUID) error {
+				return s.Delete(context.Background(), command.DeleteMessageCommand{MessageId: msgId, UserId: other})
+			},
+			wantCode: entities.ErrCodeForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authorId := entities.UserId(author)
+			msg := &entities.Message{Id: entities.MessageId(uuid.New()), CreatedAt: time.Now(), Author: &authorId, Message: "hello"}
+			if tt.inGroup {
+				msg.GroupId = &groupId
+			} else {
+				msg.ChannelId = &channelId
+			}
+			repo := &memMessageRepo{msgs: map[entities.MessageId]*entities.Message{msg.Id: msg}}
+			s := &MessageService{uow: memMessageUoW{memMessageRepos{messages: repo}}, permissions: tt.perms}
+
+			err := tt.run(s, uuid.UUID(msg.Id))
+			if got := errorCode(err); got != tt.wantCode {
+				t.Fatalf("err = %v, want code %q", err, tt.wantCode)
+			}
+			if saved := repo.saved > 0; saved != (tt.wantCode == "") {
+				t.Errorf("message saved = %t, want %t", saved, tt.wantCode == "")
+			}
+		})
+	}
+}
@@ -130,7 +130,7 @@ func (s *ServerService) Delete(ctx context.Context, param command.DeleteServerCo
 			return entities.GetErrOrDefault(err, entities.ErrCodeDepFail, "cannot get server")
 		}
 
-		if server.Owner != entities.UserId(param.UserId) {
+		if !param.Force && !server.IsOwner(entities.UserId(param.UserId)) {
 			return entities.NewError(entities.ErrCodeForbidden, "user is not the owner of the server", nil)
 		}
 
@@ -140,6 +140,32 @@ func (s *ServerService) Delete(ctx context.Context, param command.DeleteServerCo
 	})
 }
 
+func (s *ServerService) TransferOwnership(ctx context.Context, param command.TransferServerCommand) error {
+	return s.uow.Do(ctx, func(ctx context.Context, repos ServerRepos) error {
+		server, err := repos.Server().Find(ctx, entities.ServerId(param.ServerId))
+		if err != nil {
+			return entities.GetErrOrDefault(err, entities.ErrCodeDepFail, "cannot get server")
+		}
+
+		if !param.Force && !server.IsOwner(entities.UserId(param.UserId)) {
+			return entities.NewError(entities.ErrCodeForbidden, "user is not the owner of the server", nil)
+		}
+
+		_, err = repos.Member().Find(ctx, entities.UserId(param.NewOwner), server.Id)
+		if derr, ok := err.(*entities.ChatError); ok && derr.Code == entities.ErrCodeNoObject {
+			return entities.NewError(entities.ErrCodeValidationError, "new owner is not a member of the server", err)
+		} else if err != nil {
+			return entities.GetErrOrDefault(err, entities.ErrCodeDepFail, "cannot get membership")
+		}
+
+		if err = server.TransferOwnership(entities.UserId(param.NewOwner)); err != nil {
+			return err
+		}
+		_, err = repos.Server().Save(ctx, server)
+		return entities.GetErrOrDefault(err, entities.ErrCodeDepFail, "cannot transfer server")
+	})
+}
+
 func (s *ServerService) UpsertRole(context.Context, command.UpsertRoleCommand) (command.UpsertRoleCommandResult, error) {
 	return command.UpsertRoleCommandResult{}, nil
 }
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"backend/internal/application/command"
+	"backend/internal/domain/entities"
+	"backend/internal/domain/repositories"
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+type memServerRepo struct {
+	repositories.ServerRepo
+	servers map[entities.ServerId]*entities.Server
+	saves   int
+}
+
+func (r *memServerRepo) Find(ctx context.Context, id entities.ServerId) (*entities.Server, error) {
+	if s, ok := r.servers[id]; ok {
+		return s, nil
+	}
+	return nil, entities.NewError(entities.ErrCodeNoObject, "server not found", nil)
+}
+
+func (r *memServerRepo) Save(ctx context.Context, server *entities.Server) (*entities.Server, error) {
+	r.saves++
+	r.servers[server.Id] = server
+	return server, nil
+}
+
+type memMemberRepo struct {
+	repositories.MemberRepo
+	members map[entities.ServerId][]entities.UserId
+}
+
+func (r memMemberRepo) Find(ctx context.Context, userId entities.UserId, serverId entities.ServerId) (*entities.Membership, error) {
+	for _, id := range r.members[serverId] {
+		if id == userId {
+			return entities.NewMembership(serverId, userId, ""), nil
+		}
+	}
+	return nil, entities.NewError(entities.ErrCodeNoObject, "member not found", nil)
+}
+
+type memServerRepos struct {
+	ServerRepos
+	servers *memServerRepo
+	members memMemberRepo
+}
+
+func (r memServerRepos) Server() repositories.ServerRepo { return r.servers }
+func (r memServerRepos) Member() repositories.MemberRepo { return r.members }
+
+type memServerUoW struct{ repos memServerRepos }
+
+func (u memServerUoW) Do(ctx context.Context, fn func(ctx context.Context, repos ServerRepos) error) error {
+	return fn(ctx, u.repos)
+}
+
+func TestTransferOwnership(t *testing.T) {
+	owner, member, stranger := uuid.New(), uuid.New(), uuid.New()
+
+	tests := []struct {
+		name      string
+		cmd       func(serverId uuid.UUID) command.TransferServerCommand
+		wantCode  entities.ChatErrorCode
+		wantOwner uuid.UUID
+		wantEvent bool
+	}{
+		{
+			name: "owner to a member",
+			cmd: func(serverId uuid.UUID) command.TransferServerCommand {
+				return command.TransferServerCommand{UserId: owner, ServerId: serverId, NewOwner: member}
+			},
+			wantOwner: member,
+			wantEvent: true,
+		},
+		{
+			name: "owner to a non-member",
+			cmd: func(serverId uuid.UUID) command.TransferServerCommand {
+				return command.TransferServerCommand{UserId: owner, ServerId: serverId, NewOwner: stranger}
+			},
+			wantCode:  entities.ErrCodeValidationError,
+			wantOwner: owner,
+		},
+		{
+			name: "owner to self",
+			cmd: func(serverId uuid.UUID) command.TransferServerCommand {
+				return command.TransferServerCommand{UserId: owner, ServerId: serverId, NewOwner: owner}
+			},
+			wantOwner: owner,
+		},
+		{
+			name: "member who does not own it",
+			cmd: func(serverId uuid.UUID) command.TransferServerCommand {
+				return command.TransferServerCommand{UserId: member, ServerId: serverId, NewOwner: member}
+			},
+			wantCode:  entities.ErrCodeForbidden,
+			wantOwner: owner,
+		},
+		{
+			name: "operator to a member",
+			cmd: func(serverId uuid.UUID) command.TransferServerCommand {
+				return command.TransferServerCommand{ServerId: serverId, NewOwner: member, Force: true}
+			},
+			wantOwner: member,
+			wantEvent: true,
+		},
+		{
+			name: "operator to a non-member",
+			cmd: func(serverId uuid.UUID) command.TransferServerCommand {
+				return command.TransferServerCommand{ServerId: serverId, NewOwner: stranger, Force: true}
+			},
+			wantCode:  entities.ErrCodeValidationError,
+			wantOwner: owner,
+		},
+		{
+			name: "unknown server",
+			cmd: func(uuid.UUID) command.TransferServerCommand {
+				return command.TransferServerCommand{UserId: owner, ServerId: uuid.New(), NewOwner: member}
+			},
+			wantCode:  entities.ErrCodeNoObject,
+			wantOwner: owner,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, err := entities.NewServer(entities.UserId(owner), "general", "", "", "", false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			server.PullsEvents()
+			servers := &memServerRepo{servers: map[entities.ServerId]*entities.Server{server.Id: server}}
+			members := memMemberRepo{members: map[entities.ServerId][]entities.UserId{
+				server.Id: {entities.UserId(owner), entities.UserId(member)},
+			}}
+			s := NewServerService(memServerUoW{memServerRepos{servers: servers, members: members}})
+
+			err = s.TransferOwnership(context.Background(), tt.cmd(uuid.UUID(server.Id)))
+			if code := errorCode(err); code != tt.wantCode {
+				t.Fatalf("err = %v, want code %q", err, tt.wantCode)
+			}
+			if server.Owner != entities.UserId(tt.wantOwner) {
+				t.Errorf("owner = %s, want %s", server.Owner, tt.wantOwner)
+			}
+			if err != nil && servers.saves != 0 {
+				t.Errorf("server saved %d times after a refused transfer", servers.saves)
+			}
+
+			var changed bool
+			for _, e := range server.PullsEvents() {
+				changed = changed || e.GetBase().EventType == entities.EventServerOwnerChanged
+			}
+			if changed != tt.wantEvent {
+				t.Errorf("owner changed event recorded = %v, want %v", changed, tt.wantEvent)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"backend/internal/application/command"
+	"backend/internal/application/interfaces"
+	"backend/internal/application/mapper"
+	"backend/internal/domain/entities"
+	"backend/internal/domain/repositories"
+	"context"
+	"strings"
+	"time"
+)
+
+type UserRepos interface {
+	User() repositories.UserRepo
+	Session() repositories.SessionRepo
+}
+
+type UserService struct {
+	uow repositories.UnitOfWork[UserRepos]
+}
+
+func NewUserService(uow repositories.UnitOfWork[UserRepos]) interfaces.UserService {
+	return &UserService{uow}
+}
+
+func (s *UserService) Create(ctx context.Context, cmd command.CreateUserCommand) (res command.CreateUserCommandResult, err error) {
+	password, err := hashPassword(cmd.Password)
+	if err != nil {
+		return res, err
+	}
+
+	user := entities.NewUser(entities.NewUserParam{
+		Username:    strings.ToLower(cmd.Username),
+		Email:       strings.ToLower(cmd.Email),
+		DisplayName: cmd.Username,
+		Password:    password,
+		Flags:       cmd.Flags,
+	})
+	user.Verified = cmd.Verified
+	err = user.Validate()
+	if err != nil {
+		return res, entities.GetErrOrDefault(err, entities.ErrCodeValidationError, "validation failed")
+	}
+
+	err = s.uow.Do(ctx, func(ctx context.Context, repos UserRepos) error {
+		return repos.User().Save(ctx, user)
+	})
+	if err != nil {
+		return res, entities.GetErrOrDefault(err, entities.ErrCodeDepFail, "cannot save user")
+	}
+
+	return command.CreateUserCommandResult{
+		Result: mapper.NewUserResultFromUserEntity(user),
+	}, nil
+}
+
+func (s *UserService) Update(ctx context.Context, cmd command.UpdateUserCommand) (res command.UpdateUserCommandResult, err error) {
+	err = s.uow.Do(ctx, func(ctx context.Context, repos UserRepos) error {
+		user, err := repos.User().Find(ctx, entities.UserId(cmd.UserId))
+		if err != nil {
+			return entities.GetErrOrDefault(err, entities.ErrCodeDepFail, "cannot get user")
+		}
+
+		wasDisabled := user.Disabled
+		err = user.Update(entities.UpdateUserParam{
+			Disabled: cmd.Disabled,
+			Verified: cmd.Verified,
+			Flags:    cmd.Flags,
+		})
+		if err != nil {
+			return entities.GetErrOrDefault(err, entities.ErrCodeValidationError, "validation failed")
+		}
+
+		if err = repos.User().Save(ctx, user); err != nil {
+			return entities.GetErrOrDefault(err, entities.ErrCodeDepFail, "cannot save user")
+		}
+
+		// Access tokens run out on their own, a disabled user cannot refresh
+		// them. The ws gateway checks Disabled when a connection authenticates,
+		// connections already open stay until they drop.
+		if user.Disabled && !wasDisabled {
+			if _, err = s.revokeSessions(ctx, repos, user.Id); err != nil {
+				return err
+			}
+		}
+
+		res = command.UpdateUserCommandResult{
+			Result: mapper.NewUserResultFromUserEntity(user),
+		}
+		return nil
+	})
+
+	return res, err
+}
+
+func (s *UserService) RevokeSessions(ctx context.Context, cmd command.RevokeSessionsCommand) (res command.RevokeSessionsCommandResult, err error) {
+	err = s.uow.Do(ctx, func(ctx context.Context, repos UserRepos) error {
+		res.Revoked, err = s.revokeSessions(ctx, repos, entities.UserId(cmd.UserId))
+		return err
+	})
+
+	return res, err
+}
+
+func (s *UserService) revokeSessions(ctx context.Context, repos UserRepos, userId entities.UserId) (int, error) {
+	sessions, err := repos.Session().FindByUserId(ctx, userId)
+	if err != nil {
+		return 0, entities.GetErrOrDefault(err, entities.ErrCodeDepFail, "cannot get sessions")
+	}
+
+	now := time.Now()
+	for _, session := range sessions {
+		session.ExpiresAt = now
+		if err = repos.Session().Save(ctx, session); err != nil {
+			return 0, entities.GetErrOrDefault(err, entities.ErrCodeDepFail, "cannot revoke session")
+		}
+	}
+	return len(sessions), nil
+}
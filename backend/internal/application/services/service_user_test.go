@@ -0,0 +1,151 @@
+package services
+
+import (
+	"backend/internal/application/command"
+	"backend/internal/domain/entities"
+	"backend/internal/domain/repositories"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memSessionRepo struct {
+	repositories.SessionRepo
+	sessions []*entities.Session
+}
+
+func (r *memSessionRepo) FindByUserId(ctx context.Context, userId entities.UserId) ([]*entities.Session, error) {
+	var res []*entities.Session
+	for _, s := range r.sessions {
+		if s.UserId == userId {
+			res = append(res, s)
+		}
+	}
+	return res, nil
+}
+
+func (r *memSessionRepo) Save(ctx context.Context, session *entities.Session) error {
+	return nil // sessions are shared with the test
+}
+
+type memUserRepos struct {
+	UserRepos
+	users    memUserRepo
+	sessions *memSessionRepo
+}
+
+func (r memUserRepos) User() repositories.UserRepo       { return r.users }
+func (r memUserRepos) Session() repositories.SessionRepo { return r.sessions }
+
+type memUserUoW struct{ repos memUserRepos }
+
+func (u memUserUoW) Do(ctx context.Context, fn func(ctx context.Context, repos UserRepos) error) error {
+	return fn(ctx, u.repos)
+}
+
+func TestUserServiceRevokesSessions(t *testing.T) {
+	yes, no := true, false
+
+	tests := []struct {
+		name         string
+		disabled     bool // before the call
+		call         func(s *UserService, userId uuid.UUID) (revoked int, err error)
+		wantCode     entities.ChatErrorCode
+		wantRevoked  int
+		wantExpired  bool
+		wantDisabled bool
+	}{
+		{
+			name: "disabling revokes every session",
+			call: func(s *UserService, userId uuid.UUID) (int, error) {
+				_, err := s.Update(context.Background(), command.UpdateUserCommand{UserId: userId, Disabled: &yes})
+				return 0, err
+			},
+			wantExpired:  true,
+			wantDisabled: true,
+		},
+		{
+			name:     "disabling a disabled user again",
+			disabled: true,
+			call: func(s *UserService, userId uuid.UUID) (int, error) {
+				_, err := s.Update(context.Background(), command.UpdateUserCommand{UserId: userId, Disabled: &yes})
+				return 0, err
+			},
+			wantDisabled: true,
+		},
+		{
+			name:     "enabling keeps sessions",
+			disabled: true,
+			call: func(s *UserService, userId uuid.UUID) (int, error) {
+				_, err := s.Update(context.Background(), command.UpdateUserCommand{UserId: userId, Disabled: &no})
+				return 0, err
+			},
+		},
+		{
+			name: "verifying keeps sessions",
+			call: func(s *UserService, userId uuid.UUID) (int, error) {
+				_, err := s.Update(context.Background(), command.UpdateUserCommand{UserId: userId, Verified: &yes})
+				return 0, err
+			},
+		},
+		{
+			name:     "revoking the sessions of a disabled user",
+			disabled: true,
+			call: func(s *UserService, userId uuid.UUID) (int, error) {
+				res, err := s.RevokeSessions(context.Background(), command.RevokeSessionsCommand{UserId: userId})
+				return res.Revoked, err
+			},
+			wantRevoked:  2,
+			wantExpired:  true,
+			wantDisabled: true,
+		},
+		{
+			name: "unknown user",
+			call: func(s *UserService, userId uuid.UUID) (int, error) {
+				_, err := s.Update(context.Background(), command.UpdateUserCommand{UserId: uuid.New(), Disabled: &yes})
+				return 0, err
+			},
+			wantCode: entities.ErrCodeNoObject,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := entities.NewUser(entities.NewUserParam{Username: "alice", Email: "alice@example.com", DisplayName: "alice", Password: "hash"})
+			user.Disabled = tt.disabled
+			expiresAt := time.Now().Add(time.Hour)
+			sessions := &memSessionRepo{sessions: []*entities.Session{
+				{Id: uuid.New(), UserId: user.Id, ExpiresAt: expiresAt},
+				{Id: uuid.New(), UserId: user.Id, ExpiresAt: expiresAt},
+			}}
+			other := &entities.Session{Id: uuid.New(), UserId: entities.UserId(uuid.New()), ExpiresAt: expiresAt}
+			sessions.sessions = append(sessions.sessions, other)
+
+			s := &UserService{memUserUoW{memUserRepos{
+				users:    memUserRepo{users: map[entities.UserId]*entities.User{user.Id: user}},
+				sessions: sessions,
+			}}}
+
+			revoked, err := tt.call(s, uuid.UUID(user.Id))
+			if code := errorCode(err); code != tt.wantCode {
+				t.Fatalf("err = %v, want code %q", err, tt.wantCode)
+			}
+			if revoked != tt.wantRevoked {
+				t.Errorf("revoked %d sessions, want %d", revoked, tt.wantRevoked)
+			}
+			if user.Disabled != tt.wantDisabled {
+				t.Errorf("disabled = %v, want %v", user.Disabled, tt.wantDisabled)
+			}
+			for _, session := range sessions.sessions[:2] {
+				if expired := session.ExpiresAt.Before(expiresAt); expired != tt.wantExpired {
+					t.Errorf("session expired = %v, want %v", expired, tt.wantExpired)
+				}
+			}
+			if !other.ExpiresAt.Equal(expiresAt) {
+				t.Error("the session of another user was revoked")
+			}
+		})
+	}
+}
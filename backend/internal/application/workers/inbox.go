@@ -0,0 +1,57 @@
+package workers
+
+import (
+	"backend/internal/application/ports"
+	"backend/internal/domain/repositories"
+	"context"
+	"log/slog"
+
+	"github.com/google/uuid"
+)
+
+type InboxRepos interface {
+	Inbox() repositories.InboxRepo
+}
+
+// Inbox drops redelivered events. The event is recorded in the same
+// transaction as the writes of the handler it wraps, as long as the handler
+// writes through a unit of work on the ctx it is given, so the handler takes
+// effect exactly once.
+type Inbox struct {
+	uow repositories.UnitOfWork[InboxRepos]
+}
+
+func NewInbox(uow repositories.UnitOfWork[InboxRepos]) *Inbox {
+	return &Inbox{uow}
+}
+
+// Wrap runs handler at most once per event_id header for consumer. A replayed
+// event carries the id it was first delivered with, it is recorded apart for
+// each replay run so that a replay reaches the handler again.
+func (i *Inbox) Wrap(consumer string, handler func(context.Context, ports.EventMessage) error) func(context.Context, ports.EventMessage) error {
+	return func(ctx context.Context, event ports.EventMessage) error {
+		raw, _ := event.Headers["event_id"].(string)
+		eventId, err := uuid.Parse(raw)
+		if err != nil {
+			slog.Default().Warn("Event without event_id, cannot deduplicate", "consumer", consumer, "eventType", event.EventType)
+			return handler(ctx, event)
+		}
+
+		key := consumer
+		if run, _ := event.Headers[ports.HEADER_REPLAY_RUN].(string); run != "" {
+			key = consumer + "/replay/" + run
+		}
+
+		return i.uow.Do(ctx, func(ctx context.Context, repos InboxRepos) error {
+			first, err := repos.Inbox().MarkProcessed(ctx, key, eventId)
+			if err != nil {
+				return err
+			}
+			if !first {
+				slog.Default().Info("Skipping already processed event", "consumer", key, "eventId", eventId)
+				return nil
+			}
+			return handler(ctx, event)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package workers
+
+import (
+	"backend/internal/application/ports"
+	"backend/internal/domain/repositories"
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+type memInbox map[string]bool
+
+func (m memInbox) MarkProcessed(ctx context.Context, consumer string, eventId uuid.UUID) (bool, error) {
+	key := consumer + "|" + eventId.String()
+	if m[key] {
+		return false, nil
+	}
+	m[key] = true
+	return true, nil
+}
+
+type memInboxRepos struct{ inbox memInbox }
+
+func (r memInboxRepos) Inbox() repositories.InboxRepo { return r.inbox }
+
+type memInboxUoW struct{ repos memInboxRepos }
+
+func (u memInboxUoW) Do(ctx context.Context, fn func(ctx context.Context, repos InboxRepos) error) error {
+	return fn(ctx, u.repos)
+}
+
+func TestInboxWrap(t *testing.T) {
+	eventId := uuid.New().String()
+	live := ports.EventMessage{Headers: map[string]any{"event_id": eventId}}
+	replayOf := func(run string) ports.EventMessage {
+		return ports.EventMessage{Headers: map[string]any{
+			"event_id":              eventId,
+			ports.HEADER_REPLAY:     "true",
+			ports.HEADER_REPLAY_RUN: run,
+		}}
+	}
+	noRun := ports.EventMessage{Headers: map[string]any{"event_id": eventId, ports.HEADER_REPLAY: "true"}}
+	noId := ports.EventMessage{Headers: map[string]any{}}
+
+	tests := []struct {
+		name       string
+		deliveries []ports.EventMessage
+		want       int
+	}{
+		{name: "redelivery is dropped", deliveries: []ports.EventMessage{live, live}, want: 1},
+		{name: "replay reaches the handler again", deliveries: []ports.EventMessage{live, replayOf("a")}, want: 2},
+		{name: "redelivery within a run is dropped", deliveries: []ports.EventMessage{live, replayOf("a"), replayOf("a")}, want: 2},
+		{name: "every run is handled", deliveries: []ports.EventMessage{replayOf("a"), replayOf("b")}, want: 2},
+		{name: "replay without a run keeps the live key", deliveries: []ports.EventMessage{live, noRun}, want: 1},
+		{name: "event without an id is not deduplicated", deliveries: []ports.EventMessage{noId, noId}, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inbox := NewInbox(memInboxUoW{memInboxRepos{memInbox{}}})
+			handled := 0
+			handler := inbox.Wrap("test.consumer", func(ctx context.Context, event ports.EventMessage) error {
+				handled++
+				return nil
+			})
+
+			for _, event := range tt.deliveries {
+				if err := handler(context.Background(), event); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if handled != tt.want {
+				t.Errorf("handled %d times, want %d", handled, tt.want)
+			}
+		})
+	}
+}
@@ -30,7 +30,7 @@ func (w *announcementWorker) SendJoinMessageHandler(ctx context.Context, event p
 		},
 	))
 
-	m, err := events.ParseSpecificEvent[entities.MembershipCreated](event.Payload, entities.EventMembershipCreated, entities.MembershipCreatedSchemaVersion)
+	m, err := events.ParseLatest[entities.MembershipCreated](event.Payload, entities.EventMembershipCreated)
 	if err != nil {
 		slog.Default().Warn("Unabled to parse event", "error", err)
 		return err
@@ -41,8 +41,9 @@ func (w *announcementWorker) SendJoinMessageHandler(ctx context.Context, event p
 		Content:  ("<@" + m.UserID.String() + "> joined the server!"),
 	})
 	if err != nil {
+		// Rolls back the inbox record too, so the retry is not skipped
 		slog.Default().Warn("Unabled to send message", "error", err)
 	}
 
-	return nil
+	return err
 }
@@ -4,14 +4,80 @@ import (
 	"backend/internal/application/interfaces"
 	"backend/internal/application/ports"
 	"backend/internal/domain/entities"
+	"context"
+	"errors"
 	"log/slog"
 )
 
+type Handler func(context.Context, ports.EventMessage) error
+
+// Consumer is a named set of handlers. Its name keys its inbox records, so
+// each consumer handles an event once, and selects it for a replay.
+type Consumer struct {
+	Name     string
+	Handlers map[string]Handler // event type -> handler
+	// Replayable consumers also handle the events a replay republishes, the
+	// others drop them. Leave it false for a consumer with effects outside
+	// what it derives, e.g. one that posts messages.
+	Replayable bool
+}
+
+// Consumers lists every consumer run by the workers.
+func Consumers(messageSvc interfaces.MessageService) []Consumer {
+	announcements := &announcementWorker{messageSvc}
+	return []Consumer{
+		{
+			Name: "announcement.join_message",
+			Handlers: map[string]Handler{
+				entities.EventMembershipCreated: announcements.SendJoinMessageHandler,
+			},
+		},
+	}
+}
+
 // Recommend a dedicated event subscriber for this
-func NewWorker(messageSvc interfaces.MessageService, eventReader ports.EventSubscriber) error {
-	if err := eventReader.Subscribe(entities.EventMembershipCreated, (&announcementWorker{messageSvc}).SendJoinMessageHandler); err != nil {
-		return err
+func NewWorker(messageSvc interfaces.MessageService, inbox *Inbox, eventReader ports.EventSubscriber) error {
+	// A subscriber holds one handler per topic, consumers sharing a topic
+	// are run one after the other and their inboxes skip those done already
+	byTopic := map[string][]Handler{}
+	for _, consumer := range Consumers(messageSvc) {
+		for topic, handler := range consumer.Handlers {
+			if !consumer.Replayable {
+				handler = dropReplays(consumer.Name, handler)
+			}
+			byTopic[topic] = append(byTopic[topic], inbox.Wrap(consumer.Name, handler))
+		}
+	}
+
+	for topic, handlers := range byTopic {
+		if err := eventReader.Subscribe(topic, fanOut(handlers)); err != nil {
+			return err
+		}
 	}
 	slog.Info("Attach worker to event subscriber successfully")
 	return nil
 }
+
+func fanOut(handlers []Handler) Handler {
+	if len(handlers) == 1 {
+		return handlers[0]
+	}
+	return func(ctx context.Context, event ports.EventMessage) error {
+		var errs []error
+		for _, handler := range handlers {
+			errs = append(errs, handler(ctx, event))
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// dropReplays keeps the events a replay republishes from reaching handler.
+func dropReplays(consumer string, handler Handler) Handler {
+	return func(ctx context.Context, event ports.EventMessage) error {
+		if event.IsReplay() {
+			slog.Default().Debug("Dropping replayed event", "consumer", consumer, "eventType", event.EventType)
+			return nil
+		}
+		return handler(ctx, event)
+	}
+}
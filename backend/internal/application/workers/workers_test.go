@@ -0,0 +1,82 @@
+package workers
+
+import (
+	"backend/internal/application/command"
+	"backend/internal/application/interfaces"
+	"backend/internal/application/ports"
+	"backend/internal/domain/entities"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// countingMessages counts the system messages the workers post
+type countingMessages struct {
+	interfaces.MessageService
+	posted int
+}
+
+func (m *countingMessages) CreateSystemMessage(ctx context.Context, params command.CreateSystemMessageCommand) error {
+	m.posted++
+	return nil
+}
+
+type recordingSubscriber struct {
+	handlers map[string]func(context.Context, ports.EventMessage) error
+}
+
+func (s *recordingSubscriber) Subscribe(topic string, handler func(context.Context, ports.EventMessage) error) error {
+	s.handlers[topic] = handler
+	return nil
+}
+
+func (s *recordingSubscriber) Close() error { return nil }
+
+func TestWorkersDropReplays(t *testing.T) {
+	payload, err := json.Marshal(entities.NewMembershipCreated(&entities.Membership{
+		Id:       entities.MembershipId(uuid.New()),
+		ServerId: entities.ServerId(uuid.New()),
+		UserId:   entities.UserId(uuid.New()),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	joined := func(headers map[string]any) ports.EventMessage {
+		headers["event_id"] = uuid.NewString()
+		return ports.EventMessage{EventType: entities.EventMembershipCreated, Payload: payload, Headers: headers}
+	}
+
+	tests := []struct {
+		name  string
+		event ports.EventMessage
+		want  int
+	}{
+		{name: "live event posts the join message", event: joined(map[string]any{}), want: 1},
+		{name: "republished replay is dropped", event: joined(map[string]any{ports.HEADER_REPLAY: "true", ports.HEADER_REPLAY_RUN: uuid.NewString()}), want: 0},
+		{name: "replay without a run is dropped", event: joined(map[string]any{ports.HEADER_REPLAY: "true"}), want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := &countingMessages{}
+			sub := &recordingSubscriber{handlers: map[string]func(context.Context, ports.EventMessage) error{}}
+			inbox := NewInbox(memInboxUoW{memInboxRepos{memInbox{}}})
+			if err := NewWorker(messages, inbox, sub); err != nil {
+				t.Fatal(err)
+			}
+
+			handler, ok := sub.handlers[entities.EventMembershipCreated]
+			if !ok {
+				t.Fatalf("no handler subscribed to %s", entities.EventMembershipCreated)
+			}
+			if err := handler(context.Background(), tt.event); err != nil {
+				t.Fatal(err)
+			}
+			if messages.posted != tt.want {
+				t.Errorf("posted %d join messages, want %d", messages.posted, tt.want)
+			}
+		})
+	}
+}
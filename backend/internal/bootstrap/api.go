@@ -0,0 +1,115 @@
+package bootstrap
+
+import (
+	_ "backend/docs"
+	"backend/internal/application/services"
+	"backend/internal/application/workers"
+	"backend/internal/domain/repositories"
+	"backend/internal/infra/bus"
+	"backend/internal/infra/config"
+	"backend/internal/infra/db/postgres"
+	rabbitmq "backend/internal/infra/rabbitMQ"
+	"backend/internal/interface/asyncapi"
+	"backend/internal/interface/health"
+	"backend/internal/interface/rest"
+	"backend/internal/interface/ws"
+	"backend/internal/processes/relayer"
+	"context"
+	"fmt"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/cors"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	httpSwagger "github.com/swaggo/http-swagger/v2"
+)
+
+// API wires the REST api and its event workers. On the memory bus it also
+// relays the outbox, no separate relayer can reach an in-process bus.
+func API(ctx context.Context, cfg *config.Config, pgPool *pgxpool.Pool, eventBus *bus.Bus) (*Service, error) {
+	eventSub, err := eventBus.Subscriber(ctx, "api_workers", cfg.API.Workers)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create a new event sub: %w", err)
+	}
+
+	checker := health.New().
+		Add("postgres", pgPool.Ping).
+		Add("event_bus", eventBus.Ping)
+
+	if cfg.EventBus.Backend == bus.BACKEND_MEMORY {
+		// Nor can other instances share its shards
+		relayerConfig := cfg.Relayer.Config
+		relayerConfig.ShardCount = 0
+		inProcessRelayer := relayer.New(postgres.NewPGOutboxReader(pgPool), eventBus.Publisher(), nil, postgres.NewPGOutboxListener(pgPool), relayerConfig)
+		go inProcessRelayer.Run(ctx)
+		checker.Add("relayer", health.Recent(inProcessRelayer.LastClaim, cfg.API.RelayerMaxClaimAge))
+		prometheus.MustRegister(postgres.NewOutboxCollector(pgPool))
+	}
+
+	uow := postgres.NewBaseUoW(pgPool)
+
+	// ---------- Services ----------
+	authService := services.NewAuthService(postgres.NewScopedUoW(uow, func(rb repositories.RepoBundle) services.AuthRepos {
+		return rb
+	}), cfg.Auth)
+	serverService := services.NewServerService(postgres.NewScopedUoW(uow, func(rb repositories.RepoBundle) services.ServerRepos { return rb }))
+	invitationService := services.NewInvitationService(postgres.NewScopedUoW(uow, func(rb repositories.RepoBundle) services.InvitationRepos { return rb }))
+	membershipService := services.NewMemberService(postgres.NewScopedUoW(uow, func(rb repositories.RepoBundle) services.MemberRepos { return rb }))
+	channelService := services.NewChannelService(postgres.NewScopedUoW(uow, func(rb repositories.RepoBundle) services.ChannelRepos { return rb }))
+	messageService := services.NewMessageService(postgres.NewScopedUoW(uow, func(rb repositories.RepoBundle) services.MessageRepos { return rb }), postgres.NewPGPermissionQueries(pgPool))
+
+	// ---------- Queries ----------
+	serverQueries := postgres.NewPGServerQueries(pgPool)
+	inviteQueries := services.NewInvitationQueries(postgres.NewScopedUoW(uow, func(rb repositories.RepoBundle) services.InvitationRepos { return rb }))
+	messageQueries := postgres.NewPGMessageQueries(pgPool)
+	channelQueries := services.NewChannelQueries(postgres.NewScopedUoW(uow, func(rb repositories.RepoBundle) services.ChannelRepos { return rb }))
+	userQueries := postgres.NewPGUserQueries(pgPool)
+
+	inbox := workers.NewInbox(postgres.NewScopedUoW(uow, func(rb repositories.RepoBundle) workers.InboxRepos { return rb }))
+	if err = workers.NewWorker(messageService, inbox, eventSub); err != nil {
+		return nil, fmt.Errorf("cannot attach workers to event sub: %w", err)
+	}
+
+	port := cfg.API.Port
+
+	r := chi.NewRouter()
+	r.Use(rest.TracingMiddleware)
+	r.Use(rest.MetricsMiddleware)
+	r.Use(cors.Handler(cors.Options{
+		AllowedOrigins:   cfg.API.CORSOrigins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
+		ExposedHeaders:   []string{"Link"},
+		AllowCredentials: false,
+		MaxAge:           300,
+	}))
+
+	checker.Register(r)
+
+	r.Route("/api/v1", func(r chi.Router) {
+		docsHandler := httpSwagger.Handler(httpSwagger.URL(fmt.Sprintf("http://localhost:%v/api/v1/docs/doc.json", port)))
+		r.Get("/docs/*", docsHandler)
+		r.Get("/docs/asyncapi.json", asyncapi.Handler(asyncapi.Build(asyncapi.Info{
+			Title:       "Noncord events",
+			Version:     "1.0",
+			Description: "Domain events published on the event bus, with every schema version still accepted, and the frames of the WebSocket gateway.",
+		}, append(asyncapi.DomainEvents(rabbitmq.EXCHANGE_NAME), ws.Gateway())...)))
+
+		rest.NewAuthController(authService).RegisterRoute(r)
+		rest.NewServerController(authService, serverService, serverQueries, invitationService, inviteQueries).RegisterRoute(r)
+		rest.NewInvitationController(serverQueries, authService, invitationService, inviteQueries, membershipService).RegisterRoute(r)
+		rest.NewMessageController(messageService, messageQueries, authService).RegisterRoute(r)
+		rest.NewChannelController(authService, channelService, channelQueries).RegisterRoute(r)
+		rest.NewUserController(authService, userQueries).RegisterRoute(r)
+	})
+
+	return &Service{
+		Name:            "api",
+		Port:            port,
+		Handler:         r,
+		MetricsPort:     cfg.API.MetricsPort,
+		ShutdownTimeout: cfg.API.ShutdownTimeout,
+		// Requests are drained, let the workers finish the events they are handling
+		Close: eventSub.Close,
+	}, nil
+}
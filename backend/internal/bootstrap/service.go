@@ -0,0 +1,70 @@
+// Package bootstrap wires the services on top of a database pool and an
+// event bus, so cmd/api and cmd/ws run them on their own while cmd/noncord
+// runs them side by side in one process.
+package bootstrap
+
+import (
+	"backend/internal/infra/telemetry"
+	"backend/internal/interface/rest"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type Service struct {
+	Name    string
+	Port    string
+	Handler http.Handler
+	// MetricsPort serves /metrics apart from Handler so that it never faces
+	// clients, none when empty.
+	MetricsPort string
+
+	// ShutdownTimeout bounds the drain of requests in flight and the
+	// OnShutdown hooks, which run alongside, e.g. to close hijacked websockets.
+	ShutdownTimeout time.Duration
+	OnShutdown      []func(context.Context) error
+
+	// Close releases what the service opened, once requests are drained, e.g.
+	// lets its event handlers finish.
+	Close func() error
+}
+
+// Serve listens on the service port, and the metrics port, until ctx is done,
+// then drains them.
+func (s *Service) Serve(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	metricsDone := make(chan struct{})
+	if s.MetricsPort != "" {
+		go func() {
+			defer close(metricsDone)
+			s.serveMetrics(ctx)
+		}()
+	} else {
+		close(metricsDone)
+	}
+	defer func() {
+		// The service may stop on its own, the metrics listener goes with it
+		cancel()
+		<-metricsDone
+	}()
+
+	srv := &http.Server{Addr: fmt.Sprintf(":%v", s.Port), Handler: s.Handler}
+	err := rest.Serve(ctx, srv, s.ShutdownTimeout, s.OnShutdown...)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func (s *Service) serveMetrics(ctx context.Context) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", telemetry.MetricsHandler())
+
+	srv := &http.Server{Addr: fmt.Sprintf(":%v", s.MetricsPort), Handler: mux}
+	if err := rest.Serve(ctx, srv, 5*time.Second); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("Cannot serve metrics", "service", s.Name, "port", s.MetricsPort, "err", err)
+	}
+}
@@ -0,0 +1,83 @@
+package bootstrap
+
+import (
+	"backend/internal/application/services"
+	"backend/internal/domain/repositories"
+	"backend/internal/infra/bus"
+	"backend/internal/infra/cache/inmemcache"
+	"backend/internal/infra/config"
+	"backend/internal/infra/db/postgres"
+	"backend/internal/interface/health"
+	"backend/internal/interface/rest"
+	"backend/internal/interface/ws"
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/cors"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WS wires the websocket and SSE gateway.
+func WS(ctx context.Context, cfg *config.Config, pgPool *pgxpool.Pool, eventBus *bus.Bus) (*Service, error) {
+	uow := postgres.NewBaseUoW(pgPool)
+
+	visiblityQueries := services.NewVisibilityQueries(postgres.NewScopedUoW(uow, func(rb repositories.RepoBundle) services.PermissionRepos { return rb }))
+	authService := services.NewAuthService(postgres.NewScopedUoW(uow, func(rb repositories.RepoBundle) services.AuthRepos { return rb }), cfg.Auth)
+	messageService := services.NewMessageService(postgres.NewScopedUoW(uow, func(rb repositories.RepoBundle) services.MessageRepos { return rb }), postgres.NewPGPermissionQueries(pgPool))
+
+	userResolver := postgres.NewPGNicknameResolver(pgPool)
+	cacheStore := inmemcache.NewInMemoryCache(cfg.WS.NicknameCacheTTL, cfg.WS.NicknameCacheCleanup)
+
+	// Every ws instance needs every event since connected users are spread across instances,
+	// a single worker keeps them in the order clients expect
+	eventSub, err := eventBus.BroadcastSubscriber(ctx, "websocket", 1)
+	if err != nil {
+		return nil, fmt.Errorf("cannot subscribe to the event bus: %w", err)
+	}
+
+	wsHub, err := ws.NewHub(ctx, cfg.WS.Config, authService, messageService, visiblityQueries, eventSub, cacheStore, userResolver)
+	if err != nil {
+		return nil, fmt.Errorf("cannot start the hub: %w", err)
+	}
+
+	r := chi.NewRouter()
+	r.Use(rest.TracingMiddleware)
+	r.Use(cors.Handler(cors.Options{
+		AllowedOrigins:   cfg.WS.CORSOrigins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "Last-Event-ID"},
+		ExposedHeaders:   []string{"Link"},
+		AllowCredentials: false,
+		MaxAge:           300,
+	}))
+
+	prometheus.MustRegister(ws.NewStatsCollector(wsHub))
+	health.New().
+		Add("postgres", pgPool.Ping).
+		Add("event_bus", eventBus.Ping).
+		Register(r)
+
+	r.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		ws.ServeWs(wsHub, authService, w, r)
+	})
+	r.Get("/sse", func(w http.ResponseWriter, r *http.Request) {
+		ws.ServeSSE(wsHub, authService, w, r)
+	})
+	r.Post("/sse/ticket", func(w http.ResponseWriter, r *http.Request) {
+		ws.IssueSSETicket(wsHub, authService, w, r)
+	})
+
+	return &Service{
+		Name:            "ws",
+		Port:            cfg.WS.Port,
+		Handler:         r,
+		MetricsPort:     cfg.WS.MetricsPort,
+		ShutdownTimeout: cfg.WS.ShutdownTimeout,
+		// Websockets are hijacked from the server, the hub closes them itself
+		OnShutdown: []func(context.Context) error{wsHub.Shutdown},
+		Close:      eventSub.Close,
+	}, nil
+}
@@ -75,15 +75,19 @@ func NewMessageCreated(m *Message) MessageCreated {
 
 type MessageEdited struct {
 	events.Base
-	Old string `json:"old"`
-	New string `json:"new"`
+	ChannelID *uuid.UUID `json:"channel_id,omitempty"`
+	GroupID   *uuid.UUID `json:"group_id,omitempty"`
+	Old       string     `json:"old"`
+	New       string     `json:"new"`
 }
 
 func NewMessageEdited(m *Message, old string) MessageEdited {
 	return MessageEdited{
-		Base: events.NewBase("message", uuid.UUID(m.Id), EventMessageEdited, MessageEditedSchemaVersion),
-		Old:  old,
-		New:  m.Message,
+		Base:      events.NewBase("message", uuid.UUID(m.Id), EventMessageEdited, MessageEditedSchemaVersion),
+		ChannelID: (*uuid.UUID)(m.ChannelId),
+		GroupID:   (*uuid.UUID)(m.GroupId),
+		Old:       old,
+		New:       m.Message,
 	}
 }
 
@@ -101,7 +105,9 @@ func NewMessageAttachmentRemoved(m *Message, attID AttachmentId) MessageAttachme
 
 type MessageDeleted struct {
 	events.Base
-	DeletedAt time.Time `json:"deleted_at"`
+	ChannelID *uuid.UUID `json:"channel_id,omitempty"`
+	GroupID   *uuid.UUID `json:"group_id,omitempty"`
+	DeletedAt time.Time  `json:"deleted_at"`
 }
 
 func NewMessageDeleted(m *Message) MessageDeleted {
@@ -111,35 +117,45 @@ func NewMessageDeleted(m *Message) MessageDeleted {
 	}
 	return MessageDeleted{
 		Base:      events.NewBase("message", uuid.UUID(m.Id), EventMessageDeleted, MessageDeletedSchemaVersion),
+		ChannelID: (*uuid.UUID)(m.ChannelId),
+		GroupID:   (*uuid.UUID)(m.GroupId),
 		DeletedAt: deletedAt,
 	}
 }
 
 type MessageReactionAdded struct {
 	events.Base
-	UserID  uuid.UUID `json:"user_id"`
-	EmoteID uuid.UUID `json:"emote_id"`
+	ChannelID *uuid.UUID `json:"channel_id,omitempty"`
+	GroupID   *uuid.UUID `json:"group_id,omitempty"`
+	UserID    uuid.UUID  `json:"user_id"`
+	EmoteID   uuid.UUID  `json:"emote_id"`
 }
 
 func NewMessageReactionAdded(m *Message, userID UserId, emoteID EmoteId) MessageReactionAdded {
 	return MessageReactionAdded{
-		Base:    events.NewBase("message", uuid.UUID(m.Id), EventMessageReactionAdded, MessageReactionAddedSchemaVersion),
-		UserID:  uuid.UUID(userID),
-		EmoteID: uuid.UUID(emoteID),
+		Base:      events.NewBase("message", uuid.UUID(m.Id), EventMessageReactionAdded, MessageReactionAddedSchemaVersion),
+		ChannelID: (*uuid.UUID)(m.ChannelId),
+		GroupID:   (*uuid.UUID)(m.GroupId),
+		UserID:    uuid.UUID(userID),
+		EmoteID:   uuid.UUID(emoteID),
 	}
 }
 
 type MessageReactionRemoved struct {
 	events.Base
-	UserID  uuid.UUID `json:"user_id"`
-	EmoteID uuid.UUID `json:"emote_id"`
+	ChannelID *uuid.UUID `json:"channel_id,omitempty"`
+	GroupID   *uuid.UUID `json:"group_id,omitempty"`
+	UserID    uuid.UUID  `json:"user_id"`
+	EmoteID   uuid.UUID  `json:"emote_id"`
 }
 
 func NewMessageReactionRemoved(m *Message, userID UserId, emoteID EmoteId) MessageReactionRemoved {
 	return MessageReactionRemoved{
-		Base:    events.NewBase("message", uuid.UUID(m.Id), EventMessageReactionRemoved, MessageReactionRemovedSchemaVersion),
-		UserID:  uuid.UUID(userID),
-		EmoteID: uuid.UUID(emoteID),
+		Base:      events.NewBase("message", uuid.UUID(m.Id), EventMessageReactionRemoved, MessageReactionRemovedSchemaVersion),
+		ChannelID: (*uuid.UUID)(m.ChannelId),
+		GroupID:   (*uuid.UUID)(m.GroupId),
+		UserID:    uuid.UUID(userID),
+		EmoteID:   uuid.UUID(emoteID),
 	}
 }
 
@@ -294,6 +294,18 @@ func (s *Server) IsOwner(userId UserId) bool {
 	return userId == s.Owner
 }
 
+// TransferOwnership hands the server to another user, who must already be a
+// member, the caller checks it.
+func (s *Server) TransferOwnership(newOwner UserId) error {
+	if s.Owner != newOwner {
+		old := s.Owner
+		s.Owner = newOwner
+		s.UpdatedAt = time.Now()
+		s.Record(NewServerOwnerChanged(s, old))
+	}
+	return nil
+}
+
 func (s *Server) Delete() error {
 	now := time.Now()
 	s.DeletedAt = &now
@@ -15,6 +15,7 @@ const (
 	EventServerBannerURLUpdated           = "server.banner_url_updated"
 	EventServerNeedApprovalChanged        = "server.need_approval_changed"
 	EventServerAnnouncementChannelChanged = "server.announcement_channel_changed"
+	EventServerOwnerChanged               = "server.owner_changed"
 	EventServerDeleted                    = "server.deleted"
 	EventRoleCreated                      = "server.role.created"
 	EventRoleDeleted                      = "server.role.deleted"
@@ -30,6 +31,7 @@ const (
 	ServerBannerURLUpdatedSchemaVersion           = 1
 	ServerNeedApprovalChangedSchemaVersion        = 1
 	ServerAnnouncementChannelChangedSchemaVersion = 1
+	ServerOwnerChangedSchemaVersion               = 1
 	ServerDeletedSchemaVersion                    = 1
 	ServerRoleCreatedSchemaVersion                = 1
 	ServerRoleDeletedSchemaVersion                = 1
@@ -162,6 +164,20 @@ func NewServerAnnouncementChannelChanged(s *Server, old *ChannelId) ServerAnnoun
 	}
 }
 
+type ServerOwnerChanged struct {
+	events.Base
+	OldOwnerID uuid.UUID `json:"old_owner_id"`
+	NewOwnerID uuid.UUID `json:"new_owner_id"`
+}
+
+func NewServerOwnerChanged(s *Server, old UserId) ServerOwnerChanged {
+	return ServerOwnerChanged{
+		Base:       events.NewBase("server", uuid.UUID(s.Id), EventServerOwnerChanged, ServerOwnerChangedSchemaVersion),
+		OldOwnerID: uuid.UUID(old),
+		NewOwnerID: uuid.UUID(s.Owner),
+	}
+}
+
 type ServerDeleted struct {
 	events.Base
 	DeletedAt time.Time `json:"deletedAt"`
@@ -284,6 +300,7 @@ func init() {
 	events.Register(EventServerBannerURLUpdated, ServerBannerURLUpdatedSchemaVersion, func() events.DomainEvent { return ServerBannerURLUpdated{} })
 	events.Register(EventServerNeedApprovalChanged, ServerNeedApprovalChangedSchemaVersion, func() events.DomainEvent { return ServerNeedApprovalChanged{} })
 	events.Register(EventServerAnnouncementChannelChanged, ServerAnnouncementChannelChangedSchemaVersion, func() events.DomainEvent { return ServerAnnouncementChannelChanged{} })
+	events.Register(EventServerOwnerChanged, ServerOwnerChangedSchemaVersion, func() events.DomainEvent { return ServerOwnerChanged{} })
 	events.Register(EventServerDeleted, ServerDeletedSchemaVersion, func() events.DomainEvent { return ServerDeleted{} })
 	events.Register(EventRoleCreated, ServerRoleCreatedSchemaVersion, func() events.DomainEvent { return RoleCreated{} })
 	events.Register(EventRoleDeleted, ServerRoleDeletedSchemaVersion, func() events.DomainEvent { return RoleDeleted{} })
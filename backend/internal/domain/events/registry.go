@@ -1,15 +1,26 @@
 package events
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
 )
 
 type Factory func() DomainEvent
 
+// Upcaster turns the decoded payload of one schema version into the next
+// version. The registry bumps "schema_version" itself. Numbers are decoded as
+// json.Number so ids and counters keep their precision.
+type Upcaster func(payload map[string]any) (map[string]any, error)
+
 // event_type -> schema_version -> factory
 var reg = map[string]map[int]Factory{}
 
+// event_type -> from schema_version -> upcaster to schema_version+1
+var upcasters = map[string]map[int]Upcaster{}
+
 func Register(eventType string, schemaVersion int, f Factory) {
 	if reg[eventType] == nil {
 		reg[eventType] = make(map[int]Factory)
@@ -17,6 +28,69 @@ func Register(eventType string, schemaVersion int, f Factory) {
 	reg[eventType][schemaVersion] = f
 }
 
+// RegisterUpcaster registers the conversion of eventType payloads from
+// fromVersion to fromVersion+1. Register one per version bump, so payloads of
+// any older version still in the outbox or a queue reach the latest one.
+func RegisterUpcaster(eventType string, fromVersion int, u Upcaster) {
+	if upcasters[eventType] == nil {
+		upcasters[eventType] = make(map[int]Upcaster)
+	}
+	upcasters[eventType][fromVersion] = u
+}
+
+// Types returns every registered event type, sorted.
+func Types() []string {
+	return slices.Sorted(maps.Keys(reg))
+}
+
+// Versions returns the schema versions registered for eventType, oldest first.
+func Versions(eventType string) []int {
+	return slices.Sorted(maps.Keys(reg[eventType]))
+}
+
+// Latest returns the newest schema version registered for eventType.
+func Latest(eventType string) (int, bool) {
+	versions := Versions(eventType)
+	if len(versions) == 0 {
+		return 0, false
+	}
+	return versions[len(versions)-1], true
+}
+
+// Validate checks that every upcaster belongs to a registered event and that
+// each event type can be upcast from its oldest known version to the latest
+// one without a gap. Call it once at startup.
+func Validate() error {
+	for eventType, ups := range upcasters {
+		if _, ok := reg[eventType]; !ok {
+			return fmt.Errorf("upcaster registered for unknown event type %q", eventType)
+		}
+		latest, _ := Latest(eventType)
+		for from := range ups {
+			if from >= latest {
+				return fmt.Errorf("upcaster of %q from version %d goes past the latest version %d", eventType, from, latest)
+			}
+		}
+	}
+
+	for eventType, versions := range reg {
+		latest, _ := Latest(eventType)
+		oldest := latest
+		for v := range versions {
+			oldest = min(oldest, v)
+		}
+		for from := range upcasters[eventType] {
+			oldest = min(oldest, from)
+		}
+		for v := oldest; v < latest; v++ {
+			if _, ok := upcasters[eventType][v]; !ok {
+				return fmt.Errorf("no upcaster of %q from version %d to %d", eventType, v, v+1)
+			}
+		}
+	}
+	return nil
+}
+
 func New(eventType string, schemaVersion int) (DomainEvent, bool) {
 	if m, ok := reg[eventType]; ok {
 		if f, ok := m[schemaVersion]; ok {
@@ -32,6 +106,66 @@ func ParseEvent(payload []byte) (Base, error) {
 	return event, err
 }
 
+// Upcast brings payload to the latest schema version of its event type, a
+// payload already at the latest version is returned as is.
+func Upcast(payload []byte) ([]byte, error) {
+	base, err := ParseEvent(payload)
+	if err != nil {
+		return nil, err
+	}
+	latest, ok := Latest(base.EventType)
+	if !ok {
+		return nil, fmt.Errorf("event type not found")
+	}
+	if base.SchemaVersion == latest {
+		return payload, nil
+	}
+	if base.SchemaVersion > latest {
+		return nil, fmt.Errorf("event version %d is newer than the latest known version %d", base.SchemaVersion, latest)
+	}
+
+	var fields map[string]any
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	if err = dec.Decode(&fields); err != nil {
+		return nil, err
+	}
+	for v := base.SchemaVersion; v < latest; v++ {
+		up, ok := upcasters[base.EventType][v]
+		if !ok {
+			return nil, fmt.Errorf("no upcaster of %q from version %d", base.EventType, v)
+		}
+		if fields, err = up(fields); err != nil {
+			return nil, fmt.Errorf("upcast %q from version %d: %w", base.EventType, v, err)
+		}
+		fields["schema_version"] = v + 1
+	}
+	return json.Marshal(fields)
+}
+
+// ParseLatest parses payload of any known schema version of eventType into
+// the struct of its latest version. A payload of another event type is
+// rejected rather than upcast along its own chain.
+func ParseLatest[T DomainEvent](payload []byte, eventType string) (T, error) {
+	var e T
+	latest, ok := Latest(eventType)
+	if !ok {
+		return e, fmt.Errorf("event type not found")
+	}
+	base, err := ParseEvent(payload)
+	if err != nil {
+		return e, err
+	}
+	if base.EventType != eventType {
+		return e, fmt.Errorf("event type %q does not match %q", base.EventType, eventType)
+	}
+	payload, err = Upcast(payload)
+	if err != nil {
+		return e, err
+	}
+	return ParseSpecificEvent[T](payload, eventType, latest)
+}
+
 func ParseSpecificEvent[T DomainEvent](payload []byte, eventType string, schemaVersion int) (T, error) {
 	var e T
 	if _, ok := reg[eventType]; !ok {
@@ -0,0 +1,178 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const testRenamed = "test.renamed"
+
+// testRenamedV3 renamed title to name in v2 and added a tag in v3
+type testRenamedV3 struct {
+	Base
+	Name string `json:"name"`
+	Tag  string `json:"tag"`
+}
+
+type testRenamedV1 struct {
+	Base
+	Title string `json:"title"`
+}
+
+// withRegistry swaps the registry for an empty one during the test
+func withRegistry(t *testing.T) {
+	t.Helper()
+	savedReg, savedUpcasters := reg, upcasters
+	reg, upcasters = map[string]map[int]Factory{}, map[string]map[int]Upcaster{}
+	t.Cleanup(func() { reg, upcasters = savedReg, savedUpcasters })
+}
+
+func registerRenamed() {
+	Register(testRenamed, 1, func() DomainEvent { return testRenamedV1{} })
+	Register(testRenamed, 3, func() DomainEvent { return testRenamedV3{} })
+	RegisterUpcaster(testRenamed, 1, func(payload map[string]any) (map[string]any, error) {
+		payload["name"] = payload["title"]
+		delete(payload, "title")
+		return payload, nil
+	})
+	RegisterUpcaster(testRenamed, 2, func(payload map[string]any) (map[string]any, error) {
+		if payload["name"] == "" {
+			return nil, errors.New("empty name")
+		}
+		payload["tag"] = "default"
+		return payload, nil
+	})
+}
+
+func renamedPayload(version int, fields string) []byte {
+	return fmt.Appendf(nil, `{"type":%q,"schema_version":%d,%s}`, testRenamed, version, fields)
+}
+
+func TestParseLatest(t *testing.T) {
+	withRegistry(t)
+	registerRenamed()
+
+	tests := []struct {
+		name    string
+		payload []byte
+		want    testRenamedV3
+		wantErr string
+	}{
+		{name: "latest is parsed as is", payload: renamedPayload(3, `"name":"general","tag":"pinned"`), want: testRenamedV3{Name: "general", Tag: "pinned"}},
+		{name: "every step of the chain", payload: renamedPayload(1, `"title":"general"`), want: testRenamedV3{Name: "general", Tag: "default"}},
+		{name: "from the middle of the chain", payload: renamedPayload(2, `"name":"general"`), want: testRenamedV3{Name: "general", Tag: "default"}},
+		{name: "failing upcaster", payload: renamedPayload(1, `"title":""`), wantErr: "upcast \"test.renamed\" from version 2: empty name"},
+		{name: "newer than known", payload: renamedPayload(4, `"name":"general"`), wantErr: "newer than the latest known version 3"},
+		{name: "older than any upcaster", payload: renamedPayload(0, `"title":"general"`), wantErr: "no upcaster of \"test.renamed\" from version 0"},
+		{name: "another event type", payload: []byte(`{"type":"test.other","schema_version":1,"title":"general"}`), wantErr: `event type "test.other" does not match "test.renamed"`},
+		{name: "not json", payload: []byte(`{`), wantErr: "unexpected end of JSON input"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLatest[testRenamedV3](tt.payload, testRenamed)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Name != tt.want.Name || got.Tag != tt.want.Tag || got.SchemaVersion != 3 {
+				t.Errorf("got %+v, want %+v at version 3", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpcastBumpsEveryVersion(t *testing.T) {
+	withRegistry(t)
+	registerRenamed()
+
+	tests := []struct {
+		name  string
+		extra string
+	}{
+		{name: "small int", extra: "1"},
+		{name: "int past float64 precision", extra: "9007199254740993"},
+		{name: "max int64", extra: "9223372036854775807"},
+		{name: "decimal", extra: "1.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := Upcast(renamedPayload(1, `"title":"general","extra":`+tt.extra))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(payload, &fields); err != nil {
+				t.Fatal(err)
+			}
+			if string(fields["schema_version"]) != "3" || string(fields["extra"]) != tt.extra || fields["title"] != nil {
+				t.Errorf("upcast payload = %s", payload)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	noop := func(payload map[string]any) (map[string]any, error) { return payload, nil }
+	factory := func() DomainEvent { return testRenamedV3{} }
+
+	tests := []struct {
+		name     string
+		register func()
+		wantErr  string
+	}{
+		{name: "single version", register: func() { Register(testRenamed, 1, factory) }},
+		{name: "complete chain", register: registerRenamed},
+		{
+			name: "upcasters reaching back before the oldest factory",
+			register: func() {
+				Register(testRenamed, 3, factory)
+				RegisterUpcaster(testRenamed, 1, noop)
+				RegisterUpcaster(testRenamed, 2, noop)
+			},
+		},
+		{
+			name: "gap in the chain",
+			register: func() {
+				Register(testRenamed, 1, factory)
+				Register(testRenamed, 3, factory)
+				RegisterUpcaster(testRenamed, 1, noop)
+			},
+			wantErr: `no upcaster of "test.renamed" from version 2 to 3`,
+		},
+		{
+			name: "upcaster past the latest version",
+			register: func() {
+				Register(testRenamed, 1, factory)
+				RegisterUpcaster(testRenamed, 1, noop)
+			},
+			wantErr: "goes past the latest version 1",
+		},
+		{
+			name:     "upcaster of an unknown event",
+			register: func() { RegisterUpcaster("test.unknown", 1, noop) },
+			wantErr:  `unknown event type "test.unknown"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withRegistry(t)
+			tt.register()
+
+			err := Validate()
+			if (err != nil) != (tt.wantErr != "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Validate() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
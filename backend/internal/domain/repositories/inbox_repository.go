@@ -0,0 +1,13 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type InboxRepo interface {
+	// MarkProcessed records that consumer handled the event, it returns false
+	// when the event was already recorded.
+	MarkProcessed(ctx context.Context, consumer string, eventId uuid.UUID) (bool, error)
+}
@@ -0,0 +1,15 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type StreamTicketRepo interface {
+	// Redeem records the use of a stream ticket, it returns false when the
+	// ticket was already redeemed. Redemptions are forgotten once the ticket
+	// expired.
+	Redeem(ctx context.Context, ticketId uuid.UUID, expiresAt time.Time) (bool, error)
+}
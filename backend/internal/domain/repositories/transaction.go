@@ -11,11 +11,13 @@ type RepoBundle interface {
 	Channel() ChannelRepo
 	DMGroup() DMGroupRepo
 	Emote() EmoteRepo
+	Inbox() InboxRepo
 	Invitation() InvitationRepo
 	Member() MemberRepo
 	Message() MessageRepo
 	Server() ServerRepo
 	Session() SessionRepo
+	StreamTicket() StreamTicketRepo
 	UserNotification() UserNotificationRepo
 	User() UserRepo
 }
@@ -0,0 +1,209 @@
+package archive
+
+import (
+	"backend/internal/application/ports"
+	"bufio"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MAX_LINE_SIZE bounds one archived record when reading it back.
+const MAX_LINE_SIZE = 16 << 20
+
+// record is one NDJSON line, the payload is kept as raw JSON so archives stay
+// readable with zcat and jq.
+type record struct {
+	ID            uuid.UUID         `json:"id"`
+	AggregateName string            `json:"aggregate_name"`
+	AggregateID   uuid.UUID         `json:"aggregate_id"`
+	EventType     string            `json:"event_type"`
+	SchemaVersion int32             `json:"schema_version"`
+	OccurredAt    time.Time         `json:"occurred_at"`
+	Seq           int64             `json:"seq"`
+	Status        string            `json:"status"`
+	PublishedAt   *time.Time        `json:"published_at,omitempty"`
+	Payload       json.RawMessage   `json:"payload"`
+	TraceContext  map[string]string `json:"trace_context,omitempty"`
+}
+
+// NDJSONArchive writes gzip compressed NDJSON files partitioned by the UTC
+// day the events occurred, one file per day and written batch:
+//
+//	<dir>/2026/10/19/outbox-<first seq>-<last seq>.ndjson.gz
+type NDJSONArchive struct {
+	dir string
+}
+
+func NewNDJSONArchive(dir string) ports.EventArchive {
+	return &NDJSONArchive{dir}
+}
+
+func (a *NDJSONArchive) Write(ctx context.Context, records []ports.OutboxRecord) error {
+	var days []string
+	byDay := map[string][]ports.OutboxRecord{}
+	for _, rec := range records {
+		day := rec.OccurredAt.UTC().Format("2006/01/02")
+		if _, ok := byDay[day]; !ok {
+			days = append(days, day)
+		}
+		byDay[day] = append(byDay[day], rec)
+	}
+
+	for _, day := range days {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := a.writeFile(filepath.Join(a.dir, filepath.FromSlash(day)), byDay[day]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFile writes to a temporary file renamed once synced, so a crash never
+// leaves a truncated archive behind.
+func (a *NDJSONArchive) writeFile(dir string, records []ports.OutboxRecord) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("outbox-%d-%d.ndjson.gz", records[0].Seq, records[len(records)-1].Seq)
+	f, err := os.CreateTemp(dir, name+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	zw := gzip.NewWriter(f)
+	enc := json.NewEncoder(zw)
+	for _, rec := range records {
+		if err = enc.Encode(record{
+			ID:            rec.ID,
+			AggregateName: rec.AggregateName,
+			AggregateID:   rec.AggregateID,
+			EventType:     rec.EventType,
+			SchemaVersion: rec.SchemaVersion,
+			OccurredAt:    rec.OccurredAt,
+			Seq:           rec.Seq,
+			Status:        rec.Status,
+			PublishedAt:   rec.PublishedAt,
+			Payload:       rec.Payload,
+			TraceContext:  rec.TraceContext,
+		}); err != nil {
+			return err
+		}
+	}
+	if err = zw.Close(); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(dir, name))
+}
+
+func (a *NDJSONArchive) Files(ctx context.Context, since, until time.Time) ([]string, error) {
+	type file struct {
+		path     string
+		day      string
+		firstSeq int64
+	}
+	var files []file
+	err := filepath.WalkDir(a.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		var first, last int64
+		if _, err := fmt.Sscanf(d.Name(), "outbox-%d-%d.ndjson.gz", &first, &last); err != nil || !strings.HasSuffix(d.Name(), ".ndjson.gz") {
+			// Not an archive, e.g. a temporary file of a running purge
+			return nil
+		}
+		rel, err := filepath.Rel(a.dir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		day, err := time.Parse("2006/01/02", filepath.ToSlash(rel))
+		if err != nil {
+			return nil
+		}
+		// A day partition holds events from day to day+24h
+		if (!since.IsZero() && !day.AddDate(0, 0, 1).After(since)) || (!until.IsZero() && !day.Before(until)) {
+			return nil
+		}
+		files = append(files, file{path, day.Format(time.DateOnly), first})
+		return ctx.Err()
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(files, func(a, b file) int {
+		return cmp.Or(cmp.Compare(a.day, b.day), cmp.Compare(a.firstSeq, b.firstSeq))
+	})
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.path
+	}
+	return paths, nil
+}
+
+func (a *NDJSONArchive) Read(ctx context.Context, path string, fn func(ports.OutboxRecord) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	defer zr.Close()
+
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(make([]byte, 0, 64<<10), MAX_LINE_SIZE)
+	for line := 1; scanner.Scan(); line++ {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		var rec record
+		if err = json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if err = fn(ports.OutboxRecord{
+			ID:            rec.ID,
+			AggregateName: rec.AggregateName,
+			AggregateID:   rec.AggregateID,
+			EventType:     rec.EventType,
+			SchemaVersion: rec.SchemaVersion,
+			OccurredAt:    rec.OccurredAt,
+			Seq:           rec.Seq,
+			Status:        rec.Status,
+			PublishedAt:   rec.PublishedAt,
+			Payload:       rec.Payload,
+			TraceContext:  rec.TraceContext,
+		}); err != nil {
+			return err
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package archive
+
+import (
+	"backend/internal/application/ports"
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func outboxRecord(seq int64, occurredAt time.Time) ports.OutboxRecord {
+	publishedAt := occurredAt.Add(time.Second)
+	return ports.OutboxRecord{
+		ID:            uuid.New(),
+		AggregateName: "message",
+		AggregateID:   uuid.New(),
+		EventType:     "message.created",
+		SchemaVersion: 1,
+		OccurredAt:    occurredAt,
+		Seq:           seq,
+		Status:        "dispatched",
+		PublishedAt:   &publishedAt,
+		Payload:       []byte(`{"content":"hello"}`),
+		TraceContext:  map[string]string{"traceparent": fmt.Sprintf("00-%032x-%016x-01", seq, seq)},
+	}
+}
+
+func TestNDJSONArchiveRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2026, 10, 18, 23, 0, 0, 0, time.UTC)
+	first := []ports.OutboxRecord{
+		outboxRecord(1, day),
+		outboxRecord(2, day.Add(30*time.Minute)),
+		outboxRecord(3, day.Add(2*time.Hour)), // the next day
+	}
+	second := []ports.OutboxRecord{outboxRecord(4, day.Add(3*time.Hour))}
+	unpublished := outboxRecord(5, day.Add(48*time.Hour))
+	unpublished.PublishedAt = nil
+	unpublished.TraceContext = nil // written outside of a trace
+
+	dir := t.TempDir()
+	a := NewNDJSONArchive(dir)
+	for _, batch := range [][]ports.OutboxRecord{first, second, {unpublished}} {
+		if err := a.Write(ctx, batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Left behind by a purge that crashed, and a stranger
+	os.WriteFile(filepath.Join(dir, "2026", "10", "19", "outbox-9-9.ndjson.gz.tmp-1"), nil, 0o644)
+	os.WriteFile(filepath.Join(dir, "2026", "10", "19", "notes.txt"), nil, 0o644)
+
+	tests := []struct {
+		name         string
+		since, until time.Time
+		want         []ports.OutboxRecord
+	}{
+		{name: "everything, oldest first", want: []ports.OutboxRecord{first[0], first[1], first[2], second[0], unpublished}},
+		{name: "since a day", since: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC), want: []ports.OutboxRecord{first[2], second[0], unpublished}},
+		{name: "until a day", until: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), want: []ports.OutboxRecord{first[0], first[1]}},
+		{name: "nothing in range", since: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := a.Files(ctx, tt.since, tt.until)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []ports.OutboxRecord
+			for _, path := range files {
+				if err := a.Read(ctx, path, func(rec ports.OutboxRecord) error {
+					got = append(got, rec)
+					return nil
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("read %d records from %v, want %d", len(got), files, len(tt.want))
+			}
+			for i := range got {
+				assertSameRecord(t, got[i], tt.want[i])
+			}
+		})
+	}
+}
+
+func TestNDJSONArchiveMissingDir(t *testing.T) {
+	files, err := NewNDJSONArchive(filepath.Join(t.TempDir(), "none")).Files(context.Background(), time.Time{}, time.Time{})
+	if err != nil || len(files) != 0 {
+		t.Fatalf("Files = %v, %v, want no file and no error", files, err)
+	}
+}
+
+func assertSameRecord(t *testing.T, got, want ports.OutboxRecord) {
+	t.Helper()
+	if got.ID != want.ID || got.AggregateName != want.AggregateName || got.AggregateID != want.AggregateID ||
+		got.EventType != want.EventType || got.SchemaVersion != want.SchemaVersion || got.Seq != want.Seq || got.Status != want.Status {
+		t.Errorf("record %d = %+v, want %+v", want.Seq, got, want)
+	}
+	if !got.OccurredAt.Equal(want.OccurredAt) {
+		t.Errorf("record %d occurred at %s, want %s", want.Seq, got.OccurredAt, want.OccurredAt)
+	}
+	if (got.PublishedAt == nil) != (want.PublishedAt == nil) || (got.PublishedAt != nil && !got.PublishedAt.Equal(*want.PublishedAt)) {
+		t.Errorf("record %d published at %v, want %v", want.Seq, got.PublishedAt, want.PublishedAt)
+	}
+	if !bytes.Equal(got.Payload, want.Payload) {
+		t.Errorf("record %d payload = %s, want %s", want.Seq, got.Payload, want.Payload)
+	}
+	if !maps.Equal(got.TraceContext, want.TraceContext) {
+		t.Errorf("record %d trace context = %v, want %v", want.Seq, got.TraceContext, want.TraceContext)
+	}
+}
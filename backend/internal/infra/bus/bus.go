@@ -0,0 +1,150 @@
+package bus
+
+import (
+	"backend/internal/application/ports"
+	"backend/internal/infra/bus/inmembus"
+	"backend/internal/infra/db/postgres"
+	rabbitmq "backend/internal/infra/rabbitMQ"
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Backend string
+
+const (
+	// BACKEND_RABBITMQ relays the outbox to a RabbitMQ topic exchange.
+	BACKEND_RABBITMQ Backend = "rabbitmq"
+	// BACKEND_POSTGRES lets subscribers read the outbox directly, no broker
+	// and no relayer needed.
+	BACKEND_POSTGRES Backend = "postgres"
+	// BACKEND_MEMORY routes events inside one process, for tests and
+	// installs running every service in a single binary.
+	BACKEND_MEMORY Backend = "memory"
+)
+
+// Valid tells whether b is one of the known backends.
+func (b Backend) Valid() bool {
+	switch b {
+	case BACKEND_RABBITMQ, BACKEND_POSTGRES, BACKEND_MEMORY:
+		return true
+	}
+	return false
+}
+
+type Config struct {
+	Backend Backend `yaml:"backend" env:"EVENT_BUS"`
+	AMQPURI string  `yaml:"amqp_uri" env:"AMQP_URI"` // only used by RabbitMQ
+
+	PublisherChannels int `yaml:"publisher_channels" env:"RABBITMQ_PUBLISHER_CHANNELS"` // channels the relayer publishes on concurrently
+
+	// Subscriber settings of each backend, the worker count is given per
+	// subscriber by the service.
+	RabbitMQ rabbitmq.SubscriberConfig   `yaml:"rabbitmq"`
+	Postgres postgres.PGSubscriberConfig `yaml:"postgres"`
+	Memory   inmembus.Config             `yaml:"memory"`
+}
+
+var DefaultConfig = Config{
+	Backend:           BACKEND_RABBITMQ,
+	PublisherChannels: 4,
+	RabbitMQ:          rabbitmq.DefaultSubscriberConfig,
+	Postgres:          postgres.DefaultPGSubscriberConfig,
+	Memory:            inmembus.DefaultConfig,
+}
+
+type Bus struct {
+	Backend Backend
+
+	cfg  Config
+	pool *pgxpool.Pool
+	amqp *rabbitmq.Connection
+	mem  *inmembus.Bus
+}
+
+// Open connects to the backend of cfg.
+func Open(cfg Config, pool *pgxpool.Pool) (*Bus, error) {
+	b := &Bus{Backend: cfg.Backend, cfg: cfg, pool: pool}
+	switch cfg.Backend {
+	case BACKEND_RABBITMQ:
+		conn, err := rabbitmq.Dial(cfg.AMQPURI)
+		if err != nil {
+			return nil, err
+		}
+		b.amqp = conn
+	case BACKEND_MEMORY:
+		b.mem = inmembus.New(cfg.Memory)
+	case BACKEND_POSTGRES:
+	default:
+		return nil, fmt.Errorf("unknown event bus backend %q", cfg.Backend)
+	}
+	return b, nil
+}
+
+// NeedsRelayer tells whether outbox events have to be relayed to reach
+// subscribers.
+func (b *Bus) NeedsRelayer() bool {
+	return b.Backend != BACKEND_POSTGRES
+}
+
+// Publisher is what the relayer publishes to, nil for the postgres backend.
+func (b *Bus) Publisher() ports.EventPublisher {
+	switch b.Backend {
+	case BACKEND_RABBITMQ:
+		return rabbitmq.NewRMQEventPublisher(b.amqp, b.cfg.PublisherChannels)
+	case BACKEND_MEMORY:
+		return b.mem.Publisher()
+	}
+	return nil
+}
+
+// Subscriber is shared by every instance of serviceName, each event is
+// handled by one of them. workers bounds concurrent handlers where the
+// backend supports it, the postgres backend always handles events in order.
+func (b *Bus) Subscriber(ctx context.Context, serviceName string, workers int) (ports.EventSubscriber, error) {
+	switch b.Backend {
+	case BACKEND_RABBITMQ:
+		cfg := b.cfg.RabbitMQ
+		cfg.Workers = workers
+		return rabbitmq.NewRMQEventSubscriber(ctx, b.amqp, serviceName, rabbitmq.EXCHANGE_NAME, true, cfg)
+	case BACKEND_POSTGRES:
+		return postgres.NewPGEventSubscriber(ctx, b.pool, serviceName, b.cfg.Postgres)
+	default:
+		return b.mem.Subscriber(ctx, serviceName, workers), nil
+	}
+}
+
+// BroadcastSubscriber gets every event on every instance of serviceName.
+func (b *Bus) BroadcastSubscriber(ctx context.Context, serviceName string, workers int) (ports.EventSubscriber, error) {
+	switch b.Backend {
+	case BACKEND_RABBITMQ:
+		cfg := b.cfg.RabbitMQ
+		cfg.Workers = workers
+		return rabbitmq.NewRMQBroadcastSubscriber(ctx, b.amqp, serviceName, rabbitmq.EXCHANGE_NAME, cfg)
+	case BACKEND_POSTGRES:
+		return postgres.NewPGBroadcastSubscriber(ctx, b.pool, serviceName, b.cfg.Postgres)
+	default:
+		// Only one instance lives in the process, a plain queue is enough
+		return b.mem.Subscriber(ctx, serviceName, workers), nil
+	}
+}
+
+// Ping checks the broker can be reached, the postgres and memory backends
+// have nothing to check besides the pool.
+func (b *Bus) Ping(ctx context.Context) error {
+	if b.amqp != nil {
+		return b.amqp.Ping()
+	}
+	return nil
+}
+
+func (b *Bus) Close() error {
+	if b.mem != nil {
+		b.mem.Close()
+	}
+	if b.amqp != nil {
+		return b.amqp.Close()
+	}
+	return nil
+}
@@ -0,0 +1,380 @@
+package inmembus
+
+import (
+	"backend/internal/application/ports"
+	"backend/internal/infra/telemetry"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const HEADER_RETRY_COUNT = "x-retry-count"
+
+// ErrRetryOverflow is wrapped by the error of a message dead lettered because
+// MaxPendingRetries messages were already waiting to be retried.
+var ErrRetryOverflow = errors.New("too many pending retries")
+
+type Handler func(context.Context, ports.EventMessage) error
+
+type Config struct {
+	QueueSize int `yaml:"queue_size" env:"MEMORY_BUS_QUEUE_SIZE"` // buffered messages per queue before Publish blocks
+	// RetryDelays holds the delay before each retry of a failed message, a
+	// message that failed len(RetryDelays)+1 times is dead lettered.
+	RetryDelays []time.Duration `yaml:"retry_delays" env:"MEMORY_BUS_RETRY_DELAYS"`
+	// DeadLetterSize is how many dead letters are kept, the oldest is dropped
+	// to make room for a new one past that.
+	DeadLetterSize int `yaml:"dead_letter_size" env:"MEMORY_BUS_DEAD_LETTER_SIZE"`
+	// MaxPendingRetries bounds the failed messages waiting for their retry
+	// across the bus, a message failing past that is dead lettered at once.
+	MaxPendingRetries int `yaml:"max_pending_retries" env:"MEMORY_BUS_MAX_PENDING_RETRIES"`
+}
+
+var DefaultConfig = Config{
+	QueueSize:         1024,
+	RetryDelays:       []time.Duration{100 * time.Millisecond, time.Second, 10 * time.Second},
+	DeadLetterSize:    1000,
+	MaxPendingRetries: 1024,
+}
+
+type DeadLetter struct {
+	Queue   string
+	Message ports.EventMessage
+	Err     error
+}
+
+// Bus routes events between publishers and subscribers of the same process,
+// mirroring the topic exchange of the RabbitMQ backend. Subscribers sharing a
+// queue name compete for its messages, each queue gets its own copy.
+type Bus struct {
+	cfg Config
+
+	// ctx is done once the bus is closed, releasing the pending retries
+	ctx            context.Context
+	cancel         context.CancelFunc
+	pendingRetries atomic.Int64
+
+	mu     sync.RWMutex
+	queues map[string]*queue
+
+	deadMu      sync.Mutex
+	deadLetters []DeadLetter // ring of DeadLetterSize, deadNext is the oldest once full
+	deadNext    int
+	deadDropped uint64
+}
+
+func New(cfg Config) *Bus {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Bus{cfg: cfg, ctx: ctx, cancel: cancel, queues: make(map[string]*queue)}
+}
+
+// Close drops the messages waiting for a retry, subscribers are closed on
+// their own.
+func (b *Bus) Close() {
+	b.cancel()
+}
+
+func (b *Bus) Publisher() ports.EventPublisher {
+	return &publisher{b}
+}
+
+// Subscriber joins the queue named queueName, workers handlers of this
+// subscriber run concurrently until ctx is done or it is closed.
+func (b *Bus) Subscriber(ctx context.Context, queueName string, workers int) ports.EventSubscriber {
+	b.mu.Lock()
+	q, ok := b.queues[queueName]
+	if !ok {
+		q = &queue{
+			name:     queueName,
+			bus:      b,
+			bindings: make(map[string]Handler),
+			msgs:     make(chan delivery, b.cfg.QueueSize),
+			done:     make(chan struct{}),
+		}
+		b.queues[queueName] = q
+	}
+	q.subscribers++
+	b.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &subscriber{q: q, cancel: cancel}
+	for range max(workers, 1) {
+		s.workers.Add(1)
+		go func() {
+			defer s.workers.Done()
+			q.work(ctx)
+		}()
+	}
+	return s
+}
+
+// DeadLetters returns the last DeadLetterSize messages that used up their
+// retries, oldest first.
+func (b *Bus) DeadLetters() []DeadLetter {
+	b.deadMu.Lock()
+	defer b.deadMu.Unlock()
+	return slices.Concat(b.deadLetters[b.deadNext:], b.deadLetters[:b.deadNext])
+}
+
+// DeadLettersDropped returns how many dead letters were dropped to keep
+// DeadLetterSize.
+func (b *Bus) DeadLettersDropped() uint64 {
+	b.deadMu.Lock()
+	defer b.deadMu.Unlock()
+	return b.deadDropped
+}
+
+// reserveRetry takes one of the MaxPendingRetries slots, release gives it back.
+func (b *Bus) reserveRetry() bool {
+	if b.pendingRetries.Add(1) > int64(b.cfg.MaxPendingRetries) {
+		b.pendingRetries.Add(-1)
+		return false
+	}
+	return true
+}
+
+func (b *Bus) releaseRetry() {
+	b.pendingRetries.Add(-1)
+}
+
+func (b *Bus) leave(q *queue) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if q.subscribers--; q.subscribers == 0 {
+		delete(b.queues, q.name)
+		close(q.done)
+	}
+}
+
+func (b *Bus) deadLetter(q *queue, msg ports.EventMessage, err error) {
+	slog.Warn("Event dead lettered", "queue", q.name, "eventType", msg.EventType, "err", err)
+	b.deadMu.Lock()
+	defer b.deadMu.Unlock()
+	if len(b.deadLetters) < b.cfg.DeadLetterSize {
+		b.deadLetters = append(b.deadLetters, DeadLetter{q.name, msg, err})
+		return
+	}
+	if b.cfg.DeadLetterSize > 0 {
+		b.deadLetters[b.deadNext] = DeadLetter{q.name, msg, err}
+		b.deadNext = (b.deadNext + 1) % len(b.deadLetters)
+	}
+	b.deadDropped++
+	telemetry.DeadLetterDropped(q.name)
+}
+
+type publisher struct {
+	bus *Bus
+}
+
+func (p *publisher) Publish(ctx context.Context, msg ports.EventMessage) error {
+	p.bus.mu.RLock()
+	var targets []*queue
+	for _, q := range p.bus.queues {
+		if q.routes(msg.EventType) {
+			targets = append(targets, q)
+		}
+	}
+	p.bus.mu.RUnlock()
+
+	if len(targets) == 0 {
+		return ports.ErrUnroutable
+	}
+	for _, q := range targets {
+		copied := msg
+		copied.Headers = maps.Clone(msg.Headers)
+		if err := q.enqueue(ctx, delivery{copied, 0}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *publisher) PublishBatch(ctx context.Context, msgs []ports.EventMessage) []error {
+	errs := make([]error, len(msgs))
+	for i, msg := range msgs {
+		errs[i] = p.Publish(ctx, msg)
+	}
+	return errs
+}
+
+// Close leaves the bus running, it is shared with the subscribers.
+func (p *publisher) Close(ctx context.Context) error {
+	return nil
+}
+
+type delivery struct {
+	msg     ports.EventMessage
+	attempt int
+}
+
+type queue struct {
+	name string
+	bus  *Bus
+
+	mu          sync.RWMutex
+	bindings    map[string]Handler // topic pattern -> handler
+	subscribers int                // guarded by bus.mu
+
+	msgs chan delivery
+	done chan struct{} // closed once the last subscriber left
+}
+
+func (q *queue) bind(topic string, handler Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.bindings[topic] = handler
+}
+
+func (q *queue) unbind(topics []string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, topic := range topics {
+		delete(q.bindings, topic)
+	}
+}
+
+func (q *queue) routes(eventType string) bool {
+	return q.handler(eventType) != nil
+}
+
+func (q *queue) handler(eventType string) Handler {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if h, ok := q.bindings[eventType]; ok {
+		return h
+	}
+	for pattern, h := range q.bindings {
+		if matchTopic(pattern, eventType) {
+			return h
+		}
+	}
+	return nil
+}
+
+func (q *queue) enqueue(ctx context.Context, d delivery) error {
+	select {
+	case q.msgs <- d:
+		return nil
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *queue) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.done:
+			return
+		case d := <-q.msgs:
+			q.handle(ctx, d)
+		}
+	}
+}
+
+func (q *queue) handle(ctx context.Context, d delivery) {
+	handler := q.handler(d.msg.EventType)
+	if handler == nil {
+		slog.Warn("Event don't have handler", "queue", q.name, "topic", d.msg.EventType)
+		return
+	}
+
+	// A handler that started runs to the end even when the subscriber closes
+	err := telemetry.Consume(context.WithoutCancel(ctx), q.name, d.msg, handler)
+	if err == nil {
+		return
+	}
+	slog.Warn("Event handler failed", "queue", q.name, "topic", d.msg.EventType, "err", err)
+
+	deadLettered := d.attempt >= len(q.bus.cfg.RetryDelays)
+	if !deadLettered && !q.bus.reserveRetry() {
+		telemetry.RetryOverflow(q.name)
+		err = fmt.Errorf("%w: %w", ErrRetryOverflow, err)
+		deadLettered = true
+	}
+	telemetry.Retried(q.name, d.msg.EventType, deadLettered)
+	if deadLettered {
+		q.bus.deadLetter(q, d.msg, err)
+		return
+	}
+	delay := q.bus.cfg.RetryDelays[d.attempt]
+	d.attempt++
+	if d.msg.Headers == nil {
+		d.msg.Headers = map[string]any{}
+	}
+	d.msg.Headers[HEADER_RETRY_COUNT] = int32(d.attempt)
+	time.AfterFunc(delay, func() {
+		defer q.bus.releaseRetry()
+		q.enqueue(q.bus.ctx, d)
+	})
+}
+
+type subscriber struct {
+	q       *queue
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+
+	mu     sync.Mutex
+	topics []string
+	closed bool
+}
+
+func (s *subscriber) Subscribe(topic string, handler func(context.Context, ports.EventMessage) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.q.bind(topic, handler)
+	s.topics = append(s.topics, topic)
+	return nil
+}
+
+// Close stops taking messages and waits for the handlers already running.
+func (s *subscriber) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+
+	s.cancel()
+	s.q.unbind(s.topics)
+	s.q.bus.leave(s.q)
+	s.mu.Unlock()
+
+	s.workers.Wait()
+	return nil
+}
+
+// matchTopic matches a routing key against an AMQP style topic pattern, "*"
+// stands for one dot separated word and "#" for zero or more.
+func matchTopic(pattern, key string) bool {
+	return matchWords(strings.Split(pattern, "."), strings.Split(key, "."))
+}
+
+func matchWords(pattern, key []string) bool {
+	if len(pattern) == 0 {
+		return len(key) == 0
+	}
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(key); i++ {
+			if matchWords(pattern[1:], key[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(key) > 0 && matchWords(pattern[1:], key[1:])
+	default:
+		return len(key) > 0 && pattern[0] == key[0] && matchWords(pattern[1:], key[1:])
+	}
+}
@@ -0,0 +1,203 @@
+package inmembus
+
+import (
+	"backend/internal/application/ports"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errHandler = errors.New("handler failed")
+
+// eventually polls cond until it holds or a second went by
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRetryAndDeadLetter(t *testing.T) {
+	tests := []struct {
+		name         string
+		retries      int
+		failures     int // attempts failing before the handler succeeds
+		wantAttempts int
+		wantDead     bool
+	}{
+		{name: "success", retries: 2, failures: 0, wantAttempts: 1},
+		{name: "succeeds on a retry", retries: 2, failures: 2, wantAttempts: 3},
+		{name: "retries used up", retries: 2, failures: 10, wantAttempts: 3, wantDead: true},
+		{name: "no retries", retries: 0, failures: 10, wantAttempts: 1, wantDead: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			b := New(Config{QueueSize: 8, RetryDelays: make([]time.Duration, tt.retries), DeadLetterSize: 8, MaxPendingRetries: 8})
+			var mu sync.Mutex
+			var retryCounts []any
+			sub := b.Subscriber(ctx, "test", 1)
+			defer sub.Close()
+			sub.Subscribe("message.*", func(ctx context.Context, msg ports.EventMessage) error {
+				mu.Lock()
+				defer mu.Unlock()
+				retryCounts = append(retryCounts, msg.Headers[HEADER_RETRY_COUNT])
+				if len(retryCounts) <= tt.failures {
+					return errHandler
+				}
+				return nil
+			})
+
+			if err := b.Publisher().Publish(ctx, ports.EventMessage{EventType: "message.created"}); err != nil {
+				t.Fatal(err)
+			}
+
+			attempts := func() int {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(retryCounts)
+			}
+			eventually(t, func() bool { return attempts() == tt.wantAttempts })
+			if tt.wantDead {
+				eventually(t, func() bool { return len(b.DeadLetters()) == 1 })
+			}
+			// Let a wrong extra attempt or dead letter show up
+			time.Sleep(10 * time.Millisecond)
+
+			mu.Lock()
+			counts := slices.Clone(retryCounts)
+			mu.Unlock()
+			if len(counts) != tt.wantAttempts {
+				t.Errorf("handled %d times, want %d", len(counts), tt.wantAttempts)
+			}
+			for i, count := range counts {
+				if i == 0 && count != nil {
+					t.Errorf("first attempt has retry count %v", count)
+				} else if i > 0 && count != int32(i) {
+					t.Errorf("attempt %d has retry count %v, want %d", i, count, i)
+				}
+			}
+			dead := b.DeadLetters()
+			if (len(dead) == 1) != tt.wantDead || len(dead) > 1 {
+				t.Fatalf("%d dead letters, want dead lettered %t", len(dead), tt.wantDead)
+			}
+			if tt.wantDead && (dead[0].Queue != "test" || !errors.Is(dead[0].Err, errHandler)) {
+				t.Errorf("dead letter = %+v", dead[0])
+			}
+		})
+	}
+}
+
+func TestDeadLetterRing(t *testing.T) {
+	tests := []struct {
+		name        string
+		size        int
+		failed      int
+		wantKept    []string
+		wantDropped uint64
+	}{
+		{name: "under the size", size: 3, failed: 2, wantKept: []string{"a", "b"}},
+		{name: "exactly the size", size: 3, failed: 3, wantKept: []string{"a", "b", "c"}},
+		{name: "oldest are dropped", size: 3, failed: 5, wantKept: []string{"c", "d", "e"}, wantDropped: 2},
+		{name: "wraps more than once", size: 2, failed: 5, wantKept: []string{"d", "e"}, wantDropped: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New(Config{DeadLetterSize: tt.size})
+			q := &queue{name: "test"}
+			for i := range tt.failed {
+				b.deadLetter(q, ports.EventMessage{EventType: string(rune('a' + i))}, errHandler)
+			}
+
+			dead := b.DeadLetters()
+			if len(dead) != len(tt.wantKept) {
+				t.Fatalf("kept %d dead letters, want %d", len(dead), len(tt.wantKept))
+			}
+			for i, d := range dead {
+				if d.Message.EventType != tt.wantKept[i] {
+					t.Errorf("dead letter %d is %q, want %q", i, d.Message.EventType, tt.wantKept[i])
+				}
+			}
+			if got := b.DeadLettersDropped(); got != tt.wantDropped {
+				t.Errorf("dropped %d, want %d", got, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestRetryOverflow(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxPending  int
+		failed      int
+		wantPending int64
+		wantDead    int
+	}{
+		{name: "under the cap", maxPending: 4, failed: 3, wantPending: 3},
+		{name: "at the cap", maxPending: 3, failed: 3, wantPending: 3},
+		{name: "past the cap", maxPending: 2, failed: 5, wantPending: 2, wantDead: 3},
+		{name: "no retry slot", maxPending: 0, failed: 2, wantDead: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New(Config{QueueSize: 8, RetryDelays: []time.Duration{time.Hour}, DeadLetterSize: 8, MaxPendingRetries: tt.maxPending})
+			defer b.Close()
+			q := &queue{name: "test", bus: b, bindings: map[string]Handler{
+				"message.*": func(ctx context.Context, msg ports.EventMessage) error { return errHandler },
+			}}
+
+			for range tt.failed {
+				q.handle(context.Background(), delivery{msg: ports.EventMessage{EventType: "message.created"}})
+			}
+
+			if got := b.pendingRetries.Load(); got != tt.wantPending {
+				t.Errorf("%d retries pending, want %d", got, tt.wantPending)
+			}
+			dead := b.DeadLetters()
+			if len(dead) != tt.wantDead {
+				t.Fatalf("%d dead letters, want %d", len(dead), tt.wantDead)
+			}
+			for _, d := range dead {
+				if !errors.Is(d.Err, ErrRetryOverflow) || !errors.Is(d.Err, errHandler) {
+					t.Errorf("dead letter error = %v, want an overflow of the handler error", d.Err)
+				}
+			}
+		})
+	}
+}
+
+func TestCloseReleasesPendingRetries(t *testing.T) {
+	b := New(Config{QueueSize: 1, RetryDelays: []time.Duration{0}, DeadLetterSize: 8, MaxPendingRetries: 8})
+	q := &queue{
+		name: "test",
+		bus:  b,
+		bindings: map[string]Handler{
+			"message.*": func(ctx context.Context, msg ports.EventMessage) error { return errHandler },
+		},
+		msgs: make(chan delivery, 1),
+		done: make(chan struct{}),
+	}
+
+	// Nothing drains the full queue, so the retry waits for room
+	q.msgs <- delivery{msg: ports.EventMessage{EventType: "message.created"}}
+	q.handle(context.Background(), delivery{msg: ports.EventMessage{EventType: "message.created"}})
+	time.Sleep(10 * time.Millisecond)
+	if got := b.pendingRetries.Load(); got != 1 {
+		t.Fatalf("%d retries pending, want 1", got)
+	}
+
+	b.Close()
+	eventually(t, func() bool { return b.pendingRetries.Load() == 0 })
+}
@@ -0,0 +1,158 @@
+// Package config loads the settings of every command: the defaults below,
+// then the YAML file named by CONFIG_FILE if any, then the environment. The
+// result is validated as a whole so a bad deploy fails at startup, listing
+// every invalid setting by its YAML path and environment variable.
+package config
+
+import (
+	"backend/internal/application/services"
+	"backend/internal/infra/bus"
+	"backend/internal/interface/ws"
+	"backend/internal/processes/relayer"
+	"backend/internal/processes/retention"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Service tells Load which settings are required, e.g. only the api and the
+// ws sign or check tokens and need a secret.
+type Service string
+
+const (
+	SERVICE_API     Service = "api"
+	SERVICE_WS      Service = "ws"
+	SERVICE_RELAYER Service = "relayer"
+	SERVICE_TOOL    Service = "tool" // admin commands, only the database is required
+	// SERVICE_ALL_IN_ONE runs the api, ws and relayer in one process, on the
+	// memory bus unless EVENT_BUS says postgres.
+	SERVICE_ALL_IN_ONE Service = "noncord"
+)
+
+type Config struct {
+	DB       DBConfig            `yaml:"db"`
+	EventBus bus.Config          `yaml:"event_bus"`
+	Auth     services.AuthConfig `yaml:"auth"`
+	API      APIConfig           `yaml:"api"`
+	WS       WSConfig            `yaml:"ws"`
+	Relayer  RelayerConfig       `yaml:"relayer"`
+	Outbox   OutboxConfig        `yaml:"outbox"`
+}
+
+type DBConfig struct {
+	URI     string `yaml:"uri" env:"DB_URI" validate:"required"`
+	Migrate bool   `yaml:"migrate" env:"DB_MIGRATE"` // apply the embedded migrations on startup, all-in-one binary only
+}
+
+type APIConfig struct {
+	Port            string        `yaml:"port" env:"API_PORT,PORT" validate:"port"`
+	MetricsPort     string        `yaml:"metrics_port" env:"API_METRICS_PORT,METRICS_PORT" validate:"port"` // /metrics, kept off the public port
+	CORSOrigins     []string      `yaml:"cors_origins" env:"API_CORS_ORIGINS"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"API_SHUTDOWN_TIMEOUT"` // for requests in flight to finish on SIGTERM
+	// Workers is how many event handlers run concurrently. RabbitMQ keeps
+	// every aggregate on one of them so its events stay in order, the memory
+	// bus hands events to whichever is free and needs 1 to keep that order.
+	Workers int `yaml:"workers" env:"API_WORKERS"`
+	// RelayerMaxClaimAge is how long the in-process relayer of the memory bus
+	// can go without claiming from the outbox before the api is not ready.
+	RelayerMaxClaimAge time.Duration `yaml:"relayer_max_claim_age" env:"API_RELAYER_MAX_CLAIM_AGE"`
+}
+
+type WSConfig struct {
+	Port            string        `yaml:"port" env:"WS_PORT,PORT" validate:"port"`
+	MetricsPort     string        `yaml:"metrics_port" env:"WS_METRICS_PORT,METRICS_PORT" validate:"port"` // /metrics, kept off the public port, the all-in-one binary serves it on api.metrics_port
+	CORSOrigins     []string      `yaml:"cors_origins" env:"WS_CORS_ORIGINS"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"WS_SHUTDOWN_TIMEOUT"` // for clients to be told to reconnect and ops in flight to finish
+
+	ws.Config `yaml:",inline"`
+
+	NicknameCacheTTL     time.Duration `yaml:"nickname_cache_ttl" env:"WS_NICKNAME_CACHE_TTL"`
+	NicknameCacheCleanup time.Duration `yaml:"nickname_cache_cleanup" env:"WS_NICKNAME_CACHE_CLEANUP"`
+}
+
+type RelayerConfig struct {
+	relayer.Config `yaml:",inline"`
+
+	MetricsPort string        `yaml:"metrics_port" env:"RELAYER_METRICS_PORT,METRICS_PORT" validate:"port"` // /metrics, /healthz and /readyz
+	MaxClaimAge time.Duration `yaml:"max_claim_age" env:"RELAYER_MAX_CLAIM_AGE"`                            // without a claim for that long the relayer is not ready
+}
+
+type OutboxConfig struct {
+	Retention  retention.Config `yaml:"retention"`
+	ArchiveDir string           `yaml:"archive_dir" env:"OUTBOX_ARCHIVE_DIR"` // where purged events are archived, none when empty
+}
+
+// Default returns the settings used when neither the file nor the
+// environment sets them.
+func Default() Config {
+	return Config{
+		EventBus: bus.DefaultConfig,
+		Auth:     services.DefaultAuthConfig,
+		API: APIConfig{
+			Port:               "8888",
+			MetricsPort:        "9100",
+			CORSOrigins:        []string{"https://*", "http://*"},
+			ShutdownTimeout:    15 * time.Second,
+			Workers:            1,
+			RelayerMaxClaimAge: 30 * time.Second,
+		},
+		WS: WSConfig{
+			Port:                 "9999",
+			MetricsPort:          "9101",
+			CORSOrigins:          []string{"https://*", "http://*"},
+			ShutdownTimeout:      15 * time.Second,
+			Config:               ws.DefaultConfig,
+			NicknameCacheTTL:     15 * time.Minute,
+			NicknameCacheCleanup: 2 * time.Minute,
+		},
+		Relayer: RelayerConfig{
+			Config:      relayer.DefaultConfig,
+			MetricsPort: "9102",
+			MaxClaimAge: 30 * time.Second,
+		},
+		Outbox: OutboxConfig{
+			Retention: retention.DefaultConfig,
+		},
+	}
+}
+
+// Load reads the configuration of service and validates it, the error lists
+// every invalid setting.
+func Load(service Service) (*Config, error) {
+	cfg := Default()
+	if service == SERVICE_ALL_IN_ONE {
+		cfg.EventBus.Backend = bus.BACKEND_MEMORY
+	}
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := readFile(path, &cfg); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyEnv(&cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.validate(service); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func readFile(path string, cfg *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot read CONFIG_FILE: %w", err)
+	}
+	defer f.Close()
+
+	// Unknown keys are most likely typos, refuse them rather than run on defaults
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err = dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid CONFIG_FILE %s: %w", path, err)
+	}
+	return nil
+}
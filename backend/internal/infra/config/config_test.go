@@ -0,0 +1,284 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv unsets every configuration variable for the test, along with the
+// ones a developer shell may hold
+func clearEnv(t *testing.T) {
+	t.Helper()
+	cfg := Default()
+	for _, s := range settingsOf(&cfg) {
+		for _, name := range s.envs {
+			t.Setenv(name, "")
+		}
+	}
+	t.Setenv("CONFIG_FILE", "")
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		env     map[string]string
+		check   func(c *Config) bool
+		wantErr string
+	}{
+		{
+			name:  "defaults",
+			check: func(c *Config) bool { return c.WS.Port == "9999" && c.WS.PongWait == time.Minute },
+		},
+		{
+			name:  "file over defaults",
+			yaml:  "ws:\n  port: \"7000\"\n  pong_wait: 2m\n",
+			check: func(c *Config) bool { return c.WS.Port == "7000" && c.WS.PongWait == 2*time.Minute },
+		},
+		{
+			name:  "environment over file",
+			yaml:  "ws:\n  port: \"7000\"\n",
+			env:   map[string]string{"WS_PORT": "7001"},
+			check: func(c *Config) bool { return c.WS.Port == "7001" },
+		},
+		{
+			name:  "file keeps what the environment does not set",
+			yaml:  "ws:\n  port: \"7000\"\n  pong_wait: 2m\n",
+			env:   map[string]string{"WS_PORT": "7001"},
+			check: func(c *Config) bool { return c.WS.PongWait == 2*time.Minute },
+		},
+		{
+			name:  "first alias set wins",
+			env:   map[string]string{"API_PORT": "7002", "PORT": "7003"},
+			check: func(c *Config) bool { return c.API.Port == "7002" && c.WS.Port == "7003" },
+		},
+		{
+			name:  "empty variable is ignored",
+			yaml:  "api:\n  port: \"7000\"\n",
+			env:   map[string]string{"API_PORT": ""},
+			check: func(c *Config) bool { return c.API.Port == "7000" },
+		},
+		{
+			name: "comma separated lists",
+			env:  map[string]string{"MEMORY_BUS_RETRY_DELAYS": "1s, 1m", "WS_TRUSTED_PROXIES": "10.0.0.0/8,::1"},
+			check: func(c *Config) bool {
+				return slices.Equal(c.EventBus.Memory.RetryDelays, []time.Duration{time.Second, time.Minute}) &&
+					slices.Equal(c.WS.TrustedProxies, []string{"10.0.0.0/8", "::1"})
+			},
+		},
+		{
+			name:    "unknown file key",
+			yaml:    "ws:\n  pong_wiat: 2m\n",
+			wantErr: "field pong_wiat not found",
+		},
+		{
+			name:    "invalid variable",
+			env:     map[string]string{"WS_PONG_WAIT": "soon"},
+			wantErr: "ws.pong_wait (WS_PONG_WAIT): expected a duration",
+		},
+		{
+			name:    "validated after the environment",
+			yaml:    "ws:\n  pong_wait: 2m\n",
+			env:     map[string]string{"WS_PING_PERIOD": "3m"},
+			wantErr: "ws.ping_period (WS_PING_PERIOD): must be shorter than ws.pong_wait",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("DB_URI", "postgres://localhost/noncord")
+			t.Setenv("SECRET", "secret")
+			t.Setenv("AMQP_URI", "amqp://localhost")
+			if tt.yaml != "" {
+				path := filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(path, []byte(tt.yaml), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("CONFIG_FILE", path)
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			cfg, err := Load(SERVICE_API)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(cfg) {
+				t.Errorf("unexpected config %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		service Service
+		edit    func(c *Config)
+		wantErr []string
+	}{
+		{name: "defaults", service: SERVICE_API},
+		{name: "relayer without a secret", service: SERVICE_RELAYER, edit: func(c *Config) { c.Auth.Secret = "" }},
+		{name: "retention off", service: SERVICE_RELAYER, edit: func(c *Config) { c.Outbox.Retention.RetainFor = 0 }},
+		{
+			name:    "missing secret",
+			service: SERVICE_WS,
+			edit:    func(c *Config) { c.Auth.Secret = "" },
+			wantErr: []string{"auth.secret (SECRET): is required"},
+		},
+		{
+			name:    "missing database",
+			service: SERVICE_TOOL,
+			edit:    func(c *Config) { c.DB.URI = "" },
+			wantErr: []string{"db.uri (DB_URI): is required"},
+		},
+		{
+			name:    "every invalid setting is listed",
+			service: SERVICE_API,
+			edit: func(c *Config) {
+				c.API.Port = "http"
+				c.WS.WriteQueue = 0
+				c.Relayer.ShardCount = -1
+				c.Outbox.Retention.RetainFor = -time.Hour
+			},
+			wantErr: []string{
+				"api.port (API_PORT or PORT): expected a port number",
+				"ws.write_queue (WS_WRITE_QUEUE): must be positive",
+				"relayer.shard_count (RELAYER_SHARD_COUNT): must not be negative",
+				"outbox.retention.retain_for (OUTBOX_RETENTION): must not be negative",
+			},
+		},
+		{
+			name:    "negative retry delay",
+			service: SERVICE_API,
+			edit:    func(c *Config) { c.EventBus.Memory.RetryDelays = []time.Duration{time.Second, -time.Second} },
+			wantErr: []string{"event_bus.memory.retry_delays (MEMORY_BUS_RETRY_DELAYS): must be positive"},
+		},
+		{
+			name:    "relayer backoff base",
+			service: SERVICE_RELAYER,
+			edit:    func(c *Config) { c.Relayer.BackoffBase = 0 },
+			wantErr: []string{"relayer.backoff_base (RELAYER_BACKOFF_BASE): must be positive"},
+		},
+		{
+			name:    "relayer backoff max under base",
+			service: SERVICE_RELAYER,
+			edit:    func(c *Config) { c.Relayer.BackoffBase, c.Relayer.BackoffMax = time.Minute, time.Second },
+			wantErr: []string{"relayer.backoff_base (RELAYER_BACKOFF_BASE): must not exceed relayer.backoff_max"},
+		},
+		{
+			name:    "relayer max attempts",
+			service: SERVICE_RELAYER,
+			edit:    func(c *Config) { c.Relayer.MaxAttempts = 0 },
+			wantErr: []string{"relayer.max_attempts (RELAYER_MAX_ATTEMPTS): must be positive"},
+		},
+		{
+			name:    "trusted proxies",
+			service: SERVICE_WS,
+			edit:    func(c *Config) { c.WS.TrustedProxies = []string{"10.0.0.0/8", "proxy.internal"} },
+			wantErr: []string{`ws.trusted_proxies (WS_TRUSTED_PROXIES): expected CIDRs or addresses, got "proxy.internal"`},
+		},
+		{
+			name:    "api metrics on the public port",
+			service: SERVICE_API,
+			edit:    func(c *Config) { c.API.MetricsPort = c.API.Port },
+			wantErr: []string{"api.metrics_port (API_METRICS_PORT): must differ from api.port (API_PORT)"},
+		},
+		{
+			name:    "ws metrics on the public port",
+			service: SERVICE_WS,
+			edit:    func(c *Config) { c.WS.MetricsPort = c.WS.Port },
+			wantErr: []string{"ws.metrics_port (WS_METRICS_PORT): must differ from ws.port (WS_PORT)"},
+		},
+		{
+			name:    "all-in-one metrics on the ws port",
+			service: SERVICE_ALL_IN_ONE,
+			edit: func(c *Config) {
+				c.EventBus.Backend = "memory"
+				c.API.MetricsPort = c.WS.Port
+			},
+			wantErr: []string{"api.metrics_port (API_METRICS_PORT): must differ from ws.port (WS_PORT)"},
+		},
+		{
+			name:    "invalid metrics port",
+			service: SERVICE_WS,
+			edit:    func(c *Config) { c.WS.MetricsPort = "0" },
+			wantErr: []string{`ws.metrics_port (WS_METRICS_PORT or METRICS_PORT): expected a port number, got "0"`},
+		},
+		{
+			name:    "unknown bus",
+			service: SERVICE_API,
+			edit:    func(c *Config) { c.EventBus.Backend = "kafka" },
+			wantErr: []string{`unknown backend "kafka"`},
+		},
+		{
+			name:    "rabbitmq without an uri",
+			service: SERVICE_API,
+			edit:    func(c *Config) { c.EventBus.AMQPURI = "" },
+			wantErr: []string{"event_bus.amqp_uri (AMQP_URI): is required"},
+		},
+		{
+			name:    "ws on the memory bus",
+			service: SERVICE_WS,
+			edit:    func(c *Config) { c.EventBus.Backend = "memory" },
+			wantErr: []string{"the memory bus only reaches subscribers of the process relaying it"},
+		},
+		{
+			name:    "all-in-one on one port",
+			service: SERVICE_ALL_IN_ONE,
+			edit: func(c *Config) {
+				c.EventBus.Backend = "memory"
+				c.WS.Port = c.API.Port
+			},
+			wantErr: []string{"ws.port (WS_PORT): must differ from api.port"},
+		},
+		{
+			name:    "all-in-one on rabbitmq",
+			service: SERVICE_ALL_IN_ONE,
+			wantErr: []string{"the all-in-one binary runs without a broker"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.DB.URI = "postgres://localhost/noncord"
+			cfg.Auth.Secret = "secret"
+			cfg.EventBus.AMQPURI = "amqp://localhost"
+			if tt.edit != nil {
+				tt.edit(&cfg)
+			}
+
+			err := cfg.validate(tt.service)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("validate() = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("validate() = nil, want %q", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("validate() = %v, want %q", err, want)
+				}
+			}
+			if got := len(strings.Split(err.Error(), "\n")); got != len(tt.wantErr) {
+				t.Errorf("validate() listed %d errors, want %d: %v", got, len(tt.wantErr), err)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// setting is one leaf of Config, e.g. ws.pong_wait set by WS_PONG_WAIT.
+type setting struct {
+	path  string
+	envs  []string // the first one set wins, later ones are older names kept working
+	rule  string   // validate tag, e.g. required or nonnegative
+	value reflect.Value
+}
+
+func (s setting) String() string {
+	if len(s.envs) == 0 {
+		return s.path
+	}
+	return fmt.Sprintf("%s (%s)", s.path, strings.Join(s.envs, " or "))
+}
+
+// settingsOf lists the leaves of cfg, walking nested structs by their yaml
+// key. Fields tagged yaml:"-" are set by the commands and left out.
+func settingsOf(cfg *Config) []setting {
+	var out []setting
+	collect(reflect.ValueOf(cfg).Elem(), "", &out)
+	return out
+}
+
+func collect(v reflect.Value, prefix string, out *[]setting) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		path := prefix
+		if opts != "inline" {
+			path = joinPath(prefix, name)
+		}
+		if field.Type.Kind() == reflect.Struct {
+			collect(v.Field(i), path, out)
+			continue
+		}
+
+		var envs []string
+		if tag := field.Tag.Get("env"); tag != "" {
+			envs = strings.Split(tag, ",")
+		}
+		*out = append(*out, setting{path: path, envs: envs, rule: field.Tag.Get("validate"), value: v.Field(i)})
+	}
+}
+
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// applyEnv overrides every setting whose variable is set and not empty.
+func applyEnv(cfg *Config) error {
+	var errs []error
+	for _, s := range settingsOf(cfg) {
+		for _, name := range s.envs {
+			raw := os.Getenv(name)
+			if raw == "" {
+				continue
+			}
+			if err := parse(s.value, raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s (%s): %w", s.path, name, err))
+			}
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parse sets v from raw, slices are comma separated, e.g. RETRY_DELAYS=1s,10s,1m.
+func parse(v reflect.Value, raw string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("expected a duration like 30s or 5m, got %q", raw)
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("expected true or false, got %q", raw)
+		}
+		v.SetBool(b)
+	case v.CanInt():
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected an integer, got %q", raw)
+		}
+		v.SetInt(n)
+	case v.Kind() == reflect.Slice:
+		parts := strings.Split(raw, ",")
+		slice := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			if err := parse(slice.Index(i), strings.TrimSpace(part)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"backend/internal/infra/bus"
+	"errors"
+	"fmt"
+	"net/netip"
+	"reflect"
+	"strconv"
+)
+
+// validate checks every setting against its rule, numbers and durations must
+// be positive unless tagged nonnegative, then the rules spanning settings.
+func (c *Config) validate(service Service) error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	for _, s := range settingsOf(c) {
+		if err := check(s.value, s.rule); err != nil {
+			fail("%s: %w", s, err)
+		}
+	}
+
+	if !c.EventBus.Backend.Valid() {
+		fail("event_bus.backend (EVENT_BUS): unknown backend %q, expected rabbitmq, postgres or memory", c.EventBus.Backend)
+	}
+	if c.Auth.AccessTokenTTL >= c.Auth.SessionTTL {
+		fail("auth.access_token_ttl (AUTH_ACCESS_TOKEN_TTL): must be shorter than auth.session_ttl (AUTH_SESSION_TTL), got %s and %s", c.Auth.AccessTokenTTL, c.Auth.SessionTTL)
+	}
+	if c.WS.PingPeriod >= c.WS.PongWait {
+		fail("ws.ping_period (WS_PING_PERIOD): must be shorter than ws.pong_wait (WS_PONG_WAIT), got %s and %s", c.WS.PingPeriod, c.WS.PongWait)
+	}
+	if c.Relayer.BackoffBase > c.Relayer.BackoffMax {
+		fail("relayer.backoff_base (RELAYER_BACKOFF_BASE): must not exceed relayer.backoff_max (RELAYER_BACKOFF_MAX), got %s and %s", c.Relayer.BackoffBase, c.Relayer.BackoffMax)
+	}
+
+	switch service {
+	case SERVICE_API, SERVICE_WS, SERVICE_ALL_IN_ONE:
+		if c.Auth.Secret == "" {
+			fail("auth.secret (SECRET): is required to sign and check access tokens")
+		}
+	}
+	switch service {
+	case SERVICE_API, SERVICE_ALL_IN_ONE:
+		if c.API.MetricsPort == c.API.Port {
+			fail("api.metrics_port (API_METRICS_PORT): must differ from api.port (API_PORT), metrics are not served publicly, got %s", c.API.MetricsPort)
+		}
+	case SERVICE_WS:
+		if c.WS.MetricsPort == c.WS.Port {
+			fail("ws.metrics_port (WS_METRICS_PORT): must differ from ws.port (WS_PORT), metrics are not served publicly, got %s", c.WS.MetricsPort)
+		}
+	}
+	if service == SERVICE_ALL_IN_ONE {
+		if c.EventBus.Backend == bus.BACKEND_RABBITMQ {
+			fail("event_bus.backend (EVENT_BUS): the all-in-one binary runs without a broker, use memory or postgres, or the split services for rabbitmq")
+		}
+		if c.API.Port == c.WS.Port {
+			fail("ws.port (WS_PORT): must differ from api.port (API_PORT), the all-in-one binary listens on both, got %s", c.WS.Port)
+		}
+		if c.API.MetricsPort == c.WS.Port {
+			fail("api.metrics_port (API_METRICS_PORT): must differ from ws.port (WS_PORT), the all-in-one binary listens on both, got %s", c.API.MetricsPort)
+		}
+	}
+	if service != SERVICE_TOOL && service != SERVICE_ALL_IN_ONE && c.EventBus.Backend == bus.BACKEND_RABBITMQ && c.EventBus.AMQPURI == "" {
+		fail("event_bus.amqp_uri (AMQP_URI): is required by the rabbitmq backend")
+	}
+	if c.EventBus.Backend == bus.BACKEND_MEMORY {
+		switch service {
+		case SERVICE_WS:
+			fail("event_bus.backend (EVENT_BUS): the memory bus only reaches subscribers of the process relaying it, run the ws with rabbitmq or postgres")
+		case SERVICE_RELAYER:
+			fail("event_bus.backend (EVENT_BUS): the memory bus only reaches subscribers of its own process, the api relays it")
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func check(v reflect.Value, rule string) error {
+	switch {
+	case rule == "required":
+		if v.IsZero() {
+			return errors.New("is required")
+		}
+	case rule == "port":
+		if port, err := strconv.Atoi(v.String()); err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("expected a port number, got %q", v.String())
+		}
+	case rule == "cidrs":
+		for _, entry := range v.Interface().([]string) {
+			if _, err := netip.ParsePrefix(entry); err == nil {
+				continue
+			}
+			if _, err := netip.ParseAddr(entry); err != nil {
+				return fmt.Errorf("expected CIDRs or addresses, got %q", entry)
+			}
+		}
+	case v.CanInt():
+		if rule == "nonnegative" && v.Int() < 0 {
+			return fmt.Errorf("must not be negative, got %v", v.Interface())
+		}
+		if rule != "nonnegative" && v.Int() <= 0 {
+			return fmt.Errorf("must be positive, got %v", v.Interface())
+		}
+	case v.Kind() == reflect.Slice && v.Type().Elem() == durationType:
+		for i := 0; i < v.Len(); i++ {
+			if err := check(v.Index(i), rule); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
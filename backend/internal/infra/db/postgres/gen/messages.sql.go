@@ -13,6 +13,21 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const deleteReaction = `-- name: DeleteReaction :exec
+DELETE FROM reactions WHERE message_id = $1 AND user_id = $2 AND emote_id = $3
+`
+
+type DeleteReactionParams struct {
+	MessageID uuid.UUID
+	UserID    uuid.UUID
+	EmoteID   uuid.UUID
+}
+
+func (q *Queries) DeleteReaction(ctx context.Context, arg DeleteReactionParams) error {
+	_, err := q.db.Exec(ctx, deleteReaction, arg.MessageID, arg.UserID, arg.EmoteID)
+	return err
+}
+
 const findMessageById = `-- name: FindMessageById :one
 SELECT id, created_at, updated_at, deleted_at, channel_id, group_id, author_id, message, author_type FROM messages WHERE id = $1 AND deleted_at IS NULL
 `
@@ -279,6 +294,22 @@ func (q *Queries) GetEnrichedMessageById(ctx context.Context, id uuid.UUID) (Get
 	return i, err
 }
 
+const insertReaction = `-- name: InsertReaction :exec
+INSERT INTO reactions (message_id, user_id, emote_id) VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING
+`
+
+type InsertReactionParams struct {
+	MessageID uuid.UUID
+	UserID    uuid.UUID
+	EmoteID   uuid.UUID
+}
+
+func (q *Queries) InsertReaction(ctx context.Context, arg InsertReactionParams) error {
+	_, err := q.db.Exec(ctx, insertReaction, arg.MessageID, arg.UserID, arg.EmoteID)
+	return err
+}
+
 const saveMessage = `-- name: SaveMessage :one
 INSERT INTO messages (
 	id,
@@ -215,6 +215,21 @@ type Emote struct {
 	IconUrl   string
 }
 
+type EventCursor struct {
+	Consumer   string
+	TxID       int64
+	Seq        int64
+	Owner      pgtype.Text
+	LeaseUntil *time.Time
+}
+
+type EventDeadLetter struct {
+	Consumer  string
+	EventID   uuid.UUID
+	LastError string
+	FailedAt  time.Time
+}
+
 type FriendRequest struct {
 	CreatedAt time.Time
 	Requester uuid.UUID
@@ -228,6 +243,12 @@ type Friendship struct {
 	UserID2   uuid.UUID
 }
 
+type Inbox struct {
+	Consumer    string
+	EventID     uuid.UUID
+	ProcessedAt time.Time
+}
+
 type Invitation struct {
 	ID             uuid.UUID
 	ServerID       uuid.UUID
@@ -281,6 +302,22 @@ type Outbox struct {
 	Attempts      int32
 	ClaimedAt     *time.Time
 	PublishedAt   *time.Time
+	Seq           int64
+	NextAttemptAt time.Time
+	LastError     pgtype.Text
+	TxID          int64
+	TraceContext  []byte
+}
+
+type OutboxRelayer struct {
+	ID     string
+	SeenAt time.Time
+}
+
+type OutboxShardLease struct {
+	Shard     int32
+	Owner     pgtype.Text
+	ExpiresAt *time.Time
 }
 
 type Reaction struct {
@@ -333,6 +370,12 @@ type Session struct {
 	RefreshToken  string
 }
 
+type StreamTicketRedemption struct {
+	TicketID   uuid.UUID
+	ExpiresAt  time.Time
+	RedeemedAt time.Time
+}
+
 type User struct {
 	ID          uuid.UUID
 	CreatedAt   time.Time
@@ -347,6 +390,7 @@ type User struct {
 	AvatarUrl   string
 	BannerUrl   string
 	Flags       int16
+	Verified    bool
 }
 
 type UserNotificationOverride struct {
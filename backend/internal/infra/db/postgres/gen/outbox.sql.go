@@ -10,15 +10,77 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const acquireOutboxShardLeases = `-- name: AcquireOutboxShardLeases :many
+WITH free AS (
+  SELECT l.shard
+  FROM outbox_shard_leases l
+  WHERE (l.owner IS NULL OR l.expires_at < now()) AND l.shard < $3
+  ORDER BY l.shard
+  FOR UPDATE SKIP LOCKED
+  LIMIT $4
+)
+UPDATE outbox_shard_leases AS l
+SET owner      = $1,
+    expires_at = now() + $2::interval
+FROM free f
+WHERE l.shard = f.shard
+RETURNING l.shard
+`
+
+type AcquireOutboxShardLeasesParams struct {
+	Owner   pgtype.Text
+	Column2 time.Duration
+	Shard   int32
+	Limit   int32
+}
+
+func (q *Queries) AcquireOutboxShardLeases(ctx context.Context, arg AcquireOutboxShardLeasesParams) ([]int32, error) {
+	rows, err := q.db.Query(ctx, acquireOutboxShardLeases,
+		arg.Owner,
+		arg.Column2,
+		arg.Shard,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var shard int32
+		if err := rows.Scan(&shard); err != nil {
+			return nil, err
+		}
+		items = append(items, shard)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const claimOutboxBatch = `-- name: ClaimOutboxBatch :many
 WITH candidates AS (
   SELECT o.id
   FROM outbox o
   WHERE o.status IN ('pending', 'inflight')
-    AND (o.status = 'pending' OR o.claimed_at < now() - $1::interval)
-  ORDER BY o.occurred_at
+    AND (
+      (o.status = 'pending' AND o.next_attempt_at <= now())
+      OR o.claimed_at < now() - $1::interval
+    )
+    AND ($3::int = 0 OR outbox_shard(o.aggregate_id, $3::int) = ANY($4::int[]))
+    -- only the oldest undelivered event of an aggregate is claimable
+    AND NOT EXISTS (
+      SELECT 1
+      FROM outbox p
+      WHERE p.aggregate_id = o.aggregate_id
+        AND p.seq < o.seq
+        AND p.status IN ('pending', 'inflight')
+    )
+  ORDER BY o.seq
   FOR UPDATE SKIP LOCKED
   LIMIT $2
 )
@@ -28,16 +90,23 @@ SET status     = 'inflight',
     attempts   = attempts + 1
 FROM candidates c
 WHERE o.id = c.id
-RETURNING o.id, o.aggregate_name, o.aggregate_id, o.event_type, o.schema_version, o.occurred_at, o.payload, o.status, o.attempts, o.claimed_at, o.published_at
+RETURNING o.id, o.aggregate_name, o.aggregate_id, o.event_type, o.schema_version, o.occurred_at, o.payload, o.status, o.attempts, o.claimed_at, o.published_at, o.seq, o.next_attempt_at, o.last_error, o.tx_id, o.trace_context
 `
 
 type ClaimOutboxBatchParams struct {
 	Column1 time.Duration
 	Limit   int32
+	Column3 int32
+	Column4 []int32
 }
 
 func (q *Queries) ClaimOutboxBatch(ctx context.Context, arg ClaimOutboxBatchParams) ([]Outbox, error) {
-	rows, err := q.db.Query(ctx, claimOutboxBatch, arg.Column1, arg.Limit)
+	rows, err := q.db.Query(ctx, claimOutboxBatch,
+		arg.Column1,
+		arg.Limit,
+		arg.Column3,
+		arg.Column4,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -57,6 +126,11 @@ func (q *Queries) ClaimOutboxBatch(ctx context.Context, arg ClaimOutboxBatchPara
 			&i.Attempts,
 			&i.ClaimedAt,
 			&i.PublishedAt,
+			&i.Seq,
+			&i.NextAttemptAt,
+			&i.LastError,
+			&i.TxID,
+			&i.TraceContext,
 		); err != nil {
 			return nil, err
 		}
@@ -68,6 +142,154 @@ func (q *Queries) ClaimOutboxBatch(ctx context.Context, arg ClaimOutboxBatchPara
 	return items, nil
 }
 
+const countLiveOutboxRelayers = `-- name: CountLiveOutboxRelayers :one
+SELECT count(*) FROM outbox_relayers WHERE seen_at > now() - $1::interval
+`
+
+func (q *Queries) CountLiveOutboxRelayers(ctx context.Context, dollar_1 time.Duration) (int64, error) {
+	row := q.db.QueryRow(ctx, countLiveOutboxRelayers, dollar_1)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const deadLetterOutbox = `-- name: DeadLetterOutbox :exec
+UPDATE outbox SET status = 'dead_lettered', last_error = $2 WHERE id = $1
+`
+
+type DeadLetterOutboxParams struct {
+	ID        uuid.UUID
+	LastError pgtype.Text
+}
+
+func (q *Queries) DeadLetterOutbox(ctx context.Context, arg DeadLetterOutboxParams) error {
+	_, err := q.db.Exec(ctx, deadLetterOutbox, arg.ID, arg.LastError)
+	return err
+}
+
+const deleteOutboxRelayer = `-- name: DeleteOutboxRelayer :exec
+DELETE FROM outbox_relayers WHERE id = $1
+`
+
+func (q *Queries) DeleteOutboxRelayer(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, deleteOutboxRelayer, id)
+	return err
+}
+
+const ensureOutboxShards = `-- name: EnsureOutboxShards :exec
+INSERT INTO outbox_shard_leases (shard)
+SELECT generate_series(0, $1::int - 1)
+ON CONFLICT (shard) DO NOTHING
+`
+
+func (q *Queries) EnsureOutboxShards(ctx context.Context, dollar_1 int32) error {
+	_, err := q.db.Exec(ctx, ensureOutboxShards, dollar_1)
+	return err
+}
+
+const findOutboxById = `-- name: FindOutboxById :one
+SELECT id, aggregate_name, aggregate_id, event_type, schema_version, occurred_at, payload, status, attempts, claimed_at, published_at, seq, next_attempt_at, last_error, tx_id, trace_context FROM outbox WHERE id = $1
+`
+
+func (q *Queries) FindOutboxById(ctx context.Context, id uuid.UUID) (Outbox, error) {
+	row := q.db.QueryRow(ctx, findOutboxById, id)
+	var i Outbox
+	err := row.Scan(
+		&i.ID,
+		&i.AggregateName,
+		&i.AggregateID,
+		&i.EventType,
+		&i.SchemaVersion,
+		&i.OccurredAt,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.ClaimedAt,
+		&i.PublishedAt,
+		&i.Seq,
+		&i.NextAttemptAt,
+		&i.LastError,
+		&i.TxID,
+		&i.TraceContext,
+	)
+	return i, err
+}
+
+const findOutboxIds = `-- name: FindOutboxIds :many
+SELECT id FROM outbox WHERE id = ANY($1::uuid[])
+`
+
+func (q *Queries) FindOutboxIds(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.Query(ctx, findOutboxIds, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const importOutboxEvent = `-- name: ImportOutboxEvent :execrows
+INSERT INTO outbox (
+  id,
+  aggregate_name,
+  aggregate_id,
+  event_type,
+  schema_version,
+  occurred_at,
+  payload,
+  status,
+  published_at,
+  tx_id
+)
+VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9,
+  -- restored history sorts before every event bus cursor
+  CASE WHEN $8 = 'dispatched' THEN 0 ELSE pg_current_xact_id()::text::bigint END
+)
+ON CONFLICT (id) DO NOTHING
+`
+
+type ImportOutboxEventParams struct {
+	ID            uuid.UUID
+	AggregateName string
+	AggregateID   uuid.UUID
+	EventType     string
+	SchemaVersion int32
+	OccurredAt    time.Time
+	Payload       []byte
+	Status        string
+	PublishedAt   *time.Time
+}
+
+func (q *Queries) ImportOutboxEvent(ctx context.Context, arg ImportOutboxEventParams) (int64, error) {
+	result, err := q.db.Exec(ctx, importOutboxEvent,
+		arg.ID,
+		arg.AggregateName,
+		arg.AggregateID,
+		arg.EventType,
+		arg.SchemaVersion,
+		arg.OccurredAt,
+		arg.Payload,
+		arg.Status,
+		arg.PublishedAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const insertEventToOutbox = `-- name: InsertEventToOutbox :one
 INSERT INTO outbox( 
   id,
@@ -76,10 +298,11 @@ INSERT INTO outbox(
   event_type,
   schema_version,
   occurred_at,
-  payload
+  payload,
+  trace_context
 ) 
-VALUES ($1, $2, $3, $4, $5, $6, $7) 
-RETURNING id, aggregate_name, aggregate_id, event_type, schema_version, occurred_at, payload, status, attempts, claimed_at, published_at
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8) 
+RETURNING id, aggregate_name, aggregate_id, event_type, schema_version, occurred_at, payload, status, attempts, claimed_at, published_at, seq, next_attempt_at, last_error, tx_id, trace_context
 `
 
 type InsertEventToOutboxParams struct {
@@ -90,6 +313,7 @@ type InsertEventToOutboxParams struct {
 	SchemaVersion int32
 	OccurredAt    time.Time
 	Payload       []byte
+	TraceContext  []byte
 }
 
 func (q *Queries) InsertEventToOutbox(ctx context.Context, arg InsertEventToOutboxParams) (Outbox, error) {
@@ -101,6 +325,7 @@ func (q *Queries) InsertEventToOutbox(ctx context.Context, arg InsertEventToOutb
 		arg.SchemaVersion,
 		arg.OccurredAt,
 		arg.Payload,
+		arg.TraceContext,
 	)
 	var i Outbox
 	err := row.Scan(
@@ -115,10 +340,128 @@ func (q *Queries) InsertEventToOutbox(ctx context.Context, arg InsertEventToOutb
 		&i.Attempts,
 		&i.ClaimedAt,
 		&i.PublishedAt,
+		&i.Seq,
+		&i.NextAttemptAt,
+		&i.LastError,
+		&i.TxID,
+		&i.TraceContext,
 	)
 	return i, err
 }
 
+const listDeadLetteredOutbox = `-- name: ListDeadLetteredOutbox :many
+SELECT id, aggregate_name, aggregate_id, event_type, schema_version, occurred_at, payload, status, attempts, claimed_at, published_at, seq, next_attempt_at, last_error, tx_id, trace_context FROM outbox
+WHERE status = 'dead_lettered'
+ORDER BY seq
+LIMIT $1 OFFSET $2
+`
+
+type ListDeadLetteredOutboxParams struct {
+	Limit  int32
+	Offset int32
+}
+
+func (q *Queries) ListDeadLetteredOutbox(ctx context.Context, arg ListDeadLetteredOutboxParams) ([]Outbox, error) {
+	rows, err := q.db.Query(ctx, listDeadLetteredOutbox, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Outbox
+	for rows.Next() {
+		var i Outbox
+		if err := rows.Scan(
+			&i.ID,
+			&i.AggregateName,
+			&i.AggregateID,
+			&i.EventType,
+			&i.SchemaVersion,
+			&i.OccurredAt,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.ClaimedAt,
+			&i.PublishedAt,
+			&i.Seq,
+			&i.NextAttemptAt,
+			&i.LastError,
+			&i.TxID,
+			&i.TraceContext,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOutboxEvents = `-- name: ListOutboxEvents :many
+SELECT id, aggregate_name, aggregate_id, event_type, schema_version, occurred_at, payload, status, attempts, claimed_at, published_at, seq, next_attempt_at, last_error, tx_id, trace_context FROM outbox
+WHERE seq > $1::bigint
+  AND ($2::uuid IS NULL OR aggregate_id = $2::uuid)
+  AND (cardinality($3::text[]) = 0 OR event_type = ANY($3::text[]))
+  AND ($4::timestamptz IS NULL OR occurred_at >= $4::timestamptz)
+  AND ($5::timestamptz IS NULL OR occurred_at < $5::timestamptz)
+ORDER BY seq
+LIMIT $6
+`
+
+type ListOutboxEventsParams struct {
+	AfterSeq    int64
+	AggregateID *uuid.UUID
+	EventTypes  []string
+	Since       *time.Time
+	Until       *time.Time
+	BatchSize   int32
+}
+
+func (q *Queries) ListOutboxEvents(ctx context.Context, arg ListOutboxEventsParams) ([]Outbox, error) {
+	rows, err := q.db.Query(ctx, listOutboxEvents,
+		arg.AfterSeq,
+		arg.AggregateID,
+		arg.EventTypes,
+		arg.Since,
+		arg.Until,
+		arg.BatchSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Outbox
+	for rows.Next() {
+		var i Outbox
+		if err := rows.Scan(
+			&i.ID,
+			&i.AggregateName,
+			&i.AggregateID,
+			&i.EventType,
+			&i.SchemaVersion,
+			&i.OccurredAt,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.ClaimedAt,
+			&i.PublishedAt,
+			&i.Seq,
+			&i.NextAttemptAt,
+			&i.LastError,
+			&i.TxID,
+			&i.TraceContext,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const markedOutboxDispatched = `-- name: MarkedOutboxDispatched :exec
 UPDATE outbox SET status = 'dispatched', published_at = NOW() WHERE id = $1
 `
@@ -128,20 +471,211 @@ func (q *Queries) MarkedOutboxDispatched(ctx context.Context, id uuid.UUID) erro
 	return err
 }
 
-const markedOutboxFailed = `-- name: MarkedOutboxFailed :exec
-UPDATE outbox SET status = 'failed' WHERE id = $1
+const notifyOutbox = `-- name: NotifyOutbox :exec
+SELECT pg_notify('outbox_events', '')
+`
+
+func (q *Queries) NotifyOutbox(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, notifyOutbox)
+	return err
+}
+
+const outboxBacklog = `-- name: OutboxBacklog :many
+SELECT status, count(*) AS events, min(occurred_at)::timestamptz AS oldest
+FROM outbox
+WHERE status <> 'dispatched'
+GROUP BY status
+`
+
+type OutboxBacklogRow struct {
+	Status string
+	Events int64
+	Oldest time.Time
+}
+
+func (q *Queries) OutboxBacklog(ctx context.Context) ([]OutboxBacklogRow, error) {
+	rows, err := q.db.Query(ctx, outboxBacklog)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []OutboxBacklogRow
+	for rows.Next() {
+		var i OutboxBacklogRow
+		if err := rows.Scan(&i.Status, &i.Events, &i.Oldest); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeOutbox = `-- name: PurgeOutbox :many
+DELETE FROM outbox
+WHERE id IN (
+  SELECT o.id
+  FROM outbox o
+  WHERE o.occurred_at < $1
+    AND (
+      o.status = 'dispatched'
+      -- postgres event bus: every durable cursor already read the row
+      OR ($2::bool AND NOT EXISTS (
+        SELECT 1 FROM event_cursors c WHERE (c.tx_id, c.seq) < (o.tx_id, o.seq)
+      ))
+    )
+  ORDER BY o.seq
+  FOR UPDATE SKIP LOCKED
+  LIMIT $3
+)
+RETURNING id, aggregate_name, aggregate_id, event_type, schema_version, occurred_at, payload, status, attempts, claimed_at, published_at, seq, next_attempt_at, last_error, tx_id, trace_context
+`
+
+type PurgeOutboxParams struct {
+	OccurredAt time.Time
+	Column2    bool
+	Limit      int32
+}
+
+func (q *Queries) PurgeOutbox(ctx context.Context, arg PurgeOutboxParams) ([]Outbox, error) {
+	rows, err := q.db.Query(ctx, purgeOutbox, arg.OccurredAt, arg.Column2, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Outbox
+	for rows.Next() {
+		var i Outbox
+		if err := rows.Scan(
+			&i.ID,
+			&i.AggregateName,
+			&i.AggregateID,
+			&i.EventType,
+			&i.SchemaVersion,
+			&i.OccurredAt,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.ClaimedAt,
+			&i.PublishedAt,
+			&i.Seq,
+			&i.NextAttemptAt,
+			&i.LastError,
+			&i.TxID,
+			&i.TraceContext,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const releaseAllOutboxShardLeases = `-- name: ReleaseAllOutboxShardLeases :exec
+UPDATE outbox_shard_leases SET owner = NULL, expires_at = NULL WHERE owner = $1
+`
+
+func (q *Queries) ReleaseAllOutboxShardLeases(ctx context.Context, owner pgtype.Text) error {
+	_, err := q.db.Exec(ctx, releaseAllOutboxShardLeases, owner)
+	return err
+}
+
+const releaseOutboxShardLeases = `-- name: ReleaseOutboxShardLeases :exec
+UPDATE outbox_shard_leases SET owner = NULL, expires_at = NULL
+WHERE owner = $1 AND shard = ANY($2::int[])
+`
+
+type ReleaseOutboxShardLeasesParams struct {
+	Owner   pgtype.Text
+	Column2 []int32
+}
+
+func (q *Queries) ReleaseOutboxShardLeases(ctx context.Context, arg ReleaseOutboxShardLeasesParams) error {
+	_, err := q.db.Exec(ctx, releaseOutboxShardLeases, arg.Owner, arg.Column2)
+	return err
+}
+
+const renewOutboxShardLeases = `-- name: RenewOutboxShardLeases :many
+UPDATE outbox_shard_leases
+SET expires_at = now() + $2::interval
+WHERE owner = $1 AND shard < $3
+RETURNING shard
 `
 
-func (q *Queries) MarkedOutboxFailed(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.Exec(ctx, markedOutboxFailed, id)
+type RenewOutboxShardLeasesParams struct {
+	Owner   pgtype.Text
+	Column2 time.Duration
+	Shard   int32
+}
+
+func (q *Queries) RenewOutboxShardLeases(ctx context.Context, arg RenewOutboxShardLeasesParams) ([]int32, error) {
+	rows, err := q.db.Query(ctx, renewOutboxShardLeases, arg.Owner, arg.Column2, arg.Shard)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var shard int32
+		if err := rows.Scan(&shard); err != nil {
+			return nil, err
+		}
+		items = append(items, shard)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const requeueOutbox = `-- name: RequeueOutbox :execrows
+UPDATE outbox
+SET status          = 'pending',
+    attempts        = 0,
+    claimed_at      = NULL,
+    next_attempt_at = now()
+WHERE id = $1 AND status = 'dead_lettered'
+`
+
+func (q *Queries) RequeueOutbox(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, requeueOutbox, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const retryOutbox = `-- name: RetryOutbox :exec
+UPDATE outbox
+SET status          = 'pending',
+    claimed_at      = NULL,
+    next_attempt_at = now() + $2::interval,
+    last_error      = $3
+WHERE id = $1
+`
+
+type RetryOutboxParams struct {
+	ID        uuid.UUID
+	Column2   time.Duration
+	LastError pgtype.Text
+}
+
+func (q *Queries) RetryOutbox(ctx context.Context, arg RetryOutboxParams) error {
+	_, err := q.db.Exec(ctx, retryOutbox, arg.ID, arg.Column2, arg.LastError)
 	return err
 }
 
-const requeueOutbox = `-- name: RequeueOutbox :exec
-UPDATE outbox SET status = 'pending', claimed_at = NULL WHERE id = $1
+const upsertOutboxRelayer = `-- name: UpsertOutboxRelayer :exec
+INSERT INTO outbox_relayers (id, seen_at) VALUES ($1, now())
+ON CONFLICT (id) DO UPDATE SET seen_at = now()
 `
 
-func (q *Queries) RequeueOutbox(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.Exec(ctx, requeueOutbox, id)
+func (q *Queries) UpsertOutboxRelayer(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, upsertOutboxRelayer, id)
 	return err
 }
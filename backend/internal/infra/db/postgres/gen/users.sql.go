@@ -27,7 +27,8 @@ INSERT INTO users (
   disabled,
   avatar_url,
   banner_url,
-  flags
+  flags,
+  verified
 ) VALUES ( 
   $1,
   $2,
@@ -41,7 +42,8 @@ INSERT INTO users (
   $10,
   $11,
   $12,
-  $13
+  $13,
+  $14
 )
 ON CONFLICT (id)
 DO UPDATE SET 
@@ -56,8 +58,9 @@ DO UPDATE SET
   disabled = $10,
   avatar_url = $11,
   banner_url = $12,
-  flags = $13
-RETURNING id, created_at, updated_at, deleted_at, username, display_name, about_me, email, password, disabled, avatar_url, banner_url, flags
+  flags = $13,
+  verified = $14
+RETURNING id, created_at, updated_at, deleted_at, username, display_name, about_me, email, password, disabled, avatar_url, banner_url, flags, verified
 `
 
 type CreateUserParams struct {
@@ -74,6 +77,7 @@ type CreateUserParams struct {
 	AvatarUrl   string
 	BannerUrl   string
 	Flags       int16
+	Verified    bool
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -91,6 +95,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		arg.AvatarUrl,
 		arg.BannerUrl,
 		arg.Flags,
+		arg.Verified,
 	)
 	var i User
 	err := row.Scan(
@@ -107,6 +112,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.AvatarUrl,
 		&i.BannerUrl,
 		&i.Flags,
+		&i.Verified,
 	)
 	return i, err
 }
@@ -192,7 +198,7 @@ func (q *Queries) CreateUserSetting(ctx context.Context, arg CreateUserSettingPa
 }
 
 const findUserByEmail = `-- name: FindUserByEmail :one
-SELECT id, created_at, updated_at, deleted_at, username, display_name, about_me, email, password, disabled, avatar_url, banner_url, flags FROM users WHERE email = $1 AND deleted_at IS NULL
+SELECT id, created_at, updated_at, deleted_at, username, display_name, about_me, email, password, disabled, avatar_url, banner_url, flags, verified FROM users WHERE email = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) FindUserByEmail(ctx context.Context, email string) (User, error) {
@@ -212,12 +218,13 @@ func (q *Queries) FindUserByEmail(ctx context.Context, email string) (User, erro
 		&i.AvatarUrl,
 		&i.BannerUrl,
 		&i.Flags,
+		&i.Verified,
 	)
 	return i, err
 }
 
 const findUserById = `-- name: FindUserById :one
-SELECT id, created_at, updated_at, deleted_at, username, display_name, about_me, email, password, disabled, avatar_url, banner_url, flags FROM users WHERE id = $1 AND deleted_at IS NULL
+SELECT id, created_at, updated_at, deleted_at, username, display_name, about_me, email, password, disabled, avatar_url, banner_url, flags, verified FROM users WHERE id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) FindUserById(ctx context.Context, id uuid.UUID) (User, error) {
@@ -237,12 +244,13 @@ func (q *Queries) FindUserById(ctx context.Context, id uuid.UUID) (User, error)
 		&i.AvatarUrl,
 		&i.BannerUrl,
 		&i.Flags,
+		&i.Verified,
 	)
 	return i, err
 }
 
 const findUserByUsername = `-- name: FindUserByUsername :one
-SELECT id, created_at, updated_at, deleted_at, username, display_name, about_me, email, password, disabled, avatar_url, banner_url, flags FROM users WHERE username = $1 AND deleted_at IS NULL
+SELECT id, created_at, updated_at, deleted_at, username, display_name, about_me, email, password, disabled, avatar_url, banner_url, flags, verified FROM users WHERE username = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) FindUserByUsername(ctx context.Context, username string) (User, error) {
@@ -262,6 +270,7 @@ func (q *Queries) FindUserByUsername(ctx context.Context, username string) (User
 		&i.AvatarUrl,
 		&i.BannerUrl,
 		&i.Flags,
+		&i.Verified,
 	)
 	return i, err
 }
@@ -22,7 +22,7 @@ func fromDbUser(user *gen.User) *entities.User {
 		Email:       user.Email,
 		Password:    "",
 		Disabled:    user.Disabled,
-		Verified:    true,
+		Verified:    user.Verified,
 		AvatarUrl:   user.AvatarUrl,
 		BannerUrl:   user.BannerUrl,
 		Flags:       entities.UserFlags(user.Flags),
@@ -47,6 +47,6 @@ func toCommonUser(user gen.User) common.UserResult {
 		AvatarUrl:   user.AvatarUrl,
 		BannerUrl:   user.BannerUrl,
 		Flags:       uint16(user.Flags),
-		Verified:    true,
+		Verified:    user.Verified,
 	}
 }
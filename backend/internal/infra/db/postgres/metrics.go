@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"backend/internal/infra/db/postgres/gen"
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	uowDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "noncord",
+		Subsystem: "uow",
+		Name:      "duration_seconds",
+		Help:      "Duration of UnitOfWork.Do transactions, nested ones are savepoints.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"nested"})
+
+	uowRollbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "noncord",
+		Subsystem: "uow",
+		Name:      "rollbacks_total",
+		Help:      "UnitOfWork.Do transactions rolled back, by an error of the work or of the commit.",
+	}, []string{"nested"})
+)
+
+func observeUoW(nested bool, start time.Time, err error) {
+	label := strconv.FormatBool(nested)
+	uowDuration.WithLabelValues(label).Observe(time.Since(start).Seconds())
+	if err != nil {
+		uowRollbacks.WithLabelValues(label).Inc()
+	}
+}
+
+// OUTBOX_STATUSES are reported even when no event has them, so the series
+// drop to 0 instead of vanishing once the backlog is drained.
+var OUTBOX_STATUSES = []string{"pending", "inflight", "dead_lettered"}
+
+var (
+	outboxBacklogDesc = prometheus.NewDesc("noncord_outbox_backlog",
+		"Outbox events not dispatched yet, by status.", []string{"status"}, nil)
+	outboxOldestPendingDesc = prometheus.NewDesc("noncord_outbox_oldest_pending_age_seconds",
+		"Age of the oldest pending outbox event, 0 when none is pending.", nil, nil)
+)
+
+type outboxCollector struct {
+	q *gen.Queries
+}
+
+// NewOutboxCollector reports the outbox backlog, it is read from the
+// database on every scrape.
+func NewOutboxCollector(pool *pgxpool.Pool) prometheus.Collector {
+	return &outboxCollector{gen.New(pool)}
+}
+
+func (c *outboxCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- outboxBacklogDesc
+	ch <- outboxOldestPendingDesc
+}
+
+func (c *outboxCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	rows, err := c.q.OutboxBacklog(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(outboxBacklogDesc, err)
+		return
+	}
+
+	backlog := map[string]int64{}
+	for _, status := range OUTBOX_STATUSES {
+		backlog[status] = 0
+	}
+	var oldestPending float64
+	for _, row := range rows {
+		backlog[row.Status] = row.Events
+		if row.Status == "pending" {
+			oldestPending = max(time.Since(row.Oldest).Seconds(), 0)
+		}
+	}
+	for status, events := range backlog {
+		ch <- prometheus.MustNewConstMetric(outboxBacklogDesc, prometheus.GaugeValue, float64(events), status)
+	}
+	ch <- prometheus.MustNewConstMetric(outboxOldestPendingDesc, prometheus.GaugeValue, oldestPending)
+}
@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"backend/internal/infra/db/sql/migration"
+	"context"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
+)
+
+func newMigrationProvider(pool *pgxpool.Pool) (*goose.Provider, error) {
+	return goose.NewProvider(goose.DialectPostgres, stdlib.OpenDBFromPool(pool), migration.FS)
+}
+
+// Migrate applies the embedded migrations the database does not have yet,
+// in order. Each one runs in its own transaction unless it opts out.
+func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
+	provider, err := newMigrationProvider(pool)
+	if err != nil {
+		return err
+	}
+	defer provider.Close()
+
+	results, err := provider.Up(ctx)
+	for _, res := range results {
+		slog.Info("Applied migration", "version", res.Source.Version, "file", res.Source.Path, "took", res.Duration)
+	}
+	return err
+}
+
+// MigrationStatus lists every embedded migration, applied or pending.
+func MigrationStatus(ctx context.Context, pool *pgxpool.Pool) ([]*goose.MigrationStatus, error) {
+	provider, err := newMigrationProvider(pool)
+	if err != nil {
+		return nil, err
+	}
+	defer provider.Close()
+
+	return provider.Status(ctx)
+}
@@ -5,9 +5,20 @@ import (
 	"backend/internal/infra/db/postgres/gen"
 	"context"
 	"encoding/json"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 func pullAndPushEvents(ctx context.Context, q *gen.Queries, evts []events.DomainEvent) error {
+	// Consumers continue the trace of the request that raised the events
+	var traceContext []byte
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) > 0 {
+		traceContext, _ = json.Marshal(carrier)
+	}
+
 	for _, evt := range evts {
 		payload, err := json.Marshal(evt)
 		if err != nil {
@@ -23,10 +34,15 @@ func pullAndPushEvents(ctx context.Context, q *gen.Queries, evts []events.Domain
 			SchemaVersion: int32(base.SchemaVersion),
 			OccurredAt:    base.OccurredAt,
 			Payload:       payload,
+			TraceContext:  traceContext,
 		}); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	if len(evts) == 0 {
+		return nil
+	}
+	// Wakes listening relayers once the transaction commits
+	return q.NotifyOutbox(ctx)
 }
@@ -0,0 +1,298 @@
+package postgres
+
+import (
+	"backend/internal/application/ports"
+	"backend/internal/infra/db/postgres/gen"
+	"backend/internal/infra/telemetry"
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PGSubscriberConfig struct {
+	PollInterval  time.Duration `yaml:"poll_interval" env:"PG_SUBSCRIBER_POLL_INTERVAL"` // safety net next to LISTEN, e.g. 2 * time.Second
+	BatchSize     int32         `yaml:"batch_size" env:"PG_SUBSCRIBER_BATCH_SIZE"`
+	LeaseDuration time.Duration `yaml:"lease_duration" env:"PG_SUBSCRIBER_LEASE_DURATION"` // how long an instance keeps a shared cursor without progress
+	// RetryDelays holds the delay before each retry of a failed event, the
+	// cursor waits for them so events stay in order. An event that failed
+	// len(RetryDelays)+1 times is recorded in event_dead_letters and skipped.
+	RetryDelays []time.Duration `yaml:"retry_delays" env:"PG_SUBSCRIBER_RETRY_DELAYS"`
+}
+
+var DefaultPGSubscriberConfig = PGSubscriberConfig{
+	PollInterval:  2 * time.Second,
+	BatchSize:     100,
+	LeaseDuration: time.Minute,
+	RetryDelays:   []time.Duration{100 * time.Millisecond, time.Second, 5 * time.Second},
+}
+
+var errCursorLost = errors.New("event cursor lease lost")
+
+// PGEventSubscriber reads events straight from the outbox, no broker and no
+// relayer involved. Its position is a cursor over (tx_id, seq).
+type PGEventSubscriber struct {
+	pool     *pgxpool.Pool
+	q        *gen.Queries
+	listener ports.OutboxListener
+	cfg      PGSubscriberConfig
+
+	consumer string
+	owner    pgtype.Text
+	durable  bool
+	txId     int64 // position of a broadcast subscriber, durable ones keep it in event_cursors
+	seq      int64
+
+	mu         sync.RWMutex
+	handlerMap map[string]func(context.Context, ports.EventMessage) error
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewPGEventSubscriber shares one cursor in event_cursors between every
+// instance of consumer, only the instance holding its lease reads at a time.
+// A new consumer starts at the current end of the outbox.
+func NewPGEventSubscriber(ctx context.Context, pool *pgxpool.Pool, consumer string, cfg PGSubscriberConfig) (ports.EventSubscriber, error) {
+	s := newPGEventSubscriber(pool, consumer, true, cfg)
+	pos, err := s.currentPosition(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.q.EnsureEventCursor(ctx, gen.EnsureEventCursorParams{
+		Consumer: consumer,
+		TxID:     pos.TxID,
+		Seq:      pos.Seq,
+	}); err != nil {
+		return nil, err
+	}
+
+	s.start(ctx)
+	return s, nil
+}
+
+// NewPGBroadcastSubscriber keeps its cursor in memory, so every instance
+// reads every event committed after it started.
+func NewPGBroadcastSubscriber(ctx context.Context, pool *pgxpool.Pool, serviceName string, cfg PGSubscriberConfig) (ports.EventSubscriber, error) {
+	s := newPGEventSubscriber(pool, serviceName+"."+uuid.NewString(), false, cfg)
+	pos, err := s.currentPosition(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.txId, s.seq = pos.TxID, pos.Seq
+
+	s.start(ctx)
+	return s, nil
+}
+
+func newPGEventSubscriber(pool *pgxpool.Pool, consumer string, durable bool, cfg PGSubscriberConfig) *PGEventSubscriber {
+	hostname, _ := os.Hostname()
+	return &PGEventSubscriber{
+		pool:       pool,
+		q:          gen.New(pool),
+		listener:   NewPGOutboxListener(pool),
+		cfg:        cfg,
+		consumer:   consumer,
+		owner:      pgtype.Text{String: hostname + "." + uuid.NewString(), Valid: true},
+		durable:    durable,
+		handlerMap: make(map[string]func(context.Context, ports.EventMessage) error),
+		done:       make(chan struct{}),
+	}
+}
+
+func (s *PGEventSubscriber) currentPosition(ctx context.Context) (gen.CurrentOutboxPositionRow, error) {
+	pos, err := s.q.CurrentOutboxPosition(ctx)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return gen.CurrentOutboxPositionRow{}, nil
+	}
+	return pos, err
+}
+
+func (s *PGEventSubscriber) start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	go s.run(ctx)
+	slog.Info("Created new EventSubscriber successfully", "consumer", s.consumer)
+}
+
+func (s *PGEventSubscriber) Subscribe(topic string, handler func(context.Context, ports.EventMessage) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlerMap[topic] = handler
+	return nil
+}
+
+func (s *PGEventSubscriber) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+func (s *PGEventSubscriber) run(ctx context.Context) {
+	defer close(s.done)
+	if s.durable {
+		defer s.q.ReleaseEventCursor(context.WithoutCancel(ctx), gen.ReleaseEventCursorParams{
+			Consumer: s.consumer,
+			Owner:    s.owner,
+		})
+	}
+
+	tickerCh := time.Tick(s.cfg.PollInterval)
+	wakeCh, _ := s.listener.Listen(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-wakeCh:
+			if !ok {
+				// Poll until the next tick listens again
+				wakeCh = nil
+				continue
+			}
+		case <-tickerCh:
+			if wakeCh == nil {
+				wakeCh, _ = s.listener.Listen(ctx)
+			}
+		}
+
+		for {
+			count, err := s.step(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Error("Event subscriber step failed", "consumer", s.consumer, "err", err)
+				}
+				break
+			}
+			if count < int(s.cfg.BatchSize) {
+				break
+			}
+		}
+	}
+}
+
+func (s *PGEventSubscriber) step(ctx context.Context) (int, error) {
+	s.mu.RLock()
+	topics := slices.Collect(maps.Keys(s.handlerMap))
+	s.mu.RUnlock()
+	if len(topics) == 0 {
+		return 0, nil
+	}
+
+	txId, seq := s.txId, s.seq
+	if s.durable {
+		cursor, err := s.claimCursor(ctx)
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Another instance holds the cursor
+			return 0, nil
+		} else if err != nil {
+			return 0, err
+		}
+		txId, seq = cursor.TxID, cursor.Seq
+	}
+
+	rows, err := s.q.ReadOutboxAfter(ctx, gen.ReadOutboxAfterParams{
+		Column1: txId,
+		Column2: seq,
+		Column3: topics,
+		Limit:   s.cfg.BatchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, row := range rows {
+		if err = ctx.Err(); err != nil {
+			return 0, err
+		}
+		if err = s.deliver(ctx, row); err != nil {
+			return 0, err
+		}
+		// Record a handled event even when ctx got cancelled meanwhile
+		if err = s.advance(context.WithoutCancel(ctx), row); err != nil {
+			return 0, err
+		}
+	}
+	return len(rows), nil
+}
+
+func (s *PGEventSubscriber) claimCursor(ctx context.Context) (gen.ClaimEventCursorRow, error) {
+	return s.q.ClaimEventCursor(ctx, gen.ClaimEventCursorParams{
+		Consumer: s.consumer,
+		Owner:    s.owner,
+		Column3:  s.cfg.LeaseDuration,
+	})
+}
+
+func (s *PGEventSubscriber) advance(ctx context.Context, row gen.Outbox) error {
+	if !s.durable {
+		s.txId, s.seq = row.TxID, row.Seq
+		return nil
+	}
+
+	moved, err := s.q.AdvanceEventCursor(ctx, gen.AdvanceEventCursorParams{
+		Consumer: s.consumer,
+		Owner:    s.owner,
+		TxID:     row.TxID,
+		Seq:      row.Seq,
+		Column5:  s.cfg.LeaseDuration,
+	})
+	if err != nil {
+		return err
+	}
+	if moved == 0 {
+		return errCursorLost
+	}
+	return nil
+}
+
+// deliver runs the handler until it succeeds or its retries are used up, only
+// a cancelled ctx or a lost cursor lease is returned as an error. A cancelled
+// ctx stops the retries but never a handler that already started.
+func (s *PGEventSubscriber) deliver(ctx context.Context, row gen.Outbox) error {
+	s.mu.RLock()
+	handler, ok := s.handlerMap[row.EventType]
+	s.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	msg := toOutboxRecord(row).Message()
+	for attempt := 0; ; attempt++ {
+		err := telemetry.Consume(context.WithoutCancel(ctx), s.consumer, msg, handler)
+		if err == nil {
+			return nil
+		}
+		slog.Warn("Event handler failed", "consumer", s.consumer, "eventId", row.ID, "err", err)
+
+		deadLettered := attempt >= len(s.cfg.RetryDelays)
+		telemetry.Retried(s.consumer, row.EventType, deadLettered)
+		if deadLettered {
+			slog.Warn("Event dead lettered", "consumer", s.consumer, "eventId", row.ID, "attempts", attempt+1)
+			return s.q.InsertEventDeadLetter(ctx, gen.InsertEventDeadLetterParams{
+				Consumer:  s.consumer,
+				EventID:   row.ID,
+				LastError: err.Error(),
+			})
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.cfg.RetryDelays[attempt]):
+		}
+		if s.durable {
+			// Keep the lease while retrying
+			if _, err = s.claimCursor(ctx); err != nil {
+				return errors.Join(errCursorLost, err)
+			}
+		}
+	}
+}
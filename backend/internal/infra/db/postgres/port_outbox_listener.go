@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"backend/internal/application/ports"
+	"context"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// OUTBOX_CHANNEL is notified by pullAndPushEvents, Postgres delivers it on
+// commit and folds duplicates of one transaction into a single notification.
+const OUTBOX_CHANNEL = "outbox_events"
+
+type PGOutboxListener struct {
+	pool *pgxpool.Pool
+}
+
+func NewPGOutboxListener(pool *pgxpool.Pool) ports.OutboxListener {
+	return &PGOutboxListener{pool}
+}
+
+func (l *PGOutboxListener) Listen(ctx context.Context) (<-chan struct{}, error) {
+	pooled, err := l.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The connection keeps listening for as long as it lives, take it out of
+	// the pool so it is never handed to anyone else
+	conn := pooled.Hijack()
+	if _, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{OUTBOX_CHANNEL}.Sanitize()); err != nil {
+		conn.Close(context.Background())
+		return nil, err
+	}
+
+	wake := make(chan struct{}, 1)
+	go func() {
+		defer close(wake)
+		defer conn.Close(context.Background())
+		for {
+			if _, err := conn.WaitForNotification(ctx); err != nil {
+				if ctx.Err() == nil {
+					slog.Default().Error("outbox listen connection dropped", "err", err)
+				}
+				return
+			}
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return wake, nil
+}
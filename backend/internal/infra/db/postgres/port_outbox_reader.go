@@ -2,12 +2,17 @@ package postgres
 
 import (
 	"backend/internal/application/ports"
+	"backend/internal/domain/entities"
 	"backend/internal/infra/db/postgres/gen"
 	"context"
+	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gookit/goutil/arrutil"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -20,40 +25,131 @@ func NewPGOutboxReader(pool *pgxpool.Pool) ports.OutboxReader {
 	return &PGOutboxReader{pool, gen.New(pool)}
 }
 
-func (r *PGOutboxReader) ClaimBatch(ctx context.Context, limit int32, staleAfter time.Duration) ([]ports.OutboxRecord, error) {
+func toOutboxRecord(row gen.Outbox) ports.OutboxRecord {
+	var traceContext map[string]string
+	if row.TraceContext != nil {
+		// A broken trace context only costs the trace, never the event
+		_ = json.Unmarshal(row.TraceContext, &traceContext)
+	}
+	return ports.OutboxRecord{
+		ID:            row.ID,
+		AggregateName: row.AggregateName,
+		AggregateID:   row.AggregateID,
+		EventType:     row.EventType,
+		SchemaVersion: row.SchemaVersion,
+		OccurredAt:    row.OccurredAt,
+		Seq:           row.Seq,
+		Status:        row.Status,
+		Attempts:      row.Attempts,
+		ClaimedAt:     row.ClaimedAt,
+		PublishedAt:   row.PublishedAt,
+		NextAttemptAt: row.NextAttemptAt,
+		LastError:     row.LastError.String,
+		Payload:       row.Payload,
+		TraceContext:  traceContext,
+	}
+}
+
+func toOutboxRecords(rows []gen.Outbox) []ports.OutboxRecord {
+	return arrutil.Map(rows, func(row gen.Outbox) (target ports.OutboxRecord, find bool) {
+		return toOutboxRecord(row), true
+	})
+}
+
+func (r *PGOutboxReader) ClaimBatch(ctx context.Context, limit int32, staleAfter time.Duration, shardCount int32, shards []int32) ([]ports.OutboxRecord, error) {
 	batch, err := r.q.ClaimOutboxBatch(ctx, gen.ClaimOutboxBatchParams{
 		Column1: staleAfter,
 		Limit:   limit,
+		Column3: shardCount,
+		Column4: shards,
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	return arrutil.Map(batch, func(row gen.Outbox) (target ports.OutboxRecord, find bool) {
-		return ports.OutboxRecord{
-			ID:            row.ID,
-			AggregateName: row.AggregateName,
-			AggregateID:   row.AggregateID,
-			EventType:     row.EventType,
-			SchemaVersion: row.SchemaVersion,
-			OccurredAt:    row.OccurredAt,
-			Status:        row.Status,
-			Attempts:      row.Attempts,
-			ClaimedAt:     row.ClaimedAt,
-			PublishedAt:   row.PublishedAt,
-			Payload:       row.Payload,
-		}, true
-	}), nil
+	return toOutboxRecords(batch), nil
 }
 
 func (r *PGOutboxReader) MarkDispatched(ctx context.Context, id uuid.UUID) error {
 	return r.q.MarkedOutboxDispatched(ctx, id)
 }
 
-func (r *PGOutboxReader) Requeue(ctx context.Context, id uuid.UUID) error {
-	return r.q.RequeueOutbox(ctx, id)
+func (r *PGOutboxReader) Retry(ctx context.Context, id uuid.UUID, delay time.Duration, lastErr string) error {
+	return r.q.RetryOutbox(ctx, gen.RetryOutboxParams{
+		ID:        id,
+		Column2:   delay,
+		LastError: pgtype.Text{String: lastErr, Valid: true},
+	})
 }
 
-func (r *PGOutboxReader) MarkFailed(ctx context.Context, id uuid.UUID) error {
-	return r.q.MarkedOutboxFailed(ctx, id)
+func (r *PGOutboxReader) DeadLetter(ctx context.Context, id uuid.UUID, lastErr string) error {
+	return r.q.DeadLetterOutbox(ctx, gen.DeadLetterOutboxParams{
+		ID:        id,
+		LastError: pgtype.Text{String: lastErr, Valid: true},
+	})
+}
+
+func (r *PGOutboxReader) List(ctx context.Context, filter ports.EventFilter, afterSeq int64, limit int32) ([]ports.OutboxRecord, error) {
+	params := gen.ListOutboxEventsParams{
+		AfterSeq:   afterSeq,
+		EventTypes: filter.EventTypes,
+		BatchSize:  limit,
+	}
+	if params.EventTypes == nil {
+		params.EventTypes = []string{}
+	}
+	if filter.AggregateID != uuid.Nil {
+		params.AggregateID = &filter.AggregateID
+	}
+	if !filter.Since.IsZero() {
+		params.Since = &filter.Since
+	}
+	if !filter.Until.IsZero() {
+		params.Until = &filter.Until
+	}
+
+	rows, err := r.q.ListOutboxEvents(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return toOutboxRecords(rows), nil
+}
+
+func (r *PGOutboxReader) ListDeadLettered(ctx context.Context, limit, offset int32) ([]ports.OutboxRecord, error) {
+	rows, err := r.q.ListDeadLetteredOutbox(ctx, gen.ListDeadLetteredOutboxParams{
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toOutboxRecords(rows), nil
+}
+
+func (r *PGOutboxReader) Get(ctx context.Context, id uuid.UUID) (ports.OutboxRecord, error) {
+	row, err := r.q.FindOutboxById(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ports.OutboxRecord{}, entities.NewError(entities.ErrCodeNoObject, "outbox event not found", nil)
+	} else if err != nil {
+		return ports.OutboxRecord{}, err
+	}
+
+	return toOutboxRecord(row), nil
+}
+
+func (r *PGOutboxReader) Retained(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error) {
+	return r.q.FindOutboxIds(ctx, ids)
+}
+
+func (r *PGOutboxReader) Requeue(ctx context.Context, id uuid.UUID) error {
+	affected, err := r.q.RequeueOutbox(ctx, id)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return entities.NewError(entities.ErrCodeNoObject, "no dead lettered outbox event with this id", nil)
+	}
+	return nil
 }
@@ -0,0 +1,68 @@
+package postgres
+
+import (
+	"backend/internal/application/ports"
+	"backend/internal/infra/db/postgres/gen"
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PGOutboxRetention struct {
+	pool    *pgxpool.Pool
+	q       *gen.Queries
+	cursors bool
+}
+
+// NewPGOutboxRetention purges dispatched records. With cursors set, for the
+// postgres event bus where nothing is ever dispatched, it purges the records
+// every event_cursors consumer already read instead.
+func NewPGOutboxRetention(pool *pgxpool.Pool, cursors bool) ports.OutboxRetention {
+	return &PGOutboxRetention{pool, gen.New(pool), cursors}
+}
+
+func (r *PGOutboxRetention) Purge(ctx context.Context, cutoff time.Time, limit int32, archive func([]ports.OutboxRecord) error) (int, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	rows, err := r.q.WithTx(tx).PurgeOutbox(ctx, gen.PurgeOutboxParams{
+		OccurredAt: cutoff,
+		Column2:    r.cursors,
+		Limit:      limit,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	if archive != nil {
+		if err = archive(toOutboxRecords(rows)); err != nil {
+			return 0, err
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return len(rows), nil
+}
+
+func (r *PGOutboxRetention) Import(ctx context.Context, rec ports.OutboxRecord) (bool, error) {
+	inserted, err := r.q.ImportOutboxEvent(ctx, gen.ImportOutboxEventParams{
+		ID:            rec.ID,
+		AggregateName: rec.AggregateName,
+		AggregateID:   rec.AggregateID,
+		EventType:     rec.EventType,
+		SchemaVersion: rec.SchemaVersion,
+		OccurredAt:    rec.OccurredAt,
+		Payload:       rec.Payload,
+		Status:        rec.Status,
+		PublishedAt:   rec.PublishedAt,
+	})
+	return inserted > 0, err
+}
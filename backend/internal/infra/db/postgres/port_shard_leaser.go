@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"backend/internal/application/ports"
+	"backend/internal/infra/db/postgres/gen"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PGShardLeaser struct {
+	pool *pgxpool.Pool
+	q    *gen.Queries
+}
+
+func NewPGShardLeaser(pool *pgxpool.Pool) ports.ShardLeaser {
+	return &PGShardLeaser{pool, gen.New(pool)}
+}
+
+func (l *PGShardLeaser) Acquire(ctx context.Context, owner string, shardCount int32, lease time.Duration) ([]int32, error) {
+	tx, err := l.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	q := l.q.WithTx(tx)
+	ownerText := pgtype.Text{String: owner, Valid: true}
+
+	if err = q.UpsertOutboxRelayer(ctx, owner); err != nil {
+		return nil, err
+	}
+	if err = q.EnsureOutboxShards(ctx, shardCount); err != nil {
+		return nil, err
+	}
+	live, err := q.CountLiveOutboxRelayers(ctx, lease)
+	if err != nil {
+		return nil, err
+	}
+	owned, err := q.RenewOutboxShardLeases(ctx, gen.RenewOutboxShardLeasesParams{
+		Owner:   ownerText,
+		Column2: lease,
+		Shard:   shardCount,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	owned, release, acquire := rebalance(shardCount, live, owned)
+	if len(release) > 0 {
+		// Another relayer joined, hand the surplus back so it can pick it up
+		err = q.ReleaseOutboxShardLeases(ctx, gen.ReleaseOutboxShardLeasesParams{
+			Owner:   ownerText,
+			Column2: release,
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else if acquire > 0 {
+		acquired, err := q.AcquireOutboxShardLeases(ctx, gen.AcquireOutboxShardLeasesParams{
+			Owner:   ownerText,
+			Column2: lease,
+			Shard:   shardCount,
+			Limit:   acquire,
+		})
+		if err != nil {
+			return nil, err
+		}
+		owned = append(owned, acquired...)
+		slices.Sort(owned)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return owned, nil
+}
+
+// rebalance splits shardCount shards fairly between live relayers: each one
+// owns at most the ceiling of the share, keeps its lowest shards, releases the
+// rest and asks for as many free shards as it is short of.
+func rebalance(shardCount int32, live int64, owned []int32) (keep, release []int32, acquire int32) {
+	live = max(live, 1)
+	target := int32((int64(shardCount) + live - 1) / live)
+
+	keep = slices.Sorted(slices.Values(owned))
+	if extra := int32(len(keep)) - target; extra > 0 {
+		return keep[:target], keep[target:], 0
+	}
+	return keep, nil, target - int32(len(keep))
+}
+
+func (l *PGShardLeaser) Release(ctx context.Context, owner string) error {
+	err := l.q.ReleaseAllOutboxShardLeases(ctx, pgtype.Text{String: owner, Valid: true})
+	if err != nil {
+		return err
+	}
+	return l.q.DeleteOutboxRelayer(ctx, owner)
+}
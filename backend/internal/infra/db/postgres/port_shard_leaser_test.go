@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRebalance(t *testing.T) {
+	tests := []struct {
+		name        string
+		shardCount  int32
+		live        int64
+		owned       []int32
+		wantKeep    []int32
+		wantRelease []int32
+		wantAcquire int32
+	}{
+		{name: "alone takes every shard", shardCount: 4, live: 1, wantAcquire: 4},
+		{name: "not counted live yet", shardCount: 4, live: 0, owned: []int32{0, 1}, wantKeep: []int32{0, 1}, wantAcquire: 2},
+		{name: "fair share held", shardCount: 4, live: 2, owned: []int32{2, 3}, wantKeep: []int32{2, 3}},
+		{name: "fewer shards than instances", shardCount: 2, live: 3, wantAcquire: 1},
+		{name: "fewer shards than instances, surplus", shardCount: 2, live: 3, owned: []int32{1, 0}, wantKeep: []int32{0}, wantRelease: []int32{1}},
+		{name: "uneven split rounds up", shardCount: 10, live: 3, owned: []int32{0, 1}, wantKeep: []int32{0, 1}, wantAcquire: 2},
+		{name: "uneven split keeps the lowest shards", shardCount: 10, live: 4, owned: []int32{9, 2, 5, 7}, wantKeep: []int32{2, 5, 7}, wantRelease: []int32{9}},
+		{name: "instance joined", shardCount: 8, live: 2, owned: []int32{0, 1, 2, 3, 4, 5, 6, 7}, wantKeep: []int32{0, 1, 2, 3}, wantRelease: []int32{4, 5, 6, 7}},
+		{name: "instance left", shardCount: 8, live: 1, owned: []int32{4, 5, 6, 7}, wantKeep: []int32{4, 5, 6, 7}, wantAcquire: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keep, release, acquire := rebalance(tt.shardCount, tt.live, tt.owned)
+			if !slices.Equal(keep, tt.wantKeep) || !slices.Equal(release, tt.wantRelease) || acquire != tt.wantAcquire {
+				t.Errorf("rebalance = %v, %v, %d, want %v, %v, %d", keep, release, acquire, tt.wantKeep, tt.wantRelease, tt.wantAcquire)
+			}
+		})
+	}
+}
+
+// TestRebalanceConverges runs rounds of Acquire against an in memory lease
+// table while instances join and leave, and checks every shard ends up owned
+// by exactly one live instance holding no more than its fair share.
+func TestRebalanceConverges(t *testing.T) {
+	tests := []struct {
+		name       string
+		shardCount int32
+		instances  []int // live instances for each phase
+	}{
+		{name: "scale out", shardCount: 8, instances: []int{1, 2, 3}},
+		{name: "scale in", shardCount: 8, instances: []int{4, 2, 1}},
+		{name: "more instances than shards", shardCount: 2, instances: []int{1, 3, 2}},
+		{name: "uneven churn", shardCount: 10, instances: []int{3, 4, 1, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner := map[int32]int{} // shard -> instance, missing when free
+			for _, live := range tt.instances {
+				// Leases of the instances that left expire
+				for shard, o := range owner {
+					if o >= live {
+						delete(owner, shard)
+					}
+				}
+				for range 3 {
+					for instance := range live {
+						var owned []int32
+						for shard, o := range owner {
+							if o == instance {
+								owned = append(owned, shard)
+							}
+						}
+						_, release, acquire := rebalance(tt.shardCount, int64(live), owned)
+						for _, shard := range release {
+							delete(owner, shard)
+						}
+						for shard := range tt.shardCount {
+							if _, taken := owner[shard]; !taken && acquire > 0 {
+								owner[shard] = instance
+								acquire--
+							}
+						}
+					}
+				}
+
+				target := (int(tt.shardCount) + live - 1) / live
+				counts := make([]int, live)
+				for shard := range tt.shardCount {
+					o, ok := owner[shard]
+					if !ok {
+						t.Fatalf("%d instances: shard %d is not owned", live, shard)
+					}
+					counts[o]++
+				}
+				for instance, count := range counts {
+					if count > target {
+						t.Errorf("%d instances: instance %d owns %d shards, more than %d", live, instance, count, target)
+					}
+				}
+			}
+		})
+	}
+}
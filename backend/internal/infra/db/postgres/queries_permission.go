@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"backend/internal/application/interfaces"
+	"backend/internal/application/query"
+	"backend/internal/domain/entities"
+	"backend/internal/infra/db/postgres/gen"
+	"context"
+	"errors"
+
+	"github.com/gookit/goutil/arrutil"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PGPermissionQueries struct {
+	q *gen.Queries
+}
+
+func NewPGPermissionQueries(pool *pgxpool.Pool) interfaces.PermissionQueries {
+	return &PGPermissionQueries{gen.New(pool)}
+}
+
+func (q *PGPermissionQueries) GetChannelPermissions(ctx context.Context, params query.GetChannelPermissions) (res query.GetChannelPermissionsResult, err error) {
+	c, err := q.q.FindChannelById(ctx, params.ChannelId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return res, entities.NewError(entities.ErrCodeNoObject, "channel not found", nil)
+	} else if err != nil {
+		return res, entities.NewError(entities.ErrCodeDepFail, "cannot get channel", err)
+	}
+
+	server, err := q.q.FindServerById(ctx, c.ServerID)
+	if err != nil {
+		return res, entities.NewError(entities.ErrCodeDepFail, "cannot get server", err)
+	}
+
+	_, err = q.q.FindMembership(ctx, gen.FindMembershipParams{UserID: params.UserId, ServerID: c.ServerID})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return res, entities.NewError(entities.ErrCodeNoObject, "user not in the channel's server", nil)
+	} else if err != nil {
+		return res, entities.NewError(entities.ErrCodeDepFail, "cannot get membership", err)
+	}
+
+	rolesPerm, err := q.q.FindAllUserServerRolePermission(ctx, gen.FindAllUserServerRolePermissionParams{
+		UserID:   params.UserId,
+		ServerID: c.ServerID,
+	})
+	if err != nil {
+		return res, entities.NewError(entities.ErrCodeDepFail, "cannot get user permission (roles)", err)
+	}
+
+	roleOverwrite, err := q.q.FindAllChannelUserServerRoleOverwrite(ctx, gen.FindAllChannelUserServerRoleOverwriteParams{
+		UserID:   params.UserId,
+		ServerID: c.ServerID,
+	})
+	if err != nil {
+		return res, entities.NewError(entities.ErrCodeDepFail, "cannot get channel permission (role)", err)
+	}
+
+	userOverwrite, err := q.q.FindAllChannelServerUserOverwrite(ctx, gen.FindAllChannelServerUserOverwriteParams{
+		UserID:   params.UserId,
+		ServerID: c.ServerID,
+	})
+	if err != nil {
+		return res, entities.NewError(entities.ErrCodeDepFail, "cannot get channel permission (user)", err)
+	}
+
+	chanEffPerms := effectivePermPerChannel(getChannelsHelperParams{
+		Channels: []channel{{id: c.ID, serverId: c.ServerID}},
+		RolePerms: arrutil.Map(rolesPerm, func(row gen.FindAllUserServerRolePermissionRow) (target rolePerm, find bool) {
+			return rolePerm{serverId: row.ServerID, roleId: row.RoleID, permission: row.Permissions}, true
+		}),
+		UserOverwrite: arrutil.Map(userOverwrite, func(row gen.FindAllChannelServerUserOverwriteRow) (target overwrite, find bool) {
+			return overwrite{allow: row.Allow, deny: row.Deny, channelId: row.ChannelID, targetId: params.UserId}, true
+		}),
+		RoleOverwrite: arrutil.Map(roleOverwrite, func(row gen.FindAllChannelUserServerRoleOverwriteRow) (target overwrite, find bool) {
+			return overwrite{allow: row.Allow, deny: row.Deny, channelId: row.ChannelID, targetId: row.RoleID}, true
+		}),
+	})
+
+	return query.GetChannelPermissionsResult{
+		ServerId:    c.ServerID,
+		Owner:       server.Owner == params.UserId,
+		Permissions: entities.ServerPermissionBits(chanEffPerms[c.ID]),
+	}, nil
+}
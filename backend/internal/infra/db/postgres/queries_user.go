@@ -31,3 +31,14 @@ func (q *PGUserQueries) GetBasic(ctx context.Context, id uuid.UUID) (common.User
 
 	return toCommonUser(u), nil
 }
+
+func (q *PGUserQueries) GetByUsername(ctx context.Context, username string) (common.UserResult, error) {
+	u, err := q.q.FindUserByUsername(ctx, username)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return common.UserResult{}, entities.NewError(entities.ErrCodeNoObject, "user not found", nil)
+	} else if err != nil {
+		return common.UserResult{}, entities.NewError(entities.ErrCodeDepFail, "cannot get user", err)
+	}
+
+	return toCommonUser(u), nil
+}
@@ -0,0 +1,23 @@
+package postgres
+
+import (
+	"backend/internal/infra/db/postgres/gen"
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type PGInboxRepo struct {
+	repo *gen.Queries
+}
+
+func (r *PGInboxRepo) MarkProcessed(ctx context.Context, consumer string, eventId uuid.UUID) (bool, error) {
+	inserted, err := r.repo.MarkInboxProcessed(ctx, gen.MarkInboxProcessedParams{
+		Consumer: consumer,
+		EventID:  eventId,
+	})
+	if err != nil {
+		return false, err
+	}
+	return inserted > 0, nil
+}
@@ -74,7 +74,28 @@ func (r *PGMessageRepo) Save(ctx context.Context, msg *e.Message) (*e.Message, e
 		return nil, err
 	}
 
-	if err = pullAndPushEvents(ctx, r.q, msg.PullsEvents()); err != nil {
+	evts := msg.PullsEvents()
+	for _, evt := range evts {
+		switch reaction := evt.(type) {
+		case e.MessageReactionAdded:
+			err = r.q.InsertReaction(ctx, gen.InsertReactionParams{
+				MessageID: m.ID,
+				UserID:    reaction.UserID,
+				EmoteID:   reaction.EmoteID,
+			})
+		case e.MessageReactionRemoved:
+			err = r.q.DeleteReaction(ctx, gen.DeleteReactionParams{
+				MessageID: m.ID,
+				UserID:    reaction.UserID,
+				EmoteID:   reaction.EmoteID,
+			})
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = pullAndPushEvents(ctx, r.q, evts); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,28 @@
+package postgres
+
+import (
+	"backend/internal/infra/db/postgres/gen"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PGStreamTicketRepo struct {
+	repo *gen.Queries
+}
+
+func (r *PGStreamTicketRepo) Redeem(ctx context.Context, ticketId uuid.UUID, expiresAt time.Time) (bool, error) {
+	// Tickets live for seconds, the expired ones are few and indexed
+	if err := r.repo.DeleteExpiredStreamTickets(ctx); err != nil {
+		return false, err
+	}
+	inserted, err := r.repo.RedeemStreamTicket(ctx, gen.RedeemStreamTicketParams{
+		TicketID:  ticketId,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return false, err
+	}
+	return inserted > 0, nil
+}
@@ -43,6 +43,7 @@ func (r *PGUserRepo) Save(ctx context.Context, user *e.User) error {
 		AvatarUrl:   user.AvatarUrl,
 		BannerUrl:   user.BannerUrl,
 		Flags:       int16(user.Flags),
+		Verified:    user.Verified,
 	})
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"backend/internal/infra/telemetry"
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("backend/internal/infra/db/postgres")
+
+// NewPool connects like pgxpool.New, with a span around every query.
+func NewPool(ctx context.Context, uri string) (*pgxpool.Pool, error) {
+	cfg, err := pgxpool.ParseConfig(uri)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ConnConfig.Tracer = queryTracer{}
+	return pgxpool.NewWithConfig(ctx, cfg)
+}
+
+// queryTracer names each span after the sqlc query, e.g. "InsertEventToOutbox".
+type queryTracer struct{}
+
+func (queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx, _ = tracer.Start(ctx, queryName(data.SQL),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "postgresql"),
+			attribute.String("db.query.text", data.SQL),
+		),
+	)
+	return ctx
+}
+
+func (queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.Err == nil {
+		span.SetAttributes(attribute.Int64("db.response.returned_rows", data.CommandTag.RowsAffected()))
+	}
+	telemetry.End(span, data.Err)
+}
+
+// queryName reads the name sqlc puts in front of its queries, other
+// statements are named after their first keyword.
+func queryName(sql string) string {
+	sql = strings.TrimSpace(sql)
+	if rest, ok := strings.CutPrefix(sql, "-- name: "); ok {
+		name, _, _ := strings.Cut(rest, " ")
+		return name
+	}
+	keyword, _, _ := strings.Cut(sql, " ")
+	return strings.ToUpper(keyword)
+}
@@ -4,9 +4,14 @@ import (
 	"backend/internal/domain/entities"
 	"backend/internal/domain/repositories"
 	"backend/internal/infra/db/postgres/gen"
+	"backend/internal/infra/telemetry"
 	"context"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type pgRepoBundle struct {
@@ -29,6 +34,9 @@ func (b *pgRepoBundle) DMGroup() repositories.DMGroupRepo {
 func (b *pgRepoBundle) Emote() repositories.EmoteRepo {
 	return &PGEmoteRepo{b.q}
 }
+func (b *pgRepoBundle) Inbox() repositories.InboxRepo {
+	return &PGInboxRepo{b.q}
+}
 func (b *pgRepoBundle) Invitation() repositories.InvitationRepo {
 	return &PGInvitationRepo{b.q}
 }
@@ -44,6 +52,9 @@ func (b *pgRepoBundle) Server() repositories.ServerRepo {
 func (b *pgRepoBundle) Session() repositories.SessionRepo {
 	return &PGSessionRepo{b.q}
 }
+func (b *pgRepoBundle) StreamTicket() repositories.StreamTicketRepo {
+	return &PGStreamTicketRepo{b.q}
+}
 func (b *pgRepoBundle) UserNotification() repositories.UserNotificationRepo {
 	return &PGUserNotiRepo{b.q}
 }
@@ -57,8 +68,28 @@ type baseUoW struct {
 
 func NewBaseUoW(pool *pgxpool.Pool) repositories.BaseUnitOfWork { return &baseUoW{pool} }
 
+type txCtxKey struct{}
+
 func (u *baseUoW) Do(ctx context.Context, fn func(ctx context.Context, repos repositories.RepoBundle) error) error {
-	tx, err := u.pool.Begin(ctx)
+	_, nested := ctx.Value(txCtxKey{}).(pgx.Tx)
+	ctx, span := tracer.Start(ctx, "UnitOfWork.Do", trace.WithAttributes(attribute.Bool("db.transaction.nested", nested)))
+	start := time.Now()
+	err := u.do(ctx, fn)
+	observeUoW(nested, start, err)
+	telemetry.End(span, err)
+	return err
+}
+
+func (u *baseUoW) do(ctx context.Context, fn func(ctx context.Context, repos repositories.RepoBundle) error) error {
+	var tx pgx.Tx
+	var err error
+	// A Do running inside another one joins its transaction as a savepoint,
+	// so e.g. an inbox record commits together with the handler's writes
+	if outer, ok := ctx.Value(txCtxKey{}).(pgx.Tx); ok {
+		tx, err = outer.Begin(ctx)
+	} else {
+		tx, err = u.pool.Begin(ctx)
+	}
 	if err != nil {
 		return entities.NewError(entities.ErrCodeDepFail, "Cannot start a transaction", err)
 	}
@@ -67,7 +98,7 @@ func (u *baseUoW) Do(ctx context.Context, fn func(ctx context.Context, repos rep
 	qtx := gen.New(tx)
 	full := newRepoBundle(qtx)
 
-	if err = fn(ctx, full); err != nil {
+	if err = fn(context.WithValue(ctx, txCtxKey{}, tx), full); err != nil {
 		return err
 	}
 	return entities.GetErrOrDefault(tx.Commit(ctx), entities.ErrCodeDepFail, "Cannot commit changes")
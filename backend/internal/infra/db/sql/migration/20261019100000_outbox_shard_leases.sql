@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Maps an aggregate to one of shard_count shards, every event of an aggregate
+-- lands on the same shard and so on the same relayer
+CREATE FUNCTION outbox_shard(aggregate_id UUID, shard_count INT) RETURNS INT
+  LANGUAGE SQL IMMUTABLE PARALLEL SAFE
+  RETURN mod(hashtext(aggregate_id::text)::bigint + 2147483648, shard_count)::int;
+
+CREATE TABLE outbox_relayers (
+  id TEXT NOT NULL PRIMARY KEY,
+  seen_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE TABLE outbox_shard_leases (
+  shard INT NOT NULL PRIMARY KEY,
+  owner TEXT,
+  expires_at TIMESTAMP WITH TIME ZONE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE outbox_shard_leases;
+DROP TABLE outbox_relayers;
+DROP FUNCTION outbox_shard;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- occurred_at can tie within one transaction, seq keeps insertion order
+ALTER TABLE outbox ADD COLUMN seq BIGINT GENERATED ALWAYS AS IDENTITY;
+
+CREATE INDEX idx_outbox_aggregate_undelivered
+ON outbox (aggregate_id, seq)
+WHERE status IN ('pending','inflight');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_outbox_aggregate_undelivered;
+ALTER TABLE outbox DROP COLUMN seq;
+-- +goose StatementEnd
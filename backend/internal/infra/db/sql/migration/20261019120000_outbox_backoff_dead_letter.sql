@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE outbox
+  ADD COLUMN next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  ADD COLUMN last_error TEXT;
+
+-- status: pending|inflight|dispatched|dead_lettered
+UPDATE outbox SET status = 'dead_lettered' WHERE status = 'failed';
+
+CREATE INDEX idx_outbox_dead_lettered
+ON outbox (seq)
+WHERE status = 'dead_lettered';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_outbox_dead_lettered;
+UPDATE outbox SET status = 'failed' WHERE status = 'dead_lettered';
+ALTER TABLE outbox
+  DROP COLUMN last_error,
+  DROP COLUMN next_attempt_at;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Events each consumer already handled, written in the handler's transaction
+CREATE TABLE inbox (
+  consumer TEXT NOT NULL,
+  event_id UUID NOT NULL,
+  processed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  PRIMARY KEY (consumer, event_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE inbox;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Subscribers of the postgres event bus read the outbox in (tx_id, seq) order
+-- and only up to the oldest running transaction, so an event committed late
+-- can never sort before a cursor that already moved past it
+ALTER TABLE outbox ADD COLUMN tx_id BIGINT NOT NULL DEFAULT pg_current_xact_id()::text::bigint;
+
+CREATE INDEX idx_outbox_tx_seq ON outbox (tx_id, seq);
+
+CREATE TABLE event_cursors (
+  consumer TEXT NOT NULL PRIMARY KEY,
+  tx_id BIGINT NOT NULL,
+  seq BIGINT NOT NULL,
+  owner TEXT,
+  lease_until TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE event_dead_letters (
+  consumer TEXT NOT NULL,
+  event_id UUID NOT NULL,
+  last_error TEXT NOT NULL,
+  failed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  PRIMARY KEY (consumer, event_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE event_dead_letters;
+DROP TABLE event_cursors;
+DROP INDEX idx_outbox_tx_seq;
+ALTER TABLE outbox DROP COLUMN tx_id;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX idx_outbox_dispatched
+ON outbox (occurred_at)
+WHERE status = 'dispatched';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_outbox_dispatched;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- W3C trace context of the transaction that wrote the event, e.g.
+-- {"traceparent": "00-...-01"}, so the trace continues in every consumer
+ALTER TABLE outbox ADD COLUMN trace_context JSONB;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE outbox DROP COLUMN trace_context;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Backfilling every existing user as verified is intended: before the flag
+-- was stored the user repository mapped every row with Verified true, so
+-- that is what they were. New users start unverified.
+ALTER TABLE users ADD COLUMN verified BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE users SET verified = TRUE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN verified;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Stream tickets already used to open a stream, shared by every gateway
+-- instance so a ticket opens one stream in total. Kept until the ticket
+-- expires, an expired ticket is refused anyway.
+CREATE TABLE stream_ticket_redemptions (
+  ticket_id UUID PRIMARY KEY,
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  redeemed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+CREATE INDEX stream_ticket_redemptions_expires_at_idx ON stream_ticket_redemptions (expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE stream_ticket_redemptions;
+-- +goose StatementEnd
@@ -0,0 +1,8 @@
+// Package migration embeds the goose migrations so binaries can apply them
+// without the source tree.
+package migration
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,40 @@
+-- name: CurrentOutboxPosition :one
+SELECT tx_id, seq FROM outbox
+WHERE tx_id < pg_snapshot_xmin(pg_current_snapshot())::text::bigint
+ORDER BY tx_id DESC, seq DESC
+LIMIT 1;
+
+-- name: ReadOutboxAfter :many
+SELECT * FROM outbox
+WHERE (tx_id, seq) > ($1::bigint, $2::bigint)
+  AND tx_id < pg_snapshot_xmin(pg_current_snapshot())::text::bigint
+  AND event_type = ANY($3::text[])
+ORDER BY tx_id, seq
+LIMIT $4;
+
+-- name: EnsureEventCursor :exec
+INSERT INTO event_cursors (consumer, tx_id, seq) VALUES ($1, $2, $3)
+ON CONFLICT (consumer) DO NOTHING;
+
+-- name: ClaimEventCursor :one
+UPDATE event_cursors
+SET owner       = $2,
+    lease_until = now() + $3::interval
+WHERE consumer = $1
+  AND (owner = $2 OR owner IS NULL OR lease_until < now())
+RETURNING tx_id, seq;
+
+-- name: AdvanceEventCursor :execrows
+UPDATE event_cursors
+SET tx_id       = $3,
+    seq         = $4,
+    lease_until = now() + $5::interval
+WHERE consumer = $1 AND owner = $2;
+
+-- name: ReleaseEventCursor :exec
+UPDATE event_cursors SET owner = NULL, lease_until = NULL
+WHERE consumer = $1 AND owner = $2;
+
+-- name: InsertEventDeadLetter :exec
+INSERT INTO event_dead_letters (consumer, event_id, last_error) VALUES ($1, $2, $3)
+ON CONFLICT (consumer, event_id) DO UPDATE SET last_error = excluded.last_error, failed_at = now();
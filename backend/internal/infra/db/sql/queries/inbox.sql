@@ -0,0 +1,3 @@
+-- name: MarkInboxProcessed :execrows
+INSERT INTO inbox (consumer, event_id) VALUES ($1, $2)
+ON CONFLICT (consumer, event_id) DO NOTHING;
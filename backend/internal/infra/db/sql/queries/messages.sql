@@ -46,3 +46,10 @@ WHERE m.channel_id = $1 AND m.created_at < $2 AND m.deleted_at IS NULL ORDER BY
 SELECT m.*, u.display_name, u.avatar_url FROM messages m
 JOIN users u ON m.author_id = u.id
 WHERE m.group_id = $1 AND m.created_at < $2 AND m.deleted_at IS NULL ORDER BY m.created_at DESC LIMIT $3;
+
+-- name: InsertReaction :exec
+INSERT INTO reactions (message_id, user_id, emote_id) VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteReaction :exec
+DELETE FROM reactions WHERE message_id = $1 AND user_id = $2 AND emote_id = $3;
@@ -6,18 +6,34 @@ INSERT INTO outbox(
   event_type,
   schema_version,
   occurred_at,
-  payload
+  payload,
+  trace_context
 ) 
-VALUES ($1, $2, $3, $4, $5, $6, $7) 
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8) 
 RETURNING *;
 
+-- name: NotifyOutbox :exec
+SELECT pg_notify('outbox_events', '');
+
 -- name: ClaimOutboxBatch :many
 WITH candidates AS (
   SELECT o.id
   FROM outbox o
   WHERE o.status IN ('pending', 'inflight')
-    AND (o.status = 'pending' OR o.claimed_at < now() - $1::interval)
-  ORDER BY o.occurred_at
+    AND (
+      (o.status = 'pending' AND o.next_attempt_at <= now())
+      OR o.claimed_at < now() - $1::interval
+    )
+    AND ($3::int = 0 OR outbox_shard(o.aggregate_id, $3::int) = ANY($4::int[]))
+    -- only the oldest undelivered event of an aggregate is claimable
+    AND NOT EXISTS (
+      SELECT 1
+      FROM outbox p
+      WHERE p.aggregate_id = o.aggregate_id
+        AND p.seq < o.seq
+        AND p.status IN ('pending', 'inflight')
+    )
+  ORDER BY o.seq
   FOR UPDATE SKIP LOCKED
   LIMIT $2
 )
@@ -32,8 +48,132 @@ RETURNING o.*;
 -- name: MarkedOutboxDispatched :exec
 UPDATE outbox SET status = 'dispatched', published_at = NOW() WHERE id = $1;
 
--- name: MarkedOutboxFailed :exec
-UPDATE outbox SET status = 'failed' WHERE id = $1;
+-- name: RetryOutbox :exec
+UPDATE outbox
+SET status          = 'pending',
+    claimed_at      = NULL,
+    next_attempt_at = now() + $2::interval,
+    last_error      = $3
+WHERE id = $1;
+
+-- name: DeadLetterOutbox :exec
+UPDATE outbox SET status = 'dead_lettered', last_error = $2 WHERE id = $1;
+
+-- name: ListDeadLetteredOutbox :many
+SELECT * FROM outbox
+WHERE status = 'dead_lettered'
+ORDER BY seq
+LIMIT $1 OFFSET $2;
+
+-- name: FindOutboxById :one
+SELECT * FROM outbox WHERE id = $1;
+
+-- name: FindOutboxIds :many
+SELECT id FROM outbox WHERE id = ANY(@ids::uuid[]);
+
+-- name: RequeueOutbox :execrows
+UPDATE outbox
+SET status          = 'pending',
+    attempts        = 0,
+    claimed_at      = NULL,
+    next_attempt_at = now()
+WHERE id = $1 AND status = 'dead_lettered';
+
+-- name: UpsertOutboxRelayer :exec
+INSERT INTO outbox_relayers (id, seen_at) VALUES ($1, now())
+ON CONFLICT (id) DO UPDATE SET seen_at = now();
+
+-- name: DeleteOutboxRelayer :exec
+DELETE FROM outbox_relayers WHERE id = $1;
+
+-- name: CountLiveOutboxRelayers :one
+SELECT count(*) FROM outbox_relayers WHERE seen_at > now() - $1::interval;
+
+-- name: EnsureOutboxShards :exec
+INSERT INTO outbox_shard_leases (shard)
+SELECT generate_series(0, $1::int - 1)
+ON CONFLICT (shard) DO NOTHING;
+
+-- name: RenewOutboxShardLeases :many
+UPDATE outbox_shard_leases
+SET expires_at = now() + $2::interval
+WHERE owner = $1 AND shard < $3
+RETURNING shard;
+
+-- name: AcquireOutboxShardLeases :many
+WITH free AS (
+  SELECT l.shard
+  FROM outbox_shard_leases l
+  WHERE (l.owner IS NULL OR l.expires_at < now()) AND l.shard < $3
+  ORDER BY l.shard
+  FOR UPDATE SKIP LOCKED
+  LIMIT $4
+)
+UPDATE outbox_shard_leases AS l
+SET owner      = $1,
+    expires_at = now() + $2::interval
+FROM free f
+WHERE l.shard = f.shard
+RETURNING l.shard;
+
+-- name: ReleaseOutboxShardLeases :exec
+UPDATE outbox_shard_leases SET owner = NULL, expires_at = NULL
+WHERE owner = $1 AND shard = ANY($2::int[]);
+
+-- name: ReleaseAllOutboxShardLeases :exec
+UPDATE outbox_shard_leases SET owner = NULL, expires_at = NULL WHERE owner = $1;
+
+-- name: PurgeOutbox :many
+DELETE FROM outbox
+WHERE id IN (
+  SELECT o.id
+  FROM outbox o
+  WHERE o.occurred_at < $1
+    AND (
+      o.status = 'dispatched'
+      -- postgres event bus: every durable cursor already read the row
+      OR ($2::bool AND NOT EXISTS (
+        SELECT 1 FROM event_cursors c WHERE (c.tx_id, c.seq) < (o.tx_id, o.seq)
+      ))
+    )
+  ORDER BY o.seq
+  FOR UPDATE SKIP LOCKED
+  LIMIT $3
+)
+RETURNING *;
+
+-- name: ImportOutboxEvent :execrows
+INSERT INTO outbox (
+  id,
+  aggregate_name,
+  aggregate_id,
+  event_type,
+  schema_version,
+  occurred_at,
+  payload,
+  status,
+  published_at,
+  tx_id
+)
+VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9,
+  -- restored history sorts before every event bus cursor
+  CASE WHEN $8 = 'dispatched' THEN 0 ELSE pg_current_xact_id()::text::bigint END
+)
+ON CONFLICT (id) DO NOTHING;
+
+-- name: ListOutboxEvents :many
+SELECT * FROM outbox
+WHERE seq > sqlc.arg('after_seq')::bigint
+  AND (sqlc.narg('aggregate_id')::uuid IS NULL OR aggregate_id = sqlc.narg('aggregate_id')::uuid)
+  AND (cardinality(sqlc.arg('event_types')::text[]) = 0 OR event_type = ANY(sqlc.arg('event_types')::text[]))
+  AND (sqlc.narg('since')::timestamptz IS NULL OR occurred_at >= sqlc.narg('since')::timestamptz)
+  AND (sqlc.narg('until')::timestamptz IS NULL OR occurred_at < sqlc.narg('until')::timestamptz)
+ORDER BY seq
+LIMIT sqlc.arg('batch_size');
 
--- name: RequeueOutbox :exec
-UPDATE outbox SET status = 'pending', claimed_at = NULL WHERE id = $1;
+-- name: OutboxBacklog :many
+SELECT status, count(*) AS events, min(occurred_at)::timestamptz AS oldest
+FROM outbox
+WHERE status <> 'dispatched'
+GROUP BY status;
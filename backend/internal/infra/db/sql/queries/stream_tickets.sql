@@ -0,0 +1,6 @@
+-- name: RedeemStreamTicket :execrows
+INSERT INTO stream_ticket_redemptions (ticket_id, expires_at) VALUES ($1, $2)
+ON CONFLICT (ticket_id) DO NOTHING;
+
+-- name: DeleteExpiredStreamTickets :exec
+DELETE FROM stream_ticket_redemptions WHERE expires_at < now();
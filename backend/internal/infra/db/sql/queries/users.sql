@@ -12,7 +12,8 @@ INSERT INTO users (
   disabled,
   avatar_url,
   banner_url,
-  flags
+  flags,
+  verified
 ) VALUES ( 
   $1,
   $2,
@@ -26,7 +27,8 @@ INSERT INTO users (
   $10,
   $11,
   $12,
-  $13
+  $13,
+  $14
 )
 ON CONFLICT (id)
 DO UPDATE SET 
@@ -41,7 +43,8 @@ DO UPDATE SET
   disabled = $10,
   avatar_url = $11,
   banner_url = $12,
-  flags = $13
+  flags = $13,
+  verified = $14
 RETURNING *;
 
 -- name: CreateUserSetting :one
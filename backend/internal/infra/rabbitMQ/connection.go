@@ -0,0 +1,109 @@
+package rabbitmq
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	RECONNECT_MIN_DELAY = 500 * time.Millisecond
+	RECONNECT_MAX_DELAY = 30 * time.Second
+)
+
+var ErrConnectionClosed = errors.New("rabbitMQ connection closed")
+
+// Connection wraps an AMQP connection and dials again whenever the broker
+// drops it. Channels opened from a lost connection die with it, callers open
+// new ones through Channel once it is back.
+type Connection struct {
+	uri string
+
+	mu   sync.RWMutex
+	conn *amqp.Connection
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func Dial(uri string) (*Connection, error) {
+	conn, err := amqp.Dial(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Connection{uri: uri, conn: conn, done: make(chan struct{})}
+	go c.reconnectLoop(conn)
+	return c, nil
+}
+
+func (c *Connection) Channel() (*amqp.Channel, error) {
+	select {
+	case <-c.done:
+		return nil, ErrConnectionClosed
+	default:
+	}
+
+	c.mu.RLock()
+	conn := c.conn
+	c.mu.RUnlock()
+	return conn.Channel()
+}
+
+// Ping checks the connection is up by opening and closing a channel.
+func (c *Connection) Ping() error {
+	ch, err := c.Channel()
+	if err != nil {
+		return err
+	}
+	return ch.Close()
+}
+
+func (c *Connection) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.conn.Close()
+}
+
+func (c *Connection) reconnectLoop(conn *amqp.Connection) {
+	for {
+		closeErr := <-conn.NotifyClose(make(chan *amqp.Error, 1))
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+		slog.Warn("RabbitMQ connection lost, reconnecting", "err", closeErr)
+
+		if conn = c.redial(); conn == nil {
+			return
+		}
+		c.mu.Lock()
+		c.conn = conn
+		c.mu.Unlock()
+		slog.Info("RabbitMQ connection restored")
+	}
+}
+
+func (c *Connection) redial() *amqp.Connection {
+	delay := RECONNECT_MIN_DELAY
+	for {
+		select {
+		case <-c.done:
+			return nil
+		case <-time.After(delay):
+		}
+
+		conn, err := amqp.Dial(c.uri)
+		if err == nil {
+			return conn
+		}
+		slog.Warn("RabbitMQ reconnect failed", "err", err, "retry_in", delay)
+		delay = min(delay*2, RECONNECT_MAX_DELAY)
+	}
+}
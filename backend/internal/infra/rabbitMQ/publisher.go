@@ -3,43 +3,168 @@ package rabbitmq
 import (
 	"backend/internal/application/ports"
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 
+	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
 const EXCHANGE_NAME = "noncord.event"
 
+// PUBLISH_CHUNK_SIZE bounds how many messages go out on one channel before
+// waiting for their confirms, it also sizes the buffer of returned messages.
+const PUBLISH_CHUNK_SIZE = 256
+
+var ErrNacked = errors.New("broker did not accept the message")
+
+// pubChannel is a channel in confirm mode with its returned messages.
+type pubChannel struct {
+	ch      *amqp.Channel
+	returns chan amqp.Return
+}
+
 type RMQEventPublisher struct {
-	conn *amqp.Connection
+	conn *Connection
+	pool chan *pubChannel
 }
 
-func NewRMQEventPublisher(conn *amqp.Connection) ports.EventPublisher {
-	return &RMQEventPublisher{conn}
+// NewRMQEventPublisher keeps up to poolSize confirm channels open so
+// concurrent publishes don't wait on each other's confirms.
+func NewRMQEventPublisher(conn *Connection, poolSize int) ports.EventPublisher {
+	return &RMQEventPublisher{conn, make(chan *pubChannel, poolSize)}
 }
 
-func (mq *RMQEventPublisher) Publish(ctx context.Context, msg ports.EventMessage) error {
+func (mq *RMQEventPublisher) openChannel() (*pubChannel, error) {
 	c, err := mq.conn.Channel()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err = c.Confirm(false); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if err = c.ExchangeDeclare(EXCHANGE_NAME, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return &pubChannel{c, c.NotifyReturn(make(chan amqp.Return, PUBLISH_CHUNK_SIZE))}, nil
+}
+
+func (mq *RMQEventPublisher) acquire() (*pubChannel, error) {
+	for {
+		select {
+		case pc := <-mq.pool:
+			// Channels of a lost connection are dropped here
+			if !pc.ch.IsClosed() {
+				return pc, nil
+			}
+		default:
+			return mq.openChannel()
+		}
+	}
+}
+
+func (mq *RMQEventPublisher) release(pc *pubChannel) {
+	if pc.ch.IsClosed() {
+		return
+	}
+	select {
+	case mq.pool <- pc:
+	default:
+		pc.ch.Close()
+	}
+}
+
+func (mq *RMQEventPublisher) Publish(ctx context.Context, msg ports.EventMessage) error {
+	return mq.PublishBatch(ctx, []ports.EventMessage{msg})[0]
+}
+
+func (mq *RMQEventPublisher) PublishBatch(ctx context.Context, msgs []ports.EventMessage) []error {
+	errs := make([]error, len(msgs))
+	for start := 0; start < len(msgs); start += PUBLISH_CHUNK_SIZE {
+		end := min(start+PUBLISH_CHUNK_SIZE, len(msgs))
+		mq.publishChunk(ctx, msgs[start:end], errs[start:end])
+	}
+	return errs
+}
+
+func (mq *RMQEventPublisher) publishChunk(ctx context.Context, msgs []ports.EventMessage, errs []error) {
+	pc, err := mq.acquire()
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return
 	}
 
-	if err = c.ExchangeDeclare(EXCHANGE_NAME, "topic", true, false, false, false, nil); err != nil {
-		return err
+	confirms := make([]*amqp.DeferredConfirmation, len(msgs))
+	byMessageId := make(map[string]int, len(msgs))
+	for i, msg := range msgs {
+		messageId, ok := msg.Headers["event_id"].(string)
+		if !ok {
+			messageId = uuid.NewString()
+		}
+		byMessageId[messageId] = i
+
+		confirms[i], errs[i] = pc.ch.PublishWithDeferredConfirmWithContext(ctx, EXCHANGE_NAME, msg.EventType, true, false, amqp.Publishing{
+			Headers:      msg.Headers,
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			MessageId:    messageId,
+			Body:         msg.Payload,
+		})
 	}
 
-	if err = c.Publish(EXCHANGE_NAME, msg.EventType, false, false, amqp.Publishing{
-		Headers:     msg.Headers,
-		ContentType: "application/json",
-		Body:        msg.Payload,
-	}); err != nil {
-		return err
+	healthy := true
+	for i, confirm := range confirms {
+		if errs[i] != nil {
+			continue
+		}
+		acked, err := confirm.WaitContext(ctx)
+		if err != nil {
+			// Confirms may still come in later, don't hand the channel out again
+			errs[i] = err
+			healthy = false
+		} else if !acked {
+			errs[i] = ErrNacked
+		}
 	}
 
-	return nil
+	// The broker returns an unroutable message before acking it, so every
+	// return of this chunk is already buffered once the confirms are in
+drain:
+	for {
+		select {
+		case ret, ok := <-pc.returns:
+			if !ok {
+				break drain
+			}
+			if i, found := byMessageId[ret.MessageId]; found && errs[i] == nil {
+				errs[i] = fmt.Errorf("%w: %s", ports.ErrUnroutable, ret.ReplyText)
+			}
+		default:
+			break drain
+		}
+	}
+
+	if healthy {
+		mq.release(pc)
+	} else {
+		pc.ch.Close()
+	}
 }
 
 func (mq *RMQEventPublisher) Close(ctx context.Context) error {
 	slog.Info("Closing publisher")
-	return mq.conn.Close()
+	for {
+		select {
+		case pc := <-mq.pool:
+			pc.ch.Close()
+		default:
+			return mq.conn.Close()
+		}
+	}
 }
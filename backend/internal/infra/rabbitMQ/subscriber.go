@@ -4,119 +4,423 @@ import (
 	"backend/internal/application/ports"
 	_ "backend/internal/domain/entities"
 	"backend/internal/domain/events"
+	"backend/internal/infra/telemetry"
 	"context"
+	"fmt"
+	"hash/fnv"
 	"log/slog"
+	"maps"
 	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+// DEAD_LETTER_EXCHANGE receives messages that used up their retries, routed
+// by the name of the queue they failed on into "<queue>.dlq".
+const DEAD_LETTER_EXCHANGE = "noncord.dlx"
+
+const (
+	HEADER_RETRY_COUNT = "x-retry-count"
+	HEADER_LAST_ERROR  = "x-last-error"
+)
+
 type Handler func(context.Context, ports.EventMessage) error
 
+type SubscriberConfig struct {
+	Prefetch int `yaml:"prefetch" env:"RABBITMQ_PREFETCH"` // unacked deliveries the broker hands out at once
+	Workers  int `yaml:"-"`                                // handlers running concurrently, each aggregate always gets the same one
+	// RetryDelays holds the delay before each retry of a failed message, a
+	// message that failed len(RetryDelays)+1 times goes to the dead letter queue.
+	RetryDelays []time.Duration `yaml:"retry_delays" env:"RABBITMQ_RETRY_DELAYS"`
+}
+
+var DefaultSubscriberConfig = SubscriberConfig{
+	Prefetch:    32,
+	Workers:     1,
+	RetryDelays: []time.Duration{time.Second, 10 * time.Second, time.Minute, 10 * time.Minute},
+}
+
 type RMQEventSubscriber struct {
-	mu           *sync.RWMutex
-	c            *amqp.Channel
-	serviceName  string
+	conn         *Connection
+	cfg          SubscriberConfig
+	queueName    string
 	exchangeName string
-	handlerMap   map[string]Handler
 	durable      bool
+	exclusive    bool
+
+	mu         *sync.RWMutex
+	c          *amqp.Channel // nil while reconnecting
+	handlerMap map[string]Handler
+
+	cancel context.CancelFunc
+	done   chan struct{} // closed once every handler returned
 }
 
-func NewRMQEventSubscriber(ctx context.Context, conn *amqp.Connection, serviceName, exchangeName string, durable bool) (ports.EventSubscriber, error) {
-	c, err := conn.Channel()
-	if err != nil {
-		return nil, err
-	}
+// NewRMQEventSubscriber consumes from a queue named after the service. Every
+// instance of the service shares that queue, so each event is handled once per
+// service (competing consumers).
+func NewRMQEventSubscriber(ctx context.Context, conn *Connection, serviceName, exchangeName string, durable bool, cfg SubscriberConfig) (ports.EventSubscriber, error) {
+	return newRMQEventSubscriber(ctx, conn, serviceName, exchangeName, durable, false, cfg)
+}
 
-	if err = c.ExchangeDeclare(exchangeName, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
-		return nil, err
-	}
+// NewRMQBroadcastSubscriber consumes from an exclusive, auto-delete queue owned
+// by this instance only, so each event reaches every running instance of the
+// service. The queue goes away with the connection, so a crashed or scaled-down
+// instance leaves nothing behind on the broker.
+func NewRMQBroadcastSubscriber(ctx context.Context, conn *Connection, serviceName, exchangeName string, cfg SubscriberConfig) (ports.EventSubscriber, error) {
+	return newRMQEventSubscriber(ctx, conn, fmt.Sprintf("%s.%s", serviceName, uuid.NewString()), exchangeName, false, true, cfg)
+}
 
-	q, err := c.QueueDeclare(serviceName, durable, false, false, false, nil)
-	if err != nil {
-		return nil, err
+func newRMQEventSubscriber(ctx context.Context, conn *Connection, queueName, exchangeName string, durable, exclusive bool, cfg SubscriberConfig) (ports.EventSubscriber, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	client := &RMQEventSubscriber{
+		conn:         conn,
+		cfg:          cfg,
+		queueName:    queueName,
+		exchangeName: exchangeName,
+		durable:      durable,
+		exclusive:    exclusive,
+		mu:           &sync.RWMutex{},
+		handlerMap:   make(map[string]Handler),
+		cancel:       cancel,
+		done:         make(chan struct{}),
 	}
 
-	msgs, err := c.ConsumeWithContext(ctx, q.Name, "", false, false, false, false, nil)
+	// The first setup fails fast, later ones are retried by the run loop
+	msgs, closed, err := client.setup(ctx)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
+	go client.run(ctx, msgs, closed)
 
-	var mu sync.RWMutex
-	client := &RMQEventSubscriber{&mu, c, serviceName, exchangeName, make(map[string]Handler), durable}
+	slog.Info("Created new EventSubscriber successfully", "queue", queueName)
+	return client, nil
+}
 
-	go client.consumeLoop(ctx, msgs)
+func (s *RMQEventSubscriber) retryQueueName(attempt int) string {
+	return fmt.Sprintf("%s.retry.%d", s.queueName, attempt)
+}
 
-	slog.Info("Created new EventSubscriber successfully")
-	return client, nil
+func (s *RMQEventSubscriber) deadLetterQueueName() string {
+	return s.queueName + ".dlq"
 }
 
-func (s *RMQEventSubscriber) Subscribe(topic string, handler func(context.Context, ports.EventMessage) error) error {
-	q, err := s.c.QueueDeclarePassive(s.serviceName, s.durable, false, false, false, nil)
-	if err != nil {
+// topologyChannel is the part of *amqp.Channel declareTopology uses.
+type topologyChannel interface {
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+}
+
+// declareTopology declares the work queue with one delay queue per retry and
+// the dead letter queue. A delay queue holds messages for its TTL and then
+// dead letters them through the default exchange back to the work queue.
+func (s *RMQEventSubscriber) declareTopology(c topologyChannel) error {
+	if err := c.ExchangeDeclare(s.exchangeName, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		return err
+	}
+	if err := c.ExchangeDeclare(DEAD_LETTER_EXCHANGE, amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
 		return err
 	}
 
-	if err = s.c.QueueBind(q.Name, topic, s.exchangeName, false, nil); err != nil {
+	if _, err := c.QueueDeclare(s.queueName, s.durable, s.exclusive, s.exclusive, false, nil); err != nil {
 		return err
 	}
 
+	var maxDelay time.Duration
+	for i, delay := range s.cfg.RetryDelays {
+		maxDelay = max(maxDelay, delay)
+		args := amqp.Table{
+			amqp.QueueMessageTTLArg:     delay.Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": s.queueName,
+		}
+		if !s.durable {
+			// Instance queues are gone after a restart, let theirs expire too
+			args[amqp.QueueTTLArg] = (2*delay + time.Minute).Milliseconds()
+		}
+		if _, err := c.QueueDeclare(s.retryQueueName(i), s.durable, false, false, false, args); err != nil {
+			return err
+		}
+	}
+
+	var dlqArgs amqp.Table
+	if !s.durable {
+		dlqArgs = amqp.Table{amqp.QueueTTLArg: (2*maxDelay + time.Hour).Milliseconds()}
+	}
+	if _, err := c.QueueDeclare(s.deadLetterQueueName(), s.durable, false, false, false, dlqArgs); err != nil {
+		return err
+	}
+	return c.QueueBind(s.deadLetterQueueName(), s.queueName, DEAD_LETTER_EXCHANGE, false, nil)
+}
+
+// setup opens a channel, declares the topology, binds every subscribed topic
+// and starts consuming.
+func (s *RMQEventSubscriber) setup(ctx context.Context) (<-chan amqp.Delivery, <-chan *amqp.Error, error) {
+	c, err := s.conn.Channel()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err = c.Qos(s.cfg.Prefetch, 0, false); err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+	// Retries are republished on this channel, a failed message is only
+	// acked once the broker confirmed its copy
+	if err = c.Confirm(false); err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+	if err = s.declareTopology(c); err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	for topic := range s.handlerMap {
+		if err = c.QueueBind(s.queueName, topic, s.exchangeName, false, nil); err != nil {
+			c.Close()
+			return nil, nil, err
+		}
+	}
+
+	msgs, err := c.ConsumeWithContext(ctx, s.queueName, "", false, false, false, false, nil)
+	if err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+	s.c = c
+	return msgs, c.NotifyClose(make(chan *amqp.Error, 1)), nil
+}
+
+func (s *RMQEventSubscriber) run(ctx context.Context, msgs <-chan amqp.Delivery, closed <-chan *amqp.Error) {
+	defer close(s.done)
+	for {
+		var wg sync.WaitGroup
+		lanes := make([]chan delivery, max(s.cfg.Workers, 1))
+		for i := range lanes {
+			lanes[i] = make(chan delivery)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// A handler that started runs to the end even when the
+				// subscriber closes, its delivery is acked on the channel
+				// Close keeps open
+				for d := range lanes[i] {
+					s.handle(context.WithoutCancel(ctx), d)
+				}
+			}()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.dispatch(ctx, msgs, lanes)
+		}()
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case err := <-closed:
+			slog.Warn("Subscriber channel closed, resubscribing", "queue", s.queueName, "err", err)
+		}
+		wg.Wait()
+
+		s.mu.Lock()
+		s.c = nil
+		s.mu.Unlock()
+
+		delay := RECONNECT_MIN_DELAY
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+
+			var err error
+			if msgs, closed, err = s.setup(ctx); err == nil {
+				break
+			}
+			slog.Warn("Resubscribe failed", "queue", s.queueName, "err", err, "retry_in", delay)
+			delay = min(delay*2, RECONNECT_MAX_DELAY)
+		}
+		slog.Info("Subscriber resubscribed", "queue", s.queueName)
+	}
+}
+
+func (s *RMQEventSubscriber) Subscribe(topic string, handler func(context.Context, ports.EventMessage) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// While reconnecting the binding is made by the next setup
+	if s.c != nil {
+		if err := s.c.QueueBind(s.queueName, topic, s.exchangeName, false, nil); err != nil {
+			return err
+		}
+	}
 	s.handlerMap[topic] = handler
 	return nil
 }
 
+// Close stops consuming and waits for the handlers already running before
+// closing the channel, unacked prefetched deliveries go back to the queue.
 func (s *RMQEventSubscriber) Close() error {
+	s.cancel()
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.c == nil {
+		return nil
+	}
 	for topic := range s.handlerMap {
-		s.c.QueueUnbind(s.serviceName, topic, s.exchangeName, nil)
+		s.c.QueueUnbind(s.queueName, topic, s.exchangeName, nil)
 	}
 
 	return s.c.Close()
 }
 
-func (s *RMQEventSubscriber) consumeLoop(ctx context.Context, msgs <-chan amqp.Delivery) {
-loop:
+// delivery is a message with the envelope its lane was picked from
+type delivery struct {
+	msg  amqp.Delivery
+	base events.Base
+}
+
+// dispatch hands every message to the lane its aggregate hashes to, so the
+// events of an aggregate are handled one at a time and in queue order while
+// other aggregates go on. It closes the lanes once msgs is done.
+func (s *RMQEventSubscriber) dispatch(ctx context.Context, msgs <-chan amqp.Delivery, lanes []chan delivery) {
+	defer func() {
+		for _, lane := range lanes {
+			close(lane)
+		}
+	}()
+
 	for {
 		select {
 		case <-ctx.Done():
-			break loop
+			return
 		case msg, ok := <-msgs:
 			if !ok {
-				slog.Info("Message channel closed")
 				return
 			}
-
-			data := msg.Body
-			base, err := events.ParseEvent(data)
+			base, err := events.ParseEvent(msg.Body)
 			if err != nil {
-				slog.Warn("Parse event failed", "error", err, "data", data)
+				slog.Warn("Parse event failed", "error", err, "data", msg.Body)
 				msg.Ack(false)
 				continue
 			}
 
-			s.mu.RLock()
-			handler, ok := s.handlerMap[base.EventType]
-			s.mu.RUnlock()
-			if !ok {
-				slog.Warn("Event don't have handler", "topic", base.EventType, "event", base)
-				msg.Ack(false)
-				continue
-			}
-
-			err = handler(ctx, ports.EventMessage{
-				AggregateId: base.AggregateID,
-				EventType:   base.EventType,
-				Payload:     data,
-				Headers:     msg.Headers,
-			})
-			if err != nil {
-				slog.Warn("Event handler failed", "event", base)
-				msg.Reject(true)
-				continue
-			} else {
-				msg.Ack(false)
+			select {
+			case <-ctx.Done():
+				// Unacked, the broker redelivers it
+				return
+			case lanes[laneOf(base.AggregateID, len(lanes))] <- delivery{msg, base}:
 			}
 		}
 	}
 }
+
+func laneOf(aggregateId uuid.UUID, lanes int) int {
+	h := fnv.New32a()
+	h.Write(aggregateId[:])
+	return int(h.Sum32() % uint32(lanes))
+}
+
+func (s *RMQEventSubscriber) handle(ctx context.Context, d delivery) {
+	msg, base := d.msg, d.base
+	s.mu.RLock()
+	handler, ok := s.handlerMap[base.EventType]
+	s.mu.RUnlock()
+	if !ok {
+		slog.Warn("Event don't have handler", "topic", base.EventType, "event", base)
+		msg.Ack(false)
+		return
+	}
+
+	err := telemetry.Consume(ctx, s.queueName, ports.EventMessage{
+		AggregateId: base.AggregateID,
+		EventType:   base.EventType,
+		Payload:     msg.Body,
+		Headers:     msg.Headers,
+	}, handler)
+	if err == nil {
+		msg.Ack(false)
+		return
+	}
+
+	slog.Warn("Event handler failed", "event", base, "err", err)
+	telemetry.Retried(s.queueName, base.EventType, retryCount(msg.Headers) >= len(s.cfg.RetryDelays))
+	if err = s.retry(ctx, msg, err); err != nil {
+		// Could not park it anywhere, let the broker redeliver it
+		slog.Error("Cannot schedule event retry", "event", base, "err", err)
+		msg.Nack(false, true)
+		return
+	}
+	msg.Ack(false)
+}
+
+// retry republishes a failed message to the delay queue of its next attempt,
+// or to the dead letter queue once the retries are used up.
+func (s *RMQEventSubscriber) retry(ctx context.Context, msg amqp.Delivery, cause error) error {
+	s.mu.RLock()
+	c := s.c
+	s.mu.RUnlock()
+	if c == nil {
+		return ErrConnectionClosed
+	}
+
+	attempt := retryCount(msg.Headers)
+	headers := maps.Clone(msg.Headers)
+	if headers == nil {
+		headers = amqp.Table{}
+	}
+	headers[HEADER_RETRY_COUNT] = int32(attempt + 1)
+	headers[HEADER_LAST_ERROR] = cause.Error()
+
+	exchange, key := "", ""
+	if attempt < len(s.cfg.RetryDelays) {
+		key = s.retryQueueName(attempt)
+	} else {
+		exchange, key = DEAD_LETTER_EXCHANGE, s.queueName
+		slog.Warn("Event dead lettered", "queue", s.queueName, "message_id", msg.MessageId, "attempts", attempt+1)
+	}
+
+	confirm, err := c.PublishWithDeferredConfirmWithContext(ctx, exchange, key, false, false, amqp.Publishing{
+		Headers:      headers,
+		ContentType:  msg.ContentType,
+		DeliveryMode: msg.DeliveryMode,
+		MessageId:    msg.MessageId,
+		Body:         msg.Body,
+	})
+	if err != nil {
+		return err
+	}
+	// A closing channel settles its pending confirms as nacked
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return ErrNacked
+	}
+	return nil
+}
+
+func retryCount(headers amqp.Table) int {
+	switch v := headers[HEADER_RETRY_COUNT].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
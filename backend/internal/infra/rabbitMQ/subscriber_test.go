@@ -0,0 +1,154 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestRetryCount(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers amqp.Table
+		want    int
+	}{
+		{name: "no headers", headers: nil, want: 0},
+		{name: "first failure", headers: amqp.Table{}, want: 0},
+		{name: "int32 as published", headers: amqp.Table{HEADER_RETRY_COUNT: int32(2)}, want: 2},
+		{name: "int64", headers: amqp.Table{HEADER_RETRY_COUNT: int64(3)}, want: 3},
+		{name: "int", headers: amqp.Table{HEADER_RETRY_COUNT: 4}, want: 4},
+		{name: "unexpected type", headers: amqp.Table{HEADER_RETRY_COUNT: "5"}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryCount(tt.headers); got != tt.want {
+				t.Errorf("retryCount = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLaneOf(t *testing.T) {
+	for _, lanes := range []int{1, 2, 8} {
+		t.Run(fmt.Sprint(lanes), func(t *testing.T) {
+			used := map[int]bool{}
+			for range 200 {
+				id := uuid.New()
+				lane := laneOf(id, lanes)
+				if lane < 0 || lane >= lanes {
+					t.Fatalf("lane %d out of [0, %d)", lane, lanes)
+				}
+				if again := laneOf(id, lanes); again != lane {
+					t.Fatalf("aggregate %s moved from lane %d to %d", id, lane, again)
+				}
+				used[lane] = true
+			}
+			if len(used) != lanes {
+				t.Errorf("200 aggregates used %d of %d lanes", len(used), lanes)
+			}
+		})
+	}
+}
+
+type declaredQueue struct {
+	durable, autoDelete, exclusive bool
+	args                           amqp.Table
+}
+
+type binding struct{ queue, key, exchange string }
+
+// recordingChannel records the topology declared on it
+type recordingChannel struct {
+	exchanges map[string]string // name -> kind
+	queues    map[string]declaredQueue
+	bindings  []binding
+}
+
+func (c *recordingChannel) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	c.exchanges[name] = kind
+	return nil
+}
+
+func (c *recordingChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	c.queues[name] = declaredQueue{durable, autoDelete, exclusive, args}
+	return amqp.Queue{Name: name}, nil
+}
+
+func (c *recordingChannel) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	c.bindings = append(c.bindings, binding{name, key, exchange})
+	return nil
+}
+
+func TestDeclareTopology(t *testing.T) {
+	tests := []struct {
+		name      string
+		queue     string
+		durable   bool
+		exclusive bool
+		delays    []time.Duration
+	}{
+		{name: "service queue", queue: "api_workers", durable: true, delays: []time.Duration{time.Second, time.Minute}},
+		{name: "instance queue", queue: "websocket.1", exclusive: true, delays: []time.Duration{10 * time.Second}},
+		{name: "no retries", queue: "api_workers", durable: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &RMQEventSubscriber{
+				cfg:          SubscriberConfig{RetryDelays: tt.delays},
+				queueName:    tt.queue,
+				exchangeName: EXCHANGE_NAME,
+				durable:      tt.durable,
+				exclusive:    tt.exclusive,
+			}
+			c := &recordingChannel{exchanges: map[string]string{}, queues: map[string]declaredQueue{}}
+			if err := s.declareTopology(c); err != nil {
+				t.Fatal(err)
+			}
+
+			if c.exchanges[EXCHANGE_NAME] != amqp.ExchangeTopic || c.exchanges[DEAD_LETTER_EXCHANGE] != amqp.ExchangeDirect {
+				t.Errorf("exchanges = %v", c.exchanges)
+			}
+			if len(c.queues) != len(tt.delays)+2 {
+				t.Errorf("declared %d queues, want the work queue, %d delay queues and the dlq", len(c.queues), len(tt.delays))
+			}
+
+			work, ok := c.queues[tt.queue]
+			if !ok || work.durable != tt.durable || work.exclusive != tt.exclusive || work.autoDelete != tt.exclusive {
+				t.Errorf("work queue = %+v, declared %t", work, ok)
+			}
+
+			for i, delay := range tt.delays {
+				q, ok := c.queues[s.retryQueueName(i)]
+				if !ok {
+					t.Fatalf("delay queue %d not declared", i)
+				}
+				if q.args[amqp.QueueMessageTTLArg] != delay.Milliseconds() {
+					t.Errorf("delay queue %d ttl = %v, want %d", i, q.args[amqp.QueueMessageTTLArg], delay.Milliseconds())
+				}
+				if q.args["x-dead-letter-exchange"] != "" || q.args["x-dead-letter-routing-key"] != tt.queue {
+					t.Errorf("delay queue %d dead letters to %v/%v, want the work queue", i, q.args["x-dead-letter-exchange"], q.args["x-dead-letter-routing-key"])
+				}
+				if _, expires := q.args[amqp.QueueTTLArg]; expires == tt.durable {
+					t.Errorf("delay queue %d expires = %t, want %t", i, expires, !tt.durable)
+				}
+			}
+
+			dlq, ok := c.queues[s.deadLetterQueueName()]
+			if !ok || dlq.durable != tt.durable {
+				t.Errorf("dlq = %+v, declared %t", dlq, ok)
+			}
+			if _, expires := dlq.args[amqp.QueueTTLArg]; expires == tt.durable {
+				t.Errorf("dlq expires = %t, want %t", expires, !tt.durable)
+			}
+			want := binding{s.deadLetterQueueName(), tt.queue, DEAD_LETTER_EXCHANGE}
+			if len(c.bindings) != 1 || c.bindings[0] != want {
+				t.Errorf("bindings = %v, want %v", c.bindings, want)
+			}
+		})
+	}
+}
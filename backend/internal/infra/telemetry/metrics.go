@@ -0,0 +1,90 @@
+package telemetry
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	handlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "noncord",
+		Subsystem: "subscriber",
+		Name:      "handler_duration_seconds",
+		Help:      "Time spent in event handlers, by consumer, event type and outcome (ok or error).",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"consumer", "event_type", "outcome"})
+
+	handlerRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "noncord",
+		Subsystem: "subscriber",
+		Name:      "retries_total",
+		Help:      "Failed events scheduled for another attempt.",
+	}, []string{"consumer", "event_type"})
+
+	handlerDeadLetters = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "noncord",
+		Subsystem: "subscriber",
+		Name:      "dead_letters_total",
+		Help:      "Events dead lettered once their retries were used up.",
+	}, []string{"consumer", "event_type"})
+
+	deadLettersDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "noncord",
+		Subsystem: "subscriber",
+		Name:      "dead_letters_dropped_total",
+		Help:      "Dead letters dropped because the in-memory bus kept as many as it could.",
+	}, []string{"consumer"})
+
+	retryOverflows = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "noncord",
+		Subsystem: "subscriber",
+		Name:      "retry_overflows_total",
+		Help:      "Failed events the in-memory bus dead lettered instead of retrying, because too many retries were pending.",
+	}, []string{"consumer"})
+)
+
+// MetricsHandler serves every metric registered in the process, along with
+// the Go runtime and process ones. A collector that fails, e.g. the outbox one
+// while the database is down, is logged and left out instead of failing the
+// whole scrape.
+func MetricsHandler() http.Handler {
+	return promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+		ErrorLog:      slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+		ErrorHandling: promhttp.ContinueOnError,
+	}))
+}
+
+// Retried counts a failed event of consumer that is tried again, or dead
+// lettered when it used up its retries.
+func Retried(consumer, eventType string, deadLettered bool) {
+	if deadLettered {
+		handlerDeadLetters.WithLabelValues(consumer, eventType).Inc()
+		return
+	}
+	handlerRetries.WithLabelValues(consumer, eventType).Inc()
+}
+
+// DeadLetterDropped counts a dead letter of consumer the in-memory bus had no
+// room left to keep.
+func DeadLetterDropped(consumer string) {
+	deadLettersDropped.WithLabelValues(consumer).Inc()
+}
+
+// RetryOverflow counts a failed event of consumer dead lettered at once
+// because the in-memory bus had too many retries pending.
+func RetryOverflow(consumer string) {
+	retryOverflows.WithLabelValues(consumer).Inc()
+}
+
+func observeHandler(consumer, eventType string, start time.Time, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	handlerDuration.WithLabelValues(consumer, eventType, outcome).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,75 @@
+package telemetry
+
+import (
+	"backend/internal/application/ports"
+	"context"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const TRACER_NAME = "backend/internal/infra/telemetry"
+
+// Setup installs the W3C trace context propagator and, when
+// OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set,
+// an OTLP/HTTP exporter configured by the standard OTEL_* variables. Without
+// an endpoint spans are no-ops. The returned func flushes pending spans.
+func Setup(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", serviceName)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// End records err on span, if any, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Consume runs handler in a consumer span continuing the trace carried by
+// the headers of msg, and records how long it took.
+func Consume(ctx context.Context, consumer string, msg ports.EventMessage, handler func(context.Context, ports.EventMessage) error) error {
+	start := time.Now()
+	ctx = otel.GetTextMapPropagator().Extract(ctx, ports.HeaderCarrier(msg.Headers))
+	eventId, _ := msg.Headers["event_id"].(string)
+	ctx, span := otel.Tracer(TRACER_NAME).Start(ctx, "consume "+msg.EventType,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.destination.name", msg.EventType),
+			attribute.String("messaging.consumer.group.name", consumer),
+			attribute.String("messaging.message.id", eventId),
+		),
+	)
+	err := handler(ctx, msg)
+	End(span, err)
+	observeHandler(consumer, msg.EventType, start, err)
+	return err
+}
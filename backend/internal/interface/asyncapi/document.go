@@ -0,0 +1,131 @@
+package asyncapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const ASYNCAPI_VERSION = "3.0.0"
+
+// Action is what the backend does with a message, from its point of view.
+type Action string
+
+const (
+	ACTION_SEND    Action = "send"
+	ACTION_RECEIVE Action = "receive"
+)
+
+type Info struct {
+	Title       string
+	Version     string
+	Description string
+}
+
+type Message struct {
+	Name    string // unique in the document, e.g. "message.created.v1"
+	Title   string
+	Summary string
+	Action  Action
+	Headers Schema // nil when the message has no headers
+	Payload Schema
+}
+
+type Channel struct {
+	Name        string
+	Address     string // routing key, url path...
+	Description string
+	Bindings    map[string]any
+	Messages    []Message
+}
+
+// Build assembles an AsyncAPI document: each message goes to the
+// components with its payload schema, and each channel gets one operation
+// per action its messages take.
+func Build(info Info, channels ...Channel) map[string]any {
+	docChannels := map[string]any{}
+	operations := map[string]any{}
+	messages := map[string]any{}
+
+	for _, ch := range channels {
+		chMessages := map[string]any{}
+		byAction := map[Action][]any{}
+		for _, msg := range ch.Messages {
+			component := map[string]any{
+				"name":        msg.Name,
+				"contentType": "application/json",
+				"payload":     msg.Payload,
+			}
+			if msg.Title != "" {
+				component["title"] = msg.Title
+			}
+			if msg.Summary != "" {
+				component["summary"] = msg.Summary
+			}
+			if msg.Headers != nil {
+				component["headers"] = msg.Headers
+			}
+			messages[msg.Name] = component
+
+			chMessages[msg.Name] = ref("components", "messages", msg.Name)
+			byAction[msg.Action] = append(byAction[msg.Action], ref("channels", ch.Name, "messages", msg.Name))
+		}
+
+		channel := map[string]any{
+			"address":  ch.Address,
+			"messages": chMessages,
+		}
+		if ch.Description != "" {
+			channel["description"] = ch.Description
+		}
+		if ch.Bindings != nil {
+			channel["bindings"] = ch.Bindings
+		}
+		docChannels[ch.Name] = channel
+
+		for action, refs := range byAction {
+			operations[ch.Name+"."+string(action)] = map[string]any{
+				"action":   action,
+				"channel":  ref("channels", ch.Name),
+				"messages": refs,
+			}
+		}
+	}
+
+	docInfo := map[string]any{
+		"title":   info.Title,
+		"version": info.Version,
+	}
+	if info.Description != "" {
+		docInfo["description"] = info.Description
+	}
+	return map[string]any{
+		"asyncapi":   ASYNCAPI_VERSION,
+		"info":       docInfo,
+		"channels":   docChannels,
+		"operations": operations,
+		"components": map[string]any{"messages": messages},
+	}
+}
+
+// Handler serves doc as JSON, it is encoded once.
+func Handler(doc map[string]any) http.HandlerFunc {
+	body, err := json.MarshalIndent(doc, "", "  ")
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}
+}
+
+// ref builds a $ref to a JSON pointer made of path, escaping each part.
+func ref(path ...string) map[string]any {
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+	for i, part := range path {
+		path[i] = escaper.Replace(part)
+	}
+	return map[string]any{"$ref": "#/" + strings.Join(path, "/")}
+}
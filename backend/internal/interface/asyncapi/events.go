@@ -0,0 +1,72 @@
+package asyncapi
+
+import (
+	"backend/internal/application/ports"
+	"backend/internal/domain/events"
+	"fmt"
+	"reflect"
+)
+
+// eventHeaders are set on every event by the relayer, see
+// ports.OutboxRecord.Message.
+var eventHeaders = Schema{
+	"type": "object",
+	"properties": Schema{
+		"event_id":              Schema{"type": "string", "format": "uuid"},
+		"event_type":            Schema{"type": "string"},
+		"aggregate_name":        Schema{"type": "string"},
+		"schema_version":        Schema{"type": "string"},
+		"occurred_at":           Schema{"type": "string", "format": "date-time"},
+		ports.HEADER_REPLAY:     Schema{"type": "string", "enum": []string{"true"}, "description": "set on events replayed from history"},
+		ports.HEADER_REPLAY_RUN: Schema{"type": "string", "format": "uuid", "description": "the replay the event belongs to, consumers deduplicate it per run"},
+	},
+	"required": []string{"event_id", "event_type", "aggregate_name", "schema_version", "occurred_at"},
+}
+
+// DomainEvents walks the event registry and returns one channel per event
+// type, routed by its type on exchange, with one message per schema version.
+func DomainEvents(exchange string) []Channel {
+	types := events.Types()
+	channels := make([]Channel, 0, len(types))
+	for _, eventType := range types {
+		ch := Channel{
+			Name:        "events." + eventType,
+			Address:     eventType,
+			Description: fmt.Sprintf("%s domain events, routed by their type.", eventType),
+			Bindings: map[string]any{
+				"amqp": map[string]any{
+					"is": "routingKey",
+					"exchange": map[string]any{
+						"name":    exchange,
+						"type":    "topic",
+						"durable": true,
+					},
+				},
+			},
+		}
+
+		versions := events.Versions(eventType)
+		latest := versions[len(versions)-1]
+		for _, version := range versions {
+			event, _ := events.New(eventType, version)
+			payload := SchemaOf(event).
+				Property("type", Schema{"const": eventType}).
+				Property("schema_version", Schema{"const": version})
+
+			summary := fmt.Sprintf("Schema version %d", version)
+			if version < latest {
+				summary += fmt.Sprintf(", upcast to version %d before it is handled", latest)
+			}
+			ch.Messages = append(ch.Messages, Message{
+				Name:    fmt.Sprintf("%s.v%d", eventType, version),
+				Title:   reflect.TypeOf(event).Name(),
+				Summary: summary,
+				Action:  ACTION_SEND,
+				Headers: eventHeaders,
+				Payload: payload,
+			})
+		}
+		channels = append(channels, ch)
+	}
+	return channels
+}
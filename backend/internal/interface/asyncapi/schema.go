@@ -0,0 +1,176 @@
+package asyncapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Schema is a JSON Schema object.
+type Schema map[string]any
+
+var (
+	uuidType          = reflect.TypeFor[uuid.UUID]()
+	timeType          = reflect.TypeFor[time.Time]()
+	rawMessageType    = reflect.TypeFor[json.RawMessage]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// SchemaOf describes how encoding/json encodes v, nil stands for a null
+// payload. Types with their own MarshalJSON are left open.
+func SchemaOf(v any) Schema {
+	if v == nil {
+		return Schema{"type": "null"}
+	}
+	return schemaOf(reflect.TypeOf(v), map[reflect.Type]bool{})
+}
+
+// Describe returns an open schema that only carries a description.
+func Describe(description string) Schema {
+	return Schema{"description": description}
+}
+
+// Property replaces one property of an object schema.
+func (s Schema) Property(name string, prop Schema) Schema {
+	props, _ := s["properties"].(Schema)
+	if props == nil {
+		props = Schema{}
+		s["properties"] = props
+	}
+	props[name] = prop
+	return s
+}
+
+func schemaOf(t reflect.Type, visiting map[reflect.Type]bool) Schema {
+	switch t {
+	case uuidType:
+		return Schema{"type": "string", "format": "uuid"}
+	case timeType:
+		return Schema{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return Schema{}
+	}
+	if t.Kind() == reflect.Pointer {
+		return nullable(schemaOf(t.Elem(), visiting))
+	}
+	if implements(t, jsonMarshalerType) {
+		return Schema{}
+	}
+	if implements(t, textMarshalerType) {
+		return Schema{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Schema{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Schema{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "contentEncoding": "base64"}
+		}
+		return Schema{"type": "array", "items": schemaOf(t.Elem(), visiting)}
+	case reflect.Array:
+		return Schema{"type": "array", "items": schemaOf(t.Elem(), visiting), "minItems": t.Len(), "maxItems": t.Len()}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": schemaOf(t.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[t] {
+			// Recursive type, leave the inner one open
+			return Schema{}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+		s := Schema{"type": "object", "properties": Schema{}}
+		var required []string
+		addFields(s, &required, t, visiting)
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		return s
+	default:
+		return Schema{}
+	}
+}
+
+// addFields adds the fields of t to s, flattening embedded structs the way
+// encoding/json does. Fields of the outer struct win over embedded ones.
+func addFields(s Schema, required *[]string, t reflect.Type, visiting map[reflect.Type]bool) {
+	props := s["properties"].(Schema)
+	var embedded []reflect.Type
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		props[name] = schemaOf(field.Type, visiting)
+		if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") {
+			*required = append(*required, name)
+		}
+	}
+
+	for _, ft := range embedded {
+		inner := Schema{"properties": Schema{}}
+		var innerRequired []string
+		addFields(inner, &innerRequired, ft, visiting)
+		innerProps := inner["properties"].(Schema)
+		for _, name := range innerRequired {
+			if _, ok := props[name]; !ok {
+				*required = append(*required, name)
+			}
+		}
+		for name, prop := range innerProps {
+			if _, ok := props[name]; !ok {
+				props[name] = prop
+			}
+		}
+	}
+}
+
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PointerTo(t).Implements(iface)
+}
+
+func nullable(s Schema) Schema {
+	switch typ := s["type"].(type) {
+	case string:
+		s["type"] = []string{typ, "null"}
+		return s
+	case nil:
+		if len(s) == 0 {
+			return s
+		}
+	}
+	return Schema{"oneOf": []Schema{s, {"type": "null"}}}
+}
@@ -0,0 +1,108 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CHECK_TIMEOUT bounds each readiness check, a dependency slower than that is
+// reported as down.
+const CHECK_TIMEOUT = 2 * time.Second
+
+// Check returns nil when the dependency it checks is usable, e.g. pgxpool.Pool.Ping.
+type Check func(ctx context.Context) error
+
+type Checker struct {
+	names  []string
+	checks []Check
+}
+
+func New() *Checker {
+	return &Checker{}
+}
+
+// Add registers a readiness check, name is its key in the /readyz report.
+func (c *Checker) Add(name string, check Check) *Checker {
+	c.names = append(c.names, name)
+	c.checks = append(c.checks, check)
+	return c
+}
+
+// Register mounts /healthz and /readyz, on a chi router or a http.ServeMux.
+func (c *Checker) Register(r interface {
+	Handle(pattern string, handler http.Handler)
+}) {
+	r.Handle("/healthz", http.HandlerFunc(c.Live))
+	r.Handle("/readyz", http.HandlerFunc(c.Ready))
+}
+
+type report struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Live answers as long as the process serves HTTP, it checks no dependency so
+// an outage of one does not get every pod restarted.
+func (c *Checker) Live(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, http.StatusOK, report{Status: "ok"})
+}
+
+// Ready runs every check concurrently and answers 503 when one fails, with
+// the status of each. The errors are logged rather than returned, they can
+// name hosts and users the probe's caller has no business seeing.
+func (c *Checker) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), CHECK_TIMEOUT)
+	defer cancel()
+
+	errs := make([]error, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = check(ctx)
+		}()
+	}
+	wg.Wait()
+
+	res := report{Status: "ok", Checks: make(map[string]string, len(c.checks))}
+	status := http.StatusOK
+	for i, name := range c.names {
+		res.Checks[name] = "ok"
+		if errs[i] != nil {
+			slog.Warn("readiness check failed", "check", name, "error", errs[i])
+			res.Checks[name] = "down"
+			res.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	writeReport(w, status, res)
+}
+
+// Recent fails when last is zero or older than maxAge, e.g. for a loop that
+// records when it last did its work.
+func Recent(last func() time.Time, maxAge time.Duration) Check {
+	return func(ctx context.Context) error {
+		at := last()
+		if at.IsZero() {
+			return errors.New("never ran")
+		}
+		if age := time.Since(at); age > maxAge {
+			return fmt.Errorf("last ran %s ago", age.Round(time.Second))
+		}
+		return nil
+	}
+}
+
+func writeReport(w http.ResponseWriter, status int, res report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
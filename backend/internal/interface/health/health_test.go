@@ -0,0 +1,82 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReady(t *testing.T) {
+	up := func(ctx context.Context) error { return nil }
+	down := func(ctx context.Context) error {
+		return errors.New("dial tcp 10.0.3.7:5432: password authentication failed for user noncord")
+	}
+
+	tests := []struct {
+		name       string
+		checks     map[string]Check
+		wantStatus int
+		wantReport report
+	}{
+		{
+			name:       "no checks",
+			wantStatus: http.StatusOK,
+			wantReport: report{Status: "ok"},
+		},
+		{
+			name:       "all up",
+			checks:     map[string]Check{"postgres": up, "redis": up},
+			wantStatus: http.StatusOK,
+			wantReport: report{Status: "ok", Checks: map[string]string{"postgres": "ok", "redis": "ok"}},
+		},
+		{
+			name:       "one down",
+			checks:     map[string]Check{"postgres": down, "redis": up},
+			wantStatus: http.StatusServiceUnavailable,
+			wantReport: report{Status: "unavailable", Checks: map[string]string{"postgres": "down", "redis": "ok"}},
+		},
+		{
+			name:       "never ran",
+			checks:     map[string]Check{"relayer": Recent(func() time.Time { return time.Time{} }, time.Minute)},
+			wantStatus: http.StatusServiceUnavailable,
+			wantReport: report{Status: "unavailable", Checks: map[string]string{"relayer": "down"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New()
+			for name, check := range tt.checks {
+				c.Add(name, check)
+			}
+
+			rec := httptest.NewRecorder()
+			c.Ready(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			body := rec.Body.String()
+			if strings.Contains(body, "10.0.3.7") || strings.Contains(body, "password") {
+				t.Errorf("body leaks the check error: %s", body)
+			}
+			var got report
+			if err := json.Unmarshal([]byte(body), &got); err != nil {
+				t.Fatalf("decode %q: %v", body, err)
+			}
+			if got.Status != tt.wantReport.Status || len(got.Checks) != len(tt.wantReport.Checks) {
+				t.Fatalf("report = %+v, want %+v", got, tt.wantReport)
+			}
+			for name, status := range tt.wantReport.Checks {
+				if got.Checks[name] != status {
+					t.Errorf("check %s = %q, want %q", name, got.Checks[name], status)
+				}
+			}
+		})
+	}
+}
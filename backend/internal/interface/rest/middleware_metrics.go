@@ -0,0 +1,41 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "noncord",
+	Subsystem: "http",
+	Name:      "request_duration_seconds",
+	Help:      "Latency of HTTP requests, by method, chi route and status code.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"method", "route", "status"})
+
+// MetricsMiddleware records the latency and status of every request under its
+// chi route, requests no route matched are grouped under "unmatched" so
+// scanners cannot blow up the label set.
+func MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		httpDuration.WithLabelValues(r.Method, route, strconv.Itoa(status)).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,40 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Serve runs srv until ctx is done, then drains it: the listener closes and
+// the requests in flight get up to timeout to finish. The onShutdown hooks run
+// alongside with the same deadline, e.g. to close hijacked websockets which
+// http.Server.Shutdown does not track.
+func Serve(ctx context.Context, srv *http.Server, timeout time.Duration, onShutdown ...func(context.Context) error) error {
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	errs := make([]error, len(onShutdown)+1)
+	var wg sync.WaitGroup
+	for i, hook := range onShutdown {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i+1] = hook(shutdownCtx)
+		}()
+	}
+	errs[0] = srv.Shutdown(shutdownCtx)
+	wg.Wait()
+	return errors.Join(errs...)
+}
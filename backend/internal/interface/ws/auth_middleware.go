@@ -12,7 +12,7 @@ import (
 func authMiddleware(authService interfaces.AuthService, token string) *uuid.UUID {
 	slog.Info("Verifying user")
 
-	res, err := authService.Authenticate(context.Background(), command.AuthenticateCommand{AccessToken: token})
+	res, err := authService.Authenticate(context.Background(), command.AuthenticateCommand{AccessToken: token, RequireActive: true})
 	if err != nil {
 		return nil
 	}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"log/slog"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -13,119 +14,217 @@ import (
 )
 
 const (
-	WS_VERSION      = 1
-	WS_AUTH_TIMEOUT = time.Second * 5
+	WS_VERSION = 2 // latest protocol version, see versions.go for what changed
 
 	AUTH_MESSAGE = "auth"
 
 	AUTH_FAILED_EVENT = "auth_failed"
+
+	// Close codes sent when the gateway drops a connection
+	CLOSE_AUTH_FAILED          = 4001
+	CLOSE_RATE_LIMITED         = 4008
+	CLOSE_TOO_MANY_CONNECTIONS = 4009
+	CLOSE_SLOW_CONSUMER        = 4010
 )
 
 type wsPayload struct {
 	EventType string `json:"eventType"`
+	Nonce     string `json:"nonce,omitempty"`
 	Payload   any    `json:"payload"`
 	Version   int32  `json:"version"`
 }
 
+type wsRequest struct {
+	EventType string          `json:"eventType"`
+	Nonce     string          `json:"nonce,omitempty"`
+	Payload   json.RawMessage `json:"payload"`
+	Version   int32           `json:"version"`
+}
+
 type client struct {
+	cfg    *Config
 	id     uuid.UUID
 	userId uuid.UUID
 	conn   *websocket.Conn
 
-	authService interfaces.AuthService
+	proto protocol
+	codec codec
+	zlib  *zlibStream // only set for zlib-stream connections
+
+	authService    interfaces.AuthService
+	messageService interfaces.MessageService
 
+	limiter      *gatewayLimiter
+	ops          opLimiter
+	authFailures int
+
+	// writeChan is never closed, done signals the write pump to stop instead so
+	// that a late Write can never send on a closed channel
 	writeChan chan any
+	done      chan struct{}
+	closeOnce sync.Once
+	closeMsg  []byte
 	isClose   atomic.Bool
-	auth      chan uuid.UUID
-	isAuth    atomic.Bool
+	// auth hands the user over to newClient once. It is buffered so that an
+	// auth finishing after newClient gave up never blocks the read pump.
+	auth   chan uuid.UUID
+	isAuth atomic.Bool
 
-	unsub chan<- *client
+	unsub chan<- peer
+	pumps *sync.WaitGroup
 }
 
-func newClient(authService interfaces.AuthService, conn *websocket.Conn, unsub chan<- *client) *client {
+func newClient(h *Hub, id uuid.UUID, conn *websocket.Conn, proto protocol) *client {
 	c := &client{
-		id:   uuid.New(),
+		cfg:  &h.cfg,
+		id:   id,
 		conn: conn,
 
-		authService: authService,
+		proto: proto,
+		codec: newCodec(proto.Encoding),
+
+		authService:    h.authService,
+		messageService: h.messageService,
+
+		limiter: h.limiter,
+		ops:     newOpLimiter(connOpLimits),
 
-		writeChan: make(chan any, 512),
+		writeChan: make(chan any, h.cfg.WriteQueue),
+		done:      make(chan struct{}),
 		isClose:   atomic.Bool{},
-		auth:      make(chan uuid.UUID),
+		auth:      make(chan uuid.UUID, 1),
 
-		unsub: unsub,
+		unsub: h.unsubChan,
+		pumps: &h.pumps,
 	}
+	if proto.Compress == COMPRESS_ZLIB_STREAM {
+		c.zlib = newZlibStream()
+	}
+	conn.EnableWriteCompression(proto.Compress == COMPRESS_PERMESSAGE)
 
+	c.pumps.Add(2)
 	go c.writePump()
 	go c.readPump()
 
-	c.isClose.Store(false)
-
-	ctx, cancel := context.WithTimeout(context.Background(), WS_AUTH_TIMEOUT)
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.AuthTimeout)
 	defer cancel()
 
 	select {
 	case <-ctx.Done():
 		c.Close()
 		return nil
-	case id, ok := <-c.auth:
+	case _, ok := <-c.auth:
 		if !ok {
 			c.Close()
 			return nil
 		}
-		c.userId = id
 		return c
 	}
 }
 
+// Close stops the write pump, which sends the close frame and closes the
+// connection, and tells the hub to drop the client. Safe to call more than once
+// and from any goroutine.
 func (c *client) Close() error {
-	if c.isClose.Load() {
-		return nil
-	}
-	c.isClose.Store(true)
-	close(c.writeChan)
-	return c.conn.Close()
+	c.closeWithCode(websocket.CloseNormalClosure, "")
+	return nil
+}
+
+func (c *client) closeWithCode(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.closeMsg = websocket.FormatCloseMessage(code, reason)
+		c.isClose.Store(true)
+		close(c.done)
+
+		select {
+		case c.unsub <- c:
+		default:
+			go func() { c.unsub <- c }()
+		}
+	})
 }
 
+func (c *client) goAway() {
+	c.closeWithCode(websocket.CloseGoingAway, "server shutting down")
+}
+
+func (c *client) connId() uuid.UUID    { return c.id }
+func (c *client) user() uuid.UUID      { return c.userId }
+func (c *client) closed() bool         { return c.isClose.Load() }
+func (c *client) deliver(evt hubEvent) { c.Write(evt.EventType, evt.Payload) }
+
 func (c *client) Write(eventType string, msg any) {
+	c.writeReply(eventType, "", msg)
+}
+
+// writeReply queues a frame without ever blocking the caller. A client whose
+// queue is full is too slow to keep up, the frame is dropped and the client is
+// disconnected so it can resync on reconnect.
+func (c *client) writeReply(eventType, nonce string, msg any) {
 	if c.isClose.Load() {
 		return
 	}
-	c.writeChan <- wsPayload{
-		EventType: eventType,
-		Payload:   msg,
-		Version:   WS_VERSION,
+
+	select {
+	case <-c.done:
+	case c.writeChan <- newWsPayload(c.proto.Version, eventType, nonce, msg):
+	default:
+		stats.framesDropped.Add(1)
+		stats.slowDisconnects.Add(1)
+		slog.Warn("write queue full, disconnecting slow client", "client", c.toSlogVal(), "eventType", eventType)
+		c.closeWithCode(CLOSE_SLOW_CONSUMER, "slow consumer")
 	}
 }
 
 func (c *client) writePump() {
-	tickerCh := time.Tick(30 * time.Second)
+	ticker := time.NewTicker(c.cfg.PingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+		c.pumps.Done()
+	}()
+
 	for {
 		select {
-		case _, ok := <-tickerCh:
-			if !ok {
-				return
-			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(c.cfg.WriteWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				slog.Warn("Ping failed to send", "client", c.toSlogVal())
-				c.conn.Close()
+				c.Close()
 				return
 			}
 
-		case msg, ok := <-c.writeChan:
-			if !ok {
-				slog.Warn("write channel is closed", "client", c.toSlogVal())
-				_ = c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				c.conn.Close()
-				return
-			}
-			err := c.conn.WriteJSON(msg)
-			if err != nil {
+		case <-c.done:
+			_ = c.conn.WriteControl(websocket.CloseMessage, c.closeMsg, time.Now().Add(c.cfg.WriteWait))
+			return
+
+		case msg := <-c.writeChan:
+			c.conn.SetWriteDeadline(time.Now().Add(c.cfg.WriteWait))
+			if err := c.writeFrame(msg); err != nil {
 				slog.Warn("cannot send message", "client", c.toSlogVal(), "error", err)
-				c.conn.Close()
+				c.Close()
+				return
 			}
+			stats.framesSent.Add(1)
+		}
+	}
+}
+
+func (c *client) writeFrame(msg any) error {
+	data, err := c.codec.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	msgType := c.codec.MessageType()
+	if c.zlib != nil {
+		if data, err = c.zlib.compress(data); err != nil {
+			return err
 		}
+		msgType = websocket.BinaryMessage
 	}
+
+	return c.conn.WriteMessage(msgType, data)
 }
 
 func (c *client) readPump() {
@@ -133,13 +232,14 @@ func (c *client) readPump() {
 	defer func() {
 		slog.Info("closing client", "client", c.toSlogVal())
 		c.Close()
+		c.pumps.Done()
 	}()
 
-	c.conn.SetReadLimit(512)
-	c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	c.conn.SetReadLimit(c.cfg.ReadLimit)
+	c.conn.SetReadDeadline(time.Now().Add(c.cfg.PongWait))
 	c.conn.SetPongHandler(func(string) error {
 		// extend deadline on pong
-		c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		c.conn.SetReadDeadline(time.Now().Add(c.cfg.PongWait))
 		return nil
 	})
 
@@ -150,40 +250,74 @@ func (c *client) readPump() {
 			break
 		}
 
-		var data wsPayload
-		if json.Unmarshal(msg, &data) == nil {
-			switch data.EventType {
-			case AUTH_MESSAGE:
-				str, ok := data.Payload.(string)
-				if !ok {
-					slog.Info("Unknown payload for auth message", "data", data, "client", c.toSlogVal())
-					continue
-				}
+		var data wsRequest
+		if c.codec.Unmarshal(msg, &data) != nil {
+			data = wsRequest{EventType: UNKNOWN_OP}
+		}
+		if !c.allow(data.EventType) {
+			slog.Info("client rate limited", "op", data.EventType, "client", c.toSlogVal())
+			c.closeWithCode(CLOSE_RATE_LIMITED, "rate limited")
+			break
+		}
 
-				userId := authMiddleware(c.authService, str)
-				if userId == nil {
-					slog.Info("attempt to authenticate token failed", "str", str, "client", c.toSlogVal())
-					c.Write(AUTH_FAILED_EVENT, "Authentication failed")
-					continue
-				}
+		switch data.EventType {
+		case AUTH_MESSAGE:
+			var str string
+			if json.Unmarshal(data.Payload, &str) != nil {
+				slog.Info("Unknown payload for auth message", "client", c.toSlogVal())
+				continue
+			}
 
-				slog.Info("Successfully authenticate user", "userId", userId.String(), "client", c.toSlogVal())
-				if c.isAuth.CompareAndSwap(false, true) {
-					c.auth <- *userId
-					close(c.auth)
+			userId := authMiddleware(c.authService, str)
+			if userId == nil {
+				slog.Info("attempt to authenticate token failed", "token", redactToken(str), "client", c.toSlogVal())
+				if c.authFailures++; c.authFailures >= c.cfg.MaxAuthFailures {
+					c.closeWithCode(CLOSE_AUTH_FAILED, "authentication failed")
+					return
 				}
+				c.Write(AUTH_FAILED_EVENT, "Authentication failed")
+				continue
+			}
 
-			default:
-				slog.Info("Incoming (unknown) ws message", "msg", string(msg), "client", c.toSlogVal())
+			slog.Info("Successfully authenticate user", "userId", userId.String(), "client", c.toSlogVal())
+			// only the read pump writes userId, and only before isAuth is set,
+			// so any reader that saw isAuth or received from auth sees it
+			if !c.isAuth.Load() {
+				c.userId = *userId
+				c.isAuth.Store(true)
+				c.auth <- *userId
+				close(c.auth)
 			}
-		} else {
-			slog.Info("Unknown message received", "msg", string(msg), "client", c.toSlogVal())
+
+		case UNKNOWN_OP:
+			slog.Info("Unknown message received", "size", len(msg), "client", c.toSlogVal())
+
+		default:
+			if handler, ok := opHandlers[data.EventType]; ok {
+				c.handleOp(handler, data)
+				continue
+			}
+			slog.Info("Incoming (unknown) ws message", "eventType", data.EventType, "client", c.toSlogVal())
 		}
+	}
+}
 
+// allow takes a token from the connection's bucket for the op, and from the
+// user's shared bucket once authenticated
+func (c *client) allow(op string) bool {
+	if _, ok := connOpLimits[op]; !ok {
+		op = UNKNOWN_OP
+	}
+	if !c.ops.allow(op) {
+		return false
 	}
+	return !c.isAuth.Load() || c.limiter.allowUser(c.userId, op)
 }
 
 func (c *client) toSlogVal() slog.Value {
+	if !c.isAuth.Load() {
+		return slog.GroupValue(slog.Attr{Key: "conn_id", Value: slog.StringValue(c.id.String())})
+	}
 	return slog.GroupValue(
 		slog.Attr{Key: "conn_id", Value: slog.StringValue(c.id.String())},
 		slog.Attr{Key: "user_id", Value: slog.StringValue(c.userId.String())},
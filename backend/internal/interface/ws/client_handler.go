@@ -1,61 +1,83 @@
 package ws
 
 import (
+	"backend/internal/application/ports"
 	"backend/internal/domain/entities"
+	"context"
+	"log/slog"
 )
 
+// handle registers a handler pushing events to clients. Replayed events
+// are dropped, clients got them when they happened and a replay would show
+// them again as new.
+func (h *Hub) handle(topic string, handler func(context.Context, ports.EventMessage) error) error {
+	return h.eventSubscriber.Subscribe(topic, func(ctx context.Context, event ports.EventMessage) error {
+		if event.IsReplay() {
+			slog.Default().Debug("Dropping replayed event", "eventType", event.EventType, "aggregateId", event.AggregateId)
+			return nil
+		}
+		return handler(ctx, event)
+	})
+}
+
 func (h *Hub) registerHandlers() error {
 	// Messages
-	if err := h.eventSubscriber.Subscribe(entities.EventMessageCreated, h.messageCreatedHandler); err != nil {
+	if err := h.handle(entities.EventMessageCreated, h.messageCreatedHandler); err != nil {
+		return err
+	}
+	if err := h.handle(entities.EventMessageEdited, h.messageEditedHandler); err != nil {
+		return err
+	}
+	if err := h.handle(entities.EventMessageDeleted, h.messageDeletedHandler); err != nil {
 		return err
 	}
-	if err := h.eventSubscriber.Subscribe(entities.EventMessageEdited, h.messageEditedHandler); err != nil {
+	if err := h.handle(entities.EventMessageReactionAdded, h.reactionAddedHandler); err != nil {
 		return err
 	}
-	if err := h.eventSubscriber.Subscribe(entities.EventMessageDeleted, h.messageDeletedHandler); err != nil {
+	if err := h.handle(entities.EventMessageReactionRemoved, h.reactionRemovedHandler); err != nil {
 		return err
 	}
 
 	// Channels
-	if err := h.eventSubscriber.Subscribe(entities.EventChannelCreated, h.channelCreatedHandler); err != nil {
+	if err := h.handle(entities.EventChannelCreated, h.channelCreatedHandler); err != nil {
 		return err
 	}
-	if err := h.eventSubscriber.Subscribe(entities.EventChannelDeleted, h.channelDeletedHandler); err != nil {
+	if err := h.handle(entities.EventChannelDeleted, h.channelDeletedHandler); err != nil {
 		return err
 	}
-	if err := h.eventSubscriber.Subscribe(entities.EventChannelNameUpdated, h.channelNameUpdatedHandler); err != nil {
+	if err := h.handle(entities.EventChannelNameUpdated, h.channelNameUpdatedHandler); err != nil {
 		return err
 	}
-	if err := h.eventSubscriber.Subscribe(entities.EventChannelOverwriteUpserted, h.channelOverwriteUpsertedHandler); err != nil {
+	if err := h.handle(entities.EventChannelOverwriteUpserted, h.channelOverwriteUpsertedHandler); err != nil {
 		return err
 	}
-	if err := h.eventSubscriber.Subscribe(entities.EventChannelOverwriteDeleted, h.channelOverwriteDeletedHandler); err != nil {
+	if err := h.handle(entities.EventChannelOverwriteDeleted, h.channelOverwriteDeletedHandler); err != nil {
 		return err
 	}
-	if err := h.eventSubscriber.Subscribe(entities.EventChannelOrderChanged, h.channelOrderChangedHandler); err != nil {
+	if err := h.handle(entities.EventChannelOrderChanged, h.channelOrderChangedHandler); err != nil {
 		return err
 	}
-	if err := h.eventSubscriber.Subscribe(entities.EventChannelDescriptionUpdated, h.channelDescriptionUpdatedHandler); err != nil {
+	if err := h.handle(entities.EventChannelDescriptionUpdated, h.channelDescriptionUpdatedHandler); err != nil {
 		return err
 	}
-	if err := h.eventSubscriber.Subscribe(entities.EventChannelParentCategoryChanged, h.channelParentCategoryChangedHandler); err != nil {
+	if err := h.handle(entities.EventChannelParentCategoryChanged, h.channelParentCategoryChangedHandler); err != nil {
 		return err
 	}
 
 	// Servers
-	if err := h.eventSubscriber.Subscribe(entities.EventServerCreated, h.serverCreatedHandler); err != nil {
+	if err := h.handle(entities.EventServerCreated, h.serverCreatedHandler); err != nil {
 		return err
 	}
-	if err := h.eventSubscriber.Subscribe(entities.EventServerDeleted, h.serverDeletedHandler); err != nil {
+	if err := h.handle(entities.EventServerDeleted, h.serverDeletedHandler); err != nil {
 		return err
 	}
-	if err := h.eventSubscriber.Subscribe(entities.EventServerNameUpdated, h.serverNameUpdatedHandler); err != nil {
+	if err := h.handle(entities.EventServerNameUpdated, h.serverNameUpdatedHandler); err != nil {
 		return err
 	}
-	if err := h.eventSubscriber.Subscribe(entities.EventServerBannerURLUpdated, h.serverBannerURLUpdatedHandler); err != nil {
+	if err := h.handle(entities.EventServerBannerURLUpdated, h.serverBannerURLUpdatedHandler); err != nil {
 		return err
 	}
-	if err := h.eventSubscriber.Subscribe(entities.EventServerIconURLUpdated, h.serverIconURLUpdatedHandler); err != nil {
+	if err := h.handle(entities.EventServerIconURLUpdated, h.serverIconURLUpdatedHandler); err != nil {
 		return err
 	}
 
@@ -8,6 +8,9 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 const (
@@ -16,8 +19,30 @@ const (
 	incomingMessageEvent = "incoming_message"
 	messageUpdatedEvent  = "message_updated"
 	messageDeletedEvent  = "message_deleted"
+	reactionAddedEvent   = "reaction_added"
+	reactionRemovedEvent = "reaction_removed"
 )
 
+type messageUpdatedPayload struct {
+	Id        uuid.UUID  `json:"id"`
+	ChannelId *uuid.UUID `json:"channelId"`
+	Message   string     `json:"message"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+type messageDeletedPayload struct {
+	Id        uuid.UUID  `json:"id"`
+	ChannelId *uuid.UUID `json:"channelId"`
+	DeletedAt time.Time  `json:"deletedAt"`
+}
+
+type reactionPayload struct {
+	MessageId uuid.UUID  `json:"messageId"`
+	ChannelId *uuid.UUID `json:"channelId"`
+	UserId    uuid.UUID  `json:"userId"`
+	EmoteId   uuid.UUID  `json:"emoteId"`
+}
+
 func (h *Hub) messageCreatedHandler(ctx context.Context, event ports.EventMessage) error {
 	slog.Default().Info("Incoming message", "event", slog.GroupValue(
 		slog.Attr{
@@ -34,15 +59,13 @@ func (h *Hub) messageCreatedHandler(ctx context.Context, event ports.EventMessag
 		},
 	))
 
-	e, err := events.ParseSpecificEvent[entities.MessageCreated](event.Payload, entities.EventMessageCreated, entities.MessageCreatedSchemaVersion)
+	e, err := events.ParseLatest[entities.MessageCreated](event.Payload, entities.EventMessageCreated)
 	if err != nil {
 		slog.Default().Warn("Unabled to parse event", "error", err)
 		return err
 	}
 
 	slog.Default().Info("Parsed event", "event", e)
-	h.m.RLock()
-	defer h.m.RUnlock()
 	message := response.Message{
 		Id:          e.AggregateID,
 		CreatedAt:   e.OccurredAt,
@@ -87,25 +110,86 @@ func (h *Hub) messageCreatedHandler(ctx context.Context, event ports.EventMessag
 	}
 
 	if e.ChannelID != nil {
-		if _, ok := h.channelSub[*e.ChannelID]; !ok {
-			slog.Default().Info("no listener on channel", "channel_id", e.ChannelID.String())
-			return nil
-		}
-
-		for uId := range h.channelSub[*e.ChannelID] {
-			for _, c := range h.userConn[uId] {
-				c.Write(incomingMessageEvent, message)
-			}
-		}
+		h.broadcastChannel(*e.ChannelID, incomingMessageEvent, message)
 	}
 
 	return nil
 }
 
+// The handlers below only reach channel members, group messages and events
+// recorded before they carried the channel id are not broadcast.
+
 func (h *Hub) messageEditedHandler(ctx context.Context, event ports.EventMessage) error {
+	e, err := events.ParseLatest[entities.MessageEdited](event.Payload, entities.EventMessageEdited)
+	if err != nil {
+		slog.Default().Warn("Unabled to parse event", "error", err)
+		return err
+	}
+
+	if e.ChannelID != nil {
+		h.broadcastChannel(*e.ChannelID, messageUpdatedEvent, messageUpdatedPayload{
+			Id:        e.AggregateID,
+			ChannelId: e.ChannelID,
+			Message:   e.New,
+			UpdatedAt: e.OccurredAt,
+		})
+	}
+
 	return nil
 }
 
 func (h *Hub) messageDeletedHandler(ctx context.Context, event ports.EventMessage) error {
+	e, err := events.ParseLatest[entities.MessageDeleted](event.Payload, entities.EventMessageDeleted)
+	if err != nil {
+		slog.Default().Warn("Unabled to parse event", "error", err)
+		return err
+	}
+
+	if e.ChannelID != nil {
+		h.broadcastChannel(*e.ChannelID, messageDeletedEvent, messageDeletedPayload{
+			Id:        e.AggregateID,
+			ChannelId: e.ChannelID,
+			DeletedAt: e.DeletedAt,
+		})
+	}
+
+	return nil
+}
+
+func (h *Hub) reactionAddedHandler(ctx context.Context, event ports.EventMessage) error {
+	e, err := events.ParseLatest[entities.MessageReactionAdded](event.Payload, entities.EventMessageReactionAdded)
+	if err != nil {
+		slog.Default().Warn("Unabled to parse event", "error", err)
+		return err
+	}
+
+	if e.ChannelID != nil {
+		h.broadcastChannel(*e.ChannelID, reactionAddedEvent, reactionPayload{
+			MessageId: e.AggregateID,
+			ChannelId: e.ChannelID,
+			UserId:    e.UserID,
+			EmoteId:   e.EmoteID,
+		})
+	}
+
+	return nil
+}
+
+func (h *Hub) reactionRemovedHandler(ctx context.Context, event ports.EventMessage) error {
+	e, err := events.ParseLatest[entities.MessageReactionRemoved](event.Payload, entities.EventMessageReactionRemoved)
+	if err != nil {
+		slog.Default().Warn("Unabled to parse event", "error", err)
+		return err
+	}
+
+	if e.ChannelID != nil {
+		h.broadcastChannel(*e.ChannelID, reactionRemovedEvent, reactionPayload{
+			MessageId: e.AggregateID,
+			ChannelId: e.ChannelID,
+			UserId:    e.UserID,
+			EmoteId:   e.EmoteID,
+		})
+	}
+
 	return nil
 }
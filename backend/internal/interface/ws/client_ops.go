@@ -0,0 +1,178 @@
+package ws
+
+import (
+	"backend/internal/application/command"
+	"backend/internal/domain/entities"
+	"backend/internal/interface/dto/mapper"
+	"backend/internal/interface/dto/request"
+	"backend/internal/interface/dto/response"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	WS_OP_TIMEOUT = time.Second * 10
+
+	MESSAGE_CREATE_OP  = "message_create"
+	MESSAGE_EDIT_OP    = "message_edit"
+	MESSAGE_DELETE_OP  = "message_delete"
+	REACTION_ADD_OP    = "reaction_add"
+	REACTION_REMOVE_OP = "reaction_remove"
+
+	ACK_EVENT   = "ack"
+	ERROR_EVENT = "error"
+)
+
+type wsEditMessage struct {
+	MessageId uuid.UUID `json:"messageId"`
+	Content   string    `json:"content"`
+}
+
+type wsDeleteMessage struct {
+	MessageId uuid.UUID `json:"messageId"`
+}
+
+type wsReaction struct {
+	MessageId uuid.UUID `json:"messageId"`
+	EmoteId   uuid.UUID `json:"emoteId"`
+}
+
+type wsError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type opHandler func(ctx context.Context, c *client, payload json.RawMessage) (any, error)
+
+var opHandlers = map[string]opHandler{
+	MESSAGE_CREATE_OP:  createMessageOp,
+	MESSAGE_EDIT_OP:    editMessageOp,
+	MESSAGE_DELETE_OP:  deleteMessageOp,
+	REACTION_ADD_OP:    addReactionOp,
+	REACTION_REMOVE_OP: removeReactionOp,
+}
+
+// handleOp runs a client op and answers with an ack or error frame carrying the op nonce
+func (c *client) handleOp(handler opHandler, req wsRequest) {
+	if !c.isAuth.Load() {
+		c.writeReply(ERROR_EVENT, req.Nonce, wsError{Code: entities.ErrCodeUnauth, Message: "Not authenticated"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.OpTimeout)
+	defer cancel()
+
+	res, err := handler(ctx, c, req.Payload)
+	if err != nil {
+		slog.Info("client op failed", "op", req.EventType, "nonce", req.Nonce, "error", err, "client", c.toSlogVal())
+		c.writeReply(ERROR_EVENT, req.Nonce, parseOpError(err))
+		return
+	}
+
+	c.writeReply(ACK_EVENT, req.Nonce, res)
+}
+
+func parseOpError(err error) wsError {
+	var derr *entities.ChatError
+	if !errors.As(err, &derr) {
+		return wsError{Code: entities.ErrCodeDepFail, Message: "Internal server error"}
+	}
+
+	switch derr.Code {
+	case entities.ErrCodeValidationError, entities.ErrCodeNoObject, entities.ErrCodeForbidden, entities.ErrCodeUnauth:
+		return wsError{Code: string(derr.Code), Message: derr.Message}
+	default:
+		return wsError{Code: entities.ErrCodeDepFail, Message: "Internal server error"}
+	}
+}
+
+func invalidPayload(err error) error {
+	return entities.NewError(entities.ErrCodeValidationError, "Invalid payload", err)
+}
+
+func createMessageOp(ctx context.Context, c *client, payload json.RawMessage) (any, error) {
+	var body request.CreateMessage
+	if err := json.Unmarshal(payload, &body); err != nil {
+		return nil, invalidPayload(err)
+	}
+	if err := body.Bind(nil); err != nil {
+		return nil, invalidPayload(err)
+	}
+
+	userId := c.userId
+	msg, err := c.messageService.Create(ctx, command.CreateMessageCommand{
+		UserId:          &userId,
+		AuthorType:      "user",
+		TargetId:        body.TargetId,
+		Content:         body.Content,
+		IsTargetChannel: body.IsTargetChannel,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return response.CreateMessage{Id: msg.Result.Id, CreatedAt: msg.Result.CreatedAt}, nil
+}
+
+func editMessageOp(ctx context.Context, c *client, payload json.RawMessage) (any, error) {
+	var body wsEditMessage
+	if err := json.Unmarshal(payload, &body); err != nil {
+		return nil, invalidPayload(err)
+	}
+
+	msg, err := c.messageService.Update(ctx, command.UpdateMessageCommand{
+		MessageId: body.MessageId,
+		UserId:    c.userId,
+		Content:   body.Content,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mapper.ParseCommonMessage(msg.Result), nil
+}
+
+func deleteMessageOp(ctx context.Context, c *client, payload json.RawMessage) (any, error) {
+	var body wsDeleteMessage
+	if err := json.Unmarshal(payload, &body); err != nil {
+		return nil, invalidPayload(err)
+	}
+
+	// HasMessageManagement stays unset, the service resolves ManageMessages in
+	// the message's channel when the user is not the author
+	return nil, c.messageService.Delete(ctx, command.DeleteMessageCommand{
+		MessageId: body.MessageId,
+		UserId:    c.userId,
+	})
+}
+
+func addReactionOp(ctx context.Context, c *client, payload json.RawMessage) (any, error) {
+	var body wsReaction
+	if err := json.Unmarshal(payload, &body); err != nil {
+		return nil, invalidPayload(err)
+	}
+
+	return nil, c.messageService.AddReaction(ctx, command.ReactMessageCommand{
+		MessageId: body.MessageId,
+		UserId:    c.userId,
+		EmoteId:   body.EmoteId,
+	})
+}
+
+func removeReactionOp(ctx context.Context, c *client, payload json.RawMessage) (any, error) {
+	var body wsReaction
+	if err := json.Unmarshal(payload, &body); err != nil {
+		return nil, invalidPayload(err)
+	}
+
+	return nil, c.messageService.RemoveReaction(ctx, command.ReactMessageCommand{
+		MessageId: body.MessageId,
+		UserId:    c.userId,
+		EmoteId:   body.EmoteId,
+	})
+}
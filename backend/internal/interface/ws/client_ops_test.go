@@ -0,0 +1,156 @@
+package ws
+
+import (
+	"backend/internal/application/command"
+	"backend/internal/application/common"
+	"backend/internal/domain/entities"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakeMessageService records the user each command ran as and fails with err
+type fakeMessageService struct {
+	err    error
+	userId uuid.UUID
+	calls  int
+}
+
+func (s *fakeMessageService) record(userId uuid.UUID) error {
+	s.calls++
+	s.userId = userId
+	return s.err
+}
+
+func (s *fakeMessageService) Create(ctx context.Context, params command.CreateMessageCommand) (command.CreateMessageCommandResult, error) {
+	if err := s.record(*params.UserId); err != nil {
+		return command.CreateMessageCommandResult{}, err
+	}
+	return command.CreateMessageCommandResult{Result: &common.Message{Id: uuid.New(), CreatedAt: time.Now()}}, nil
+}
+
+func (s *fakeMessageService) CreateSystemMessage(ctx context.Context, params command.CreateSystemMessageCommand) error {
+	return errors.New("not used by ops")
+}
+
+func (s *fakeMessageService) Update(ctx context.Context, params command.UpdateMessageCommand) (command.UpdateMessageCommandResult, error) {
+	if err := s.record(params.UserId); err != nil {
+		return command.UpdateMessageCommandResult{}, err
+	}
+	return command.UpdateMessageCommandResult{Result: &common.Message{Id: params.MessageId, Message: params.Content}}, nil
+}
+
+func (s *fakeMessageService) Delete(ctx context.Context, params command.DeleteMessageCommand) error {
+	return s.record(params.UserId)
+}
+
+func (s *fakeMessageService) AddReaction(ctx context.Context, params command.ReactMessageCommand) error {
+	return s.record(params.UserId)
+}
+
+func (s *fakeMessageService) RemoveReaction(ctx context.Context, params command.ReactMessageCommand) error {
+	return s.record(params.UserId)
+}
+
+func TestOpHandlers(t *testing.T) {
+	forbidden := entities.NewError(entities.ErrCodeForbidden, "missing channel permission", nil)
+	payloads := map[string]string{
+		MESSAGE_CREATE_OP:  `{"targetId":"` + uuid.NewString() + `","isTargetChannel":true,"content":"hi"}`,
+		MESSAGE_EDIT_OP:    `{"messageId":"` + uuid.NewString() + `","content":"edited"}`,
+		MESSAGE_DELETE_OP:  `{"messageId":"` + uuid.NewString() + `"}`,
+		REACTION_ADD_OP:    `{"messageId":"` + uuid.NewString() + `","emoteId":"` + uuid.NewString() + `"}`,
+		REACTION_REMOVE_OP: `{"messageId":"` + uuid.NewString() + `","emoteId":"` + uuid.NewString() + `"}`,
+	}
+
+	tests := []struct {
+		name       string
+		payload    func(op string) string
+		serviceErr error
+		wantCode   string
+		wantCalls  int
+	}{
+		{name: "allowed", payload: func(op string) string { return payloads[op] }, wantCalls: 1},
+		{name: "forbidden", payload: func(op string) string { return payloads[op] }, serviceErr: forbidden, wantCode: entities.ErrCodeForbidden, wantCalls: 1},
+		{name: "malformed payload", payload: func(string) string { return `{"messageId":` }, wantCode: entities.ErrCodeValidationError},
+		{name: "wrong payload types", payload: func(string) string { return `{"messageId":42,"targetId":42}` }, wantCode: entities.ErrCodeValidationError},
+	}
+
+	for op, handler := range opHandlers {
+		for _, tt := range tests {
+			t.Run(op+"/"+tt.name, func(t *testing.T) {
+				svc := &fakeMessageService{err: tt.serviceErr}
+				c := &client{userId: uuid.New(), messageService: svc}
+
+				_, err := handler(context.Background(), c, json.RawMessage(tt.payload(op)))
+				if tt.wantCode == "" {
+					if err != nil {
+						t.Fatalf("err = %v, want nil", err)
+					}
+				} else if got := parseOpError(err).Code; got != tt.wantCode {
+					t.Fatalf("code = %q, want %q (err %v)", got, tt.wantCode, err)
+				}
+				if svc.calls != tt.wantCalls {
+					t.Fatalf("service calls = %d, want %d", svc.calls, tt.wantCalls)
+				}
+				if svc.calls > 0 && svc.userId != c.userId {
+					t.Errorf("service ran as %s, want the socket user %s", svc.userId, c.userId)
+				}
+			})
+		}
+	}
+}
+
+func TestCreateMessageOpValidates(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+	}{
+		{name: "missing target", payload: `{"content":"hi"}`},
+		{name: "missing content", payload: `{"targetId":"` + uuid.NewString() + `"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeMessageService{}
+			c := &client{userId: uuid.New(), messageService: svc}
+
+			_, err := createMessageOp(context.Background(), c, json.RawMessage(tt.payload))
+			if got := parseOpError(err).Code; got != entities.ErrCodeValidationError {
+				t.Fatalf("code = %q, want %q", got, entities.ErrCodeValidationError)
+			}
+			if svc.calls != 0 {
+				t.Errorf("service called %d times for an invalid message", svc.calls)
+			}
+		})
+	}
+}
+
+func TestParseOpError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode string
+		wantMsg  string
+	}{
+		{name: "validation", err: entities.NewError(entities.ErrCodeValidationError, "Invalid payload", nil), wantCode: entities.ErrCodeValidationError, wantMsg: "Invalid payload"},
+		{name: "no object", err: entities.NewError(entities.ErrCodeNoObject, "message not found", nil), wantCode: entities.ErrCodeNoObject, wantMsg: "message not found"},
+		{name: "forbidden", err: entities.NewError(entities.ErrCodeForbidden, "missing channel permission", nil), wantCode: entities.ErrCodeForbidden, wantMsg: "missing channel permission"},
+		{name: "unauthorized", err: entities.NewError(entities.ErrCodeUnauth, "Not authenticated", nil), wantCode: entities.ErrCodeUnauth, wantMsg: "Not authenticated"},
+		{name: "wrapped chat error", err: errors.Join(errors.New("op"), entities.NewError(entities.ErrCodeForbidden, "nope", nil)), wantCode: entities.ErrCodeForbidden, wantMsg: "nope"},
+		{name: "dependency failure hides the cause", err: entities.NewError(entities.ErrCodeDepFail, "pg: connection refused", nil), wantCode: entities.ErrCodeDepFail, wantMsg: "Internal server error"},
+		{name: "plain error", err: errors.New("boom"), wantCode: entities.ErrCodeDepFail, wantMsg: "Internal server error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseOpError(tt.err)
+			if got.Code != tt.wantCode || got.Message != tt.wantMsg {
+				t.Errorf("parseOpError = %+v, want {%s %s}", got, tt.wantCode, tt.wantMsg)
+			}
+		})
+	}
+}
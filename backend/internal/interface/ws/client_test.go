@@ -0,0 +1,231 @@
+package ws
+
+import (
+	"backend/internal/application/command"
+	"backend/internal/application/interfaces"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// gatedAuth accepts any token, once release is closed
+type gatedAuth struct {
+	interfaces.AuthService
+	userId  uuid.UUID
+	started chan struct{}
+	release chan struct{}
+}
+
+func (a *gatedAuth) Authenticate(ctx context.Context, params command.AuthenticateCommand) (command.AuthenticateCommandResult, error) {
+	close(a.started)
+	<-a.release
+	return command.AuthenticateCommandResult{UserId: &a.userId}, nil
+}
+
+func newTestHub(cfg Config, authService interfaces.AuthService) *Hub {
+	return &Hub{
+		cfg:         cfg,
+		state:       newHubState(),
+		authService: authService,
+		unsubChan:   make(chan peer, 16),
+		limiter:     newGatewayLimiter(cfg.MaxConnsPerUser, cfg.MaxConnsPerIP),
+	}
+}
+
+// dialTestClient connects to a server that runs newClient on every upgrade and
+// returns the dialer's end with the channel newClient's result arrives on
+func dialTestClient(t *testing.T, h *Hub) (*websocket.Conn, <-chan *client) {
+	t.Helper()
+
+	clients := make(chan *client, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		clients <- newClient(h, uuid.New(), conn, protocol{Version: 1, Encoding: ENCODING_JSON})
+	}))
+	t.Cleanup(srv.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, clients
+}
+
+func waitPumps(t *testing.T, h *Hub) {
+	t.Helper()
+
+	done := make(chan struct{})
+	go func() {
+		h.pumps.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("client pumps never stopped")
+	}
+}
+
+func TestNewClientLateAuthReleasesPumps(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.AuthTimeout = 20 * time.Millisecond
+	auth := &gatedAuth{userId: uuid.New(), started: make(chan struct{}), release: make(chan struct{})}
+	h := newTestHub(cfg, auth)
+
+	conn, clients := dialTestClient(t, h)
+	token, _ := json.Marshal("token")
+	if err := conn.WriteJSON(wsRequest{EventType: AUTH_MESSAGE, Payload: token}); err != nil {
+		t.Fatalf("write auth: %v", err)
+	}
+
+	// the auth is in flight when the timeout wins
+	<-auth.started
+	if c := <-clients; c != nil {
+		t.Fatal("newClient returned a client after the auth timeout")
+	}
+	close(auth.release)
+
+	waitPumps(t, h)
+}
+
+func TestNewClientAuthenticates(t *testing.T) {
+	auth := &gatedAuth{userId: uuid.New(), started: make(chan struct{}), release: make(chan struct{})}
+	close(auth.release)
+	h := newTestHub(DefaultConfig, auth)
+
+	conn, clients := dialTestClient(t, h)
+	token, _ := json.Marshal("token")
+	if err := conn.WriteJSON(wsRequest{EventType: AUTH_MESSAGE, Payload: token}); err != nil {
+		t.Fatalf("write auth: %v", err)
+	}
+
+	c := <-clients
+	if c == nil {
+		t.Fatal("newClient gave up on an authenticated client")
+	}
+	if c.user() != auth.userId {
+		t.Errorf("user = %s, want %s", c.user(), auth.userId)
+	}
+
+	c.Close()
+	waitPumps(t, h)
+}
+
+// newQueueClient is a client without a connection, whose write queue is only
+// drained by the test
+func newQueueClient(h *Hub, userId uuid.UUID) *client {
+	c := &client{
+		cfg:       &h.cfg,
+		id:        uuid.New(),
+		userId:    userId,
+		proto:     protocol{Version: WS_MAX_VERSION, Encoding: ENCODING_JSON},
+		writeChan: make(chan any, h.cfg.WriteQueue),
+		done:      make(chan struct{}),
+		auth:      make(chan uuid.UUID, 1),
+		unsub:     h.unsubChan,
+		pumps:     &h.pumps,
+	}
+	c.isAuth.Store(true)
+	return c
+}
+
+func TestSlowConsumerIsDropped(t *testing.T) {
+	tests := []struct {
+		name       string
+		writeQueue int
+	}{
+		{name: "queue of one", writeQueue: 1},
+		{name: "queue of eight", writeQueue: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig
+			cfg.WriteQueue = tt.writeQueue
+			h := newTestHub(cfg, nil)
+			h.replay = newReplayLog(uuid.New(), 16)
+			ctx, cancel := context.WithCancel(context.Background())
+			t.Cleanup(cancel)
+			go h.unsubLoop(ctx)
+
+			channelId := uuid.New()
+			slow := newQueueClient(h, uuid.New())
+			fast := newQueueClient(h, uuid.New())
+			h.state.addClient(slow, []uuid.UUID{channelId}, nil)
+			h.state.addClient(fast, []uuid.UUID{channelId}, nil)
+
+			t.Cleanup(func() { fast.Close() })
+			received := make(chan wsPayload, 1)
+			go func() {
+				for {
+					select {
+					case <-fast.done:
+						return
+					case msg := <-fast.writeChan:
+						received <- msg.(wsPayload)
+					}
+				}
+			}()
+			broadcast := func(i int) {
+				t.Helper()
+				h.broadcastChannel(channelId, "MESSAGE_CREATE", map[string]int{"i": i})
+				select {
+				case msg := <-received:
+					if got := msg.Payload.(map[string]int)["i"]; got != i {
+						t.Fatalf("fast peer got frame %d, want %d", got, i)
+					}
+				case <-time.After(2 * time.Second):
+					t.Fatalf("fast peer never got frame %d", i)
+				}
+			}
+
+			dropped, disconnects := stats.framesDropped.Load(), stats.slowDisconnects.Load()
+			// the queue fills up, the next frame overflows it
+			for i := range tt.writeQueue + 1 {
+				broadcast(i)
+			}
+
+			if !slow.closed() {
+				t.Fatal("slow peer is still connected")
+			}
+			if want := websocket.FormatCloseMessage(CLOSE_SLOW_CONSUMER, "slow consumer"); !slices.Equal(slow.closeMsg, want) {
+				t.Errorf("close message = %q, want %q", slow.closeMsg, want)
+			}
+			if got := stats.framesDropped.Load() - dropped; got != 1 {
+				t.Errorf("%d frames dropped, want 1", got)
+			}
+			if got := stats.slowDisconnects.Load() - disconnects; got != 1 {
+				t.Errorf("%d slow disconnects, want 1", got)
+			}
+			if fast.closed() {
+				t.Fatal("fast peer was disconnected")
+			}
+
+			deadline := time.Now().Add(2 * time.Second)
+			for len(h.state.topicClients(h.state.channels, channelId)) != 1 {
+				if time.Now().After(deadline) {
+					t.Fatal("slow peer is still subscribed")
+				}
+				time.Sleep(time.Millisecond)
+			}
+			// the fast peer keeps receiving once the slow one is gone
+			broadcast(tt.writeQueue + 1)
+			if len(slow.writeChan) != tt.writeQueue {
+				t.Errorf("slow peer queue holds %d frames, want %d", len(slow.writeChan), tt.writeQueue)
+			}
+		})
+	}
+}
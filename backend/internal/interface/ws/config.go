@@ -0,0 +1,42 @@
+package ws
+
+import "time"
+
+type Config struct {
+	AuthTimeout time.Duration `yaml:"auth_timeout" env:"WS_AUTH_TIMEOUT"` // to send the auth frame after connecting
+	ReadLimit   int64         `yaml:"read_limit" env:"WS_READ_LIMIT"`     // largest frame read from a client
+	WriteWait   time.Duration `yaml:"write_wait" env:"WS_WRITE_WAIT"`
+	PingPeriod  time.Duration `yaml:"ping_period" env:"WS_PING_PERIOD"` // also the SSE keep alive
+	PongWait    time.Duration `yaml:"pong_wait" env:"WS_PONG_WAIT"`     // must be longer than PingPeriod
+	WriteQueue  int           `yaml:"write_queue" env:"WS_WRITE_QUEUE"` // frames queued per client before it is dropped as too slow
+	OpTimeout   time.Duration `yaml:"op_timeout" env:"WS_OP_TIMEOUT"`
+
+	MaxConnsPerUser int `yaml:"max_conns_per_user" env:"WS_MAX_CONNS_PER_USER"`
+	MaxConnsPerIP   int `yaml:"max_conns_per_ip" env:"WS_MAX_CONNS_PER_IP"`
+	MaxAuthFailures int `yaml:"max_auth_failures" env:"WS_MAX_AUTH_FAILURES"`
+	// TrustedProxies are the CIDRs or addresses of the proxies in front of the
+	// gateway, only their X-Forwarded-For and X-Real-IP headers are believed
+	TrustedProxies []string `yaml:"trusted_proxies" env:"WS_TRUSTED_PROXIES" validate:"cidrs"`
+
+	ReplayLogSize int           `yaml:"replay_log_size" env:"WS_REPLAY_LOG_SIZE"` // frames kept for SSE clients resuming with Last-Event-ID
+	SSERetry      time.Duration `yaml:"sse_retry" env:"WS_SSE_RETRY"`             // reconnect delay advertised to EventSource
+	SSETicketTTL  time.Duration `yaml:"sse_ticket_ttl" env:"WS_SSE_TICKET_TTL"`   // lifetime of the single use tickets EventSource connects with
+}
+
+var DefaultConfig = Config{
+	AuthTimeout: 5 * time.Second,
+	ReadLimit:   32 * 1024, // fits a max length message with utf-8 content and the frame envelope
+	WriteWait:   10 * time.Second,
+	PingPeriod:  30 * time.Second,
+	PongWait:    60 * time.Second,
+	WriteQueue:  512,
+	OpTimeout:   10 * time.Second,
+
+	MaxConnsPerUser: 10,
+	MaxConnsPerIP:   50,
+	MaxAuthFailures: 3,
+
+	ReplayLogSize: 4096,
+	SSERetry:      3 * time.Second,
+	SSETicketTTL:  30 * time.Second,
+}
@@ -4,6 +4,8 @@ import (
 	"backend/internal/application/interfaces"
 	"log/slog"
 	"net/http"
+
+	"github.com/google/uuid"
 )
 
 func ServeWs(hub *Hub, authService interfaces.AuthService, w http.ResponseWriter, r *http.Request) {
@@ -12,12 +14,25 @@ func ServeWs(hub *Hub, authService interfaces.AuthService, w http.ResponseWriter
 	// 	return
 	// }
 
+	proto, err := negotiateProtocol(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	connId := uuid.New()
+	if !hub.limiter.acquireIP(connId, hub.proxies.clientIP(r)) {
+		http.Error(w, "Too many connections", http.StatusTooManyRequests)
+		return
+	}
+
 	conn, err := Upgrader.Upgrade(w, r, nil)
 	if err != nil {
+		hub.limiter.release(connId)
 		return
 	}
 
-	if err = hub.Register(r.Context(), conn); err != nil {
+	if err = hub.Register(r.Context(), connId, conn, proto); err != nil {
 		slog.Error("Fail to register to the ws hub", "error", err)
 		return
 	}
@@ -0,0 +1,63 @@
+package ws
+
+import (
+	"backend/internal/interface/asyncapi"
+	"backend/internal/interface/dto/request"
+	"backend/internal/interface/dto/response"
+)
+
+// Gateway documents the frames of the gateway protocol at its latest version
+// for the AsyncAPI catalog. Add a frame here when the gateway learns to send
+// or receive a new event type.
+func Gateway() asyncapi.Channel {
+	return asyncapi.Channel{
+		Name:    "gateway",
+		Address: "/ws",
+		Description: "WebSocket gateway, also streamed from /sse. Connect with ?v=<version>&encoding=json|msgpack&compress=permessage-deflate|zlib-stream " +
+			"and send an auth frame first. Shapes are those of the latest protocol version, older versions are downgraded by the gateway.",
+		Messages: []asyncapi.Message{
+			sent(initializedEvent, "Sent once the client is subscribed", initializedPayload{}),
+			sent(incomingMessageEvent, "A message was posted in a channel the client can see", response.Message{}),
+			sent(messageUpdatedEvent, "A message was edited", messageUpdatedPayload{}),
+			sent(messageDeletedEvent, "A message was deleted", messageDeletedPayload{}),
+			sent(reactionAddedEvent, "A user reacted to a message", reactionPayload{}),
+			sent(reactionRemovedEvent, "A user removed their reaction", reactionPayload{}),
+			sent(AUTH_FAILED_EVENT, "The auth token was rejected", ""),
+			sent(ACK_EVENT, "An op succeeded, nonce is the one of the op", asyncapi.Describe(
+				"message_create: {id, createdAt}, message_edit: the edited message, other ops: null")),
+			sent(ERROR_EVENT, "An op failed, nonce is the one of the op", wsError{}),
+			sent(resyncRequiredEvent, "Events after lastEventId are gone, the client must reload its state", map[string]string{}),
+
+			received(AUTH_MESSAGE, "Authenticates the connection with a JWT", ""),
+			received(MESSAGE_CREATE_OP, "Posts a message", request.CreateMessage{}),
+			received(MESSAGE_EDIT_OP, "Edits a message of the user", wsEditMessage{}),
+			received(MESSAGE_DELETE_OP, "Deletes a message of the user", wsDeleteMessage{}),
+			received(REACTION_ADD_OP, "Reacts to a message", wsReaction{}),
+			received(REACTION_REMOVE_OP, "Removes a reaction of the user", wsReaction{}),
+		},
+	}
+}
+
+func sent(eventType, summary string, payload any) asyncapi.Message {
+	return frame(asyncapi.ACTION_SEND, asyncapi.SchemaOf(wsPayload{}), eventType, summary, payload)
+}
+
+func received(eventType, summary string, payload any) asyncapi.Message {
+	return frame(asyncapi.ACTION_RECEIVE, asyncapi.SchemaOf(wsRequest{}), eventType, summary, payload)
+}
+
+func frame(action asyncapi.Action, envelope asyncapi.Schema, eventType, summary string, payload any) asyncapi.Message {
+	payloadSchema, ok := payload.(asyncapi.Schema)
+	if !ok {
+		payloadSchema = asyncapi.SchemaOf(payload)
+	}
+	return asyncapi.Message{
+		Name:    "gateway." + eventType,
+		Title:   eventType,
+		Summary: summary,
+		Action:  action,
+		Payload: envelope.
+			Property("eventType", asyncapi.Schema{"const": eventType}).
+			Property("payload", payloadSchema),
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"backend/internal/application/ports"
 	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,39 +16,52 @@ import (
 )
 
 type Hub struct {
-	userConn   map[uuid.UUID]map[uuid.UUID]*client
-	serverSub  map[uuid.UUID]map[uuid.UUID]bool
-	channelSub map[uuid.UUID]map[uuid.UUID]bool
+	cfg   Config
+	state *hubState
 
 	visibilityService interfaces.VisibilityQueries
 	authService       interfaces.AuthService
+	messageService    interfaces.MessageService
 	eventSubscriber   ports.EventSubscriber
 
-	unsubChan chan *client
+	unsubChan chan peer
+	replay    *replayLog
+	limiter   *gatewayLimiter
+	proxies   trustedProxies
 
 	nicknameCache ports.CacheStore
 	userResolver  ports.UserResolver
 
-	m sync.RWMutex
+	closing atomic.Bool
+	pumps   sync.WaitGroup // read and write pumps of websocket clients
 }
 
 var Upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin:     func(r *http.Request) bool { return true },
+	ReadBufferSize:    1024,
+	WriteBufferSize:   1024,
+	EnableCompression: true,
+	CheckOrigin:       func(r *http.Request) bool { return true },
 }
 
-func NewHub(ctx context.Context, authService interfaces.AuthService, visibilityQueries interfaces.VisibilityQueries, eventReader ports.EventSubscriber, cacheStore ports.CacheStore, userResolver ports.UserResolver) (*Hub, error) {
+func NewHub(ctx context.Context, cfg Config, authService interfaces.AuthService, messageService interfaces.MessageService, visibilityQueries interfaces.VisibilityQueries, eventReader ports.EventSubscriber, cacheStore ports.CacheStore, userResolver ports.UserResolver) (*Hub, error) {
+	proxies, err := parseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
 	hub := &Hub{
-		userConn:   make(map[uuid.UUID]map[uuid.UUID]*client),
-		serverSub:  make(map[uuid.UUID]map[uuid.UUID]bool),
-		channelSub: make(map[uuid.UUID]map[uuid.UUID]bool),
+		cfg:   cfg,
+		state: newHubState(),
 
 		visibilityService: visibilityQueries,
 		authService:       authService,
+		messageService:    messageService,
 		eventSubscriber:   eventReader,
 
-		unsubChan: make(chan *client, 1024),
+		unsubChan: make(chan peer, 1024),
+		replay:    newReplayLog(uuid.New(), cfg.ReplayLogSize),
+		limiter:   newGatewayLimiter(cfg.MaxConnsPerUser, cfg.MaxConnsPerIP),
+		proxies:   proxies,
 
 		nicknameCache: cacheStore,
 		userResolver:  userResolver,
@@ -60,50 +75,74 @@ func NewHub(ctx context.Context, authService interfaces.AuthService, visibilityQ
 	return hub, nil
 }
 
-func (h *Hub) Register(ctx context.Context, conn *websocket.Conn) error {
-	c := newClient(h.authService, conn, h.unsubChan)
+func (h *Hub) Register(ctx context.Context, connId uuid.UUID, conn *websocket.Conn, proto protocol) error {
+	c := newClient(h, connId, conn, proto)
 	if c == nil {
 		return fmt.Errorf("Unauth")
 	}
-	userId := c.userId
-
-	chans, err := h.visibilityService.GetVisibleChannels(ctx, userId)
-	if err != nil {
-		c.Close()
-		return err
+	if !h.limiter.acquireUser(c.id, c.userId) {
+		c.closeWithCode(CLOSE_TOO_MANY_CONNECTIONS, "too many connections")
+		return fmt.Errorf("too many connections for user")
 	}
-	servers, err := h.visibilityService.GetVisibleServers(ctx, userId)
-	if err != nil {
-		c.Close()
+
+	if _, err := h.subscribe(ctx, c); err != nil {
 		return err
 	}
 
-	h.m.Lock()
+	c.Write(initializedEvent, initializedPayload{SubscribedFrom: time.Now(), Protocol: proto})
 
-	for _, cId := range chans {
-		if _, ok := h.channelSub[cId]; !ok {
-			h.channelSub[cId] = make(map[uuid.UUID]bool)
-		}
-		h.channelSub[cId][userId] = true
-	}
+	return nil
+}
 
-	for _, sId := range servers {
-		if _, ok := h.serverSub[sId]; !ok {
-			h.serverSub[sId] = make(map[uuid.UUID]bool)
-		}
-		h.serverSub[sId][userId] = true
+// subscribe adds an authenticated peer to every channel and server it can see
+// and returns the visible channels
+func (h *Hub) subscribe(ctx context.Context, p peer) (uuid.UUIDs, error) {
+	chans, err := h.visibilityService.GetVisibleChannels(ctx, p.user())
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	servers, err := h.visibilityService.GetVisibleServers(ctx, p.user())
+	if err != nil {
+		p.Close()
+		return nil, err
 	}
 
-	if _, ok := h.userConn[userId]; !ok {
-		h.userConn[userId] = make(map[uuid.UUID]*client)
+	h.state.addClient(p, chans, servers)
+
+	// The peer may have dropped while it was being registered, its unsub
+	// could have been processed before it was added
+	if p.closed() {
+		h.unsubChan <- p
+	}
+	// Or the hub may have started shutting down, missing it
+	if h.closing.Load() {
+		p.goAway()
 	}
-	h.userConn[userId][c.id] = c
 
-	h.m.Unlock()
+	return chans, nil
+}
 
-	c.Write(initializedEvent, map[string]any{"subscribedFrom": time.Now()})
+// Shutdown closes every connection with a going away code so clients
+// reconnect to another instance, and waits for the websocket pumps to finish
+// the ops they were running or for ctx to be done.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.closing.Store(true)
+	for _, p := range h.state.allClients() {
+		p.goAway()
+	}
 
-	return nil
+	done := make(chan struct{})
+	go func() {
+		h.pumps.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (h *Hub) unsubLoop(ctx context.Context) {
@@ -119,22 +158,34 @@ outer:
 			if c == nil {
 				continue
 			}
-			h.m.Lock()
-			if _, ok = h.userConn[c.userId]; ok {
-				if _, ok = h.userConn[c.userId][c.id]; ok {
-					delete(h.userConn[c.userId], c.id)
-				}
-				if len(h.userConn[c.userId]) == 0 {
-					delete(h.userConn, c.userId)
-					for _, v := range h.serverSub {
-						delete(v, c.userId)
-					}
-					for _, v := range h.channelSub {
-						delete(v, c.userId)
-					}
-				}
-			}
-			h.m.Unlock()
+			h.state.removeClient(c)
+			h.limiter.release(c.connId())
 		}
 	}
 }
+
+// broadcastChannel records a frame in the replay log and fans it out to every
+// peer subscribed to the channel, without holding any hub lock while writing
+func (h *Hub) broadcastChannel(channelId uuid.UUID, eventType string, payload any) {
+	evt := h.replay.append(channelId, eventType, payload)
+
+	clients := h.state.topicClients(h.state.channels, channelId)
+	if len(clients) == 0 {
+		slog.Default().Info("no listener on channel", "channel_id", channelId.String())
+		return
+	}
+
+	for _, c := range clients {
+		c.deliver(evt)
+	}
+}
+
+// Stats returns the frame counters since the process started and the current
+// connection and subscription sizes
+func (h *Hub) Stats() Stats {
+	res := stats.snapshot()
+	res.Users, res.Connections = h.state.connCount()
+	res.Channels, res.ChannelSubscriptions = h.state.channels.count()
+	res.Servers, res.ServerSubscriptions = h.state.servers.count()
+	return res
+}
@@ -0,0 +1,217 @@
+package ws
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// HUB_SHARD_COUNT must be a power of two
+const HUB_SHARD_COUNT = 64
+
+func shardOf(id uuid.UUID) uint32 {
+	h := fnv.New32a()
+	h.Write(id[:])
+	return h.Sum32() & (HUB_SHARD_COUNT - 1)
+}
+
+// subShard maps a topic (channel or server id) to the users subscribed to it
+type subShard struct {
+	mu   sync.RWMutex
+	subs map[uuid.UUID]map[uuid.UUID]struct{}
+}
+
+type subIndex [HUB_SHARD_COUNT]subShard
+
+func newSubIndex() *subIndex {
+	idx := &subIndex{}
+	for i := range idx {
+		idx[i].subs = make(map[uuid.UUID]map[uuid.UUID]struct{})
+	}
+	return idx
+}
+
+func (idx *subIndex) add(topic, userId uuid.UUID) {
+	shard := &idx[shardOf(topic)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if _, ok := shard.subs[topic]; !ok {
+		shard.subs[topic] = make(map[uuid.UUID]struct{})
+	}
+	shard.subs[topic][userId] = struct{}{}
+}
+
+func (idx *subIndex) remove(topic, userId uuid.UUID) {
+	shard := &idx[shardOf(topic)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	delete(shard.subs[topic], userId)
+	if len(shard.subs[topic]) == 0 {
+		delete(shard.subs, topic)
+	}
+}
+
+func (idx *subIndex) users(topic uuid.UUID) []uuid.UUID {
+	shard := &idx[shardOf(topic)]
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	users := make([]uuid.UUID, 0, len(shard.subs[topic]))
+	for uId := range shard.subs[topic] {
+		users = append(users, uId)
+	}
+	return users
+}
+
+func (idx *subIndex) count() (topics, subs int) {
+	for i := range idx {
+		idx[i].mu.RLock()
+		topics += len(idx[i].subs)
+		for _, users := range idx[i].subs {
+			subs += len(users)
+		}
+		idx[i].mu.RUnlock()
+	}
+	return topics, subs
+}
+
+// userEntry holds a user's live connections and the reverse index of what the
+// user is subscribed to, so dropping a user only touches its own topics
+type userEntry struct {
+	conns    map[uuid.UUID]peer
+	channels map[uuid.UUID]struct{}
+	servers  map[uuid.UUID]struct{}
+}
+
+type userShard struct {
+	mu    sync.RWMutex
+	users map[uuid.UUID]*userEntry
+}
+
+// hubState is the hub's subscription state, split into shards so fanout for
+// one channel never contends with connects and disconnects elsewhere.
+// Lock order is always a user shard before any topic shard.
+type hubState struct {
+	users    [HUB_SHARD_COUNT]userShard
+	channels *subIndex
+	servers  *subIndex
+}
+
+func newHubState() *hubState {
+	s := &hubState{
+		channels: newSubIndex(),
+		servers:  newSubIndex(),
+	}
+	for i := range s.users {
+		s.users[i].users = make(map[uuid.UUID]*userEntry)
+	}
+	return s
+}
+
+func (s *hubState) addClient(c peer, channels, servers []uuid.UUID) {
+	userId := c.user()
+	shard := &s.users[shardOf(userId)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry, ok := shard.users[userId]
+	if !ok {
+		entry = &userEntry{
+			conns:    make(map[uuid.UUID]peer),
+			channels: make(map[uuid.UUID]struct{}),
+			servers:  make(map[uuid.UUID]struct{}),
+		}
+		shard.users[userId] = entry
+	}
+	entry.conns[c.connId()] = c
+
+	for _, cId := range channels {
+		if _, ok := entry.channels[cId]; !ok {
+			entry.channels[cId] = struct{}{}
+			s.channels.add(cId, userId)
+		}
+	}
+	for _, sId := range servers {
+		if _, ok := entry.servers[sId]; !ok {
+			entry.servers[sId] = struct{}{}
+			s.servers.add(sId, userId)
+		}
+	}
+}
+
+func (s *hubState) removeClient(c peer) {
+	userId := c.user()
+	shard := &s.users[shardOf(userId)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry, ok := shard.users[userId]
+	if !ok {
+		return
+	}
+	delete(entry.conns, c.connId())
+	if len(entry.conns) > 0 {
+		return
+	}
+
+	delete(shard.users, userId)
+	for cId := range entry.channels {
+		s.channels.remove(cId, userId)
+	}
+	for sId := range entry.servers {
+		s.servers.remove(sId, userId)
+	}
+}
+
+func (s *hubState) userClients(userId uuid.UUID) []peer {
+	shard := &s.users[shardOf(userId)]
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	entry, ok := shard.users[userId]
+	if !ok {
+		return nil
+	}
+	clients := make([]peer, 0, len(entry.conns))
+	for _, c := range entry.conns {
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+func (s *hubState) topicClients(idx *subIndex, topic uuid.UUID) []peer {
+	var clients []peer
+	for _, uId := range idx.users(topic) {
+		clients = append(clients, s.userClients(uId)...)
+	}
+	return clients
+}
+
+func (s *hubState) allClients() []peer {
+	var clients []peer
+	for i := range s.users {
+		s.users[i].mu.RLock()
+		for _, entry := range s.users[i].users {
+			for _, c := range entry.conns {
+				clients = append(clients, c)
+			}
+		}
+		s.users[i].mu.RUnlock()
+	}
+	return clients
+}
+
+func (s *hubState) connCount() (users, conns int) {
+	for i := range s.users {
+		s.users[i].mu.RLock()
+		users += len(s.users[i].users)
+		for _, entry := range s.users[i].users {
+			conns += len(entry.conns)
+		}
+		s.users[i].mu.RUnlock()
+	}
+	return users, conns
+}
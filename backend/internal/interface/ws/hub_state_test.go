@@ -0,0 +1,187 @@
+package ws
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+type fakePeer struct {
+	id, userId uuid.UUID
+	delivered  atomic.Int64
+}
+
+func newFakePeer(userId uuid.UUID) *fakePeer {
+	return &fakePeer{id: uuid.New(), userId: userId}
+}
+
+func (p *fakePeer) connId() uuid.UUID               { return p.id }
+func (p *fakePeer) user() uuid.UUID                 { return p.userId }
+func (p *fakePeer) closed() bool                    { return false }
+func (p *fakePeer) deliver(evt hubEvent)            { p.delivered.Add(1) }
+func (p *fakePeer) Write(eventType string, msg any) {}
+func (p *fakePeer) Close() error                    { return nil }
+func (p *fakePeer) goAway()                         {}
+
+// populate subscribes users with connsPerUser connections each to the channel,
+// plus a few other channels so the shards are not empty around it
+func populate(s *hubState, channelId uuid.UUID, users, connsPerUser int) []*fakePeer {
+	peers := make([]*fakePeer, 0, users*connsPerUser)
+	for range users {
+		userId := uuid.New()
+		channels := []uuid.UUID{channelId, uuid.New(), uuid.New()}
+		for range connsPerUser {
+			p := newFakePeer(userId)
+			s.addClient(p, channels, nil)
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
+func TestHubStateAddRemove(t *testing.T) {
+	channelId := uuid.New()
+	userId := uuid.New()
+	first, second := newFakePeer(userId), newFakePeer(userId)
+
+	tests := []struct {
+		name          string
+		run           func(s *hubState)
+		wantTopic     int
+		wantUsers     int
+		wantConns     int
+		wantSubscribe int
+	}{
+		{
+			name:          "one connection",
+			run:           func(s *hubState) { s.addClient(first, []uuid.UUID{channelId}, nil) },
+			wantTopic:     1,
+			wantUsers:     1,
+			wantConns:     1,
+			wantSubscribe: 1,
+		},
+		{
+			name: "two connections of one user count the user once",
+			run: func(s *hubState) {
+				s.addClient(first, []uuid.UUID{channelId}, nil)
+				s.addClient(second, []uuid.UUID{channelId}, nil)
+			},
+			wantTopic:     2,
+			wantUsers:     1,
+			wantConns:     2,
+			wantSubscribe: 1,
+		},
+		{
+			name: "user stays subscribed while a connection is left",
+			run: func(s *hubState) {
+				s.addClient(first, []uuid.UUID{channelId}, nil)
+				s.addClient(second, []uuid.UUID{channelId}, nil)
+				s.removeClient(first)
+			},
+			wantTopic:     1,
+			wantUsers:     1,
+			wantConns:     1,
+			wantSubscribe: 1,
+		},
+		{
+			name: "last connection unsubscribes the user",
+			run: func(s *hubState) {
+				s.addClient(first, []uuid.UUID{channelId}, nil)
+				s.removeClient(first)
+			},
+		},
+		{
+			name: "removing an unknown connection is a no-op",
+			run:  func(s *hubState) { s.removeClient(first) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newHubState()
+			tt.run(s)
+
+			if got := len(s.topicClients(s.channels, channelId)); got != tt.wantTopic {
+				t.Errorf("topicClients = %d, want %d", got, tt.wantTopic)
+			}
+			users, conns := s.connCount()
+			if users != tt.wantUsers || conns != tt.wantConns {
+				t.Errorf("connCount = %d users %d conns, want %d %d", users, conns, tt.wantUsers, tt.wantConns)
+			}
+			if _, subs := s.channels.count(); subs != tt.wantSubscribe {
+				t.Errorf("channels.count subs = %d, want %d", subs, tt.wantSubscribe)
+			}
+		})
+	}
+}
+
+var fanoutSizes = []struct{ users, connsPerUser int }{
+	{100, 1},
+	{1_000, 1},
+	{1_000, 4},
+	{10_000, 1},
+	{10_000, 4},
+}
+
+func BenchmarkTopicClients(b *testing.B) {
+	for _, size := range fanoutSizes {
+		b.Run(fmt.Sprintf("users=%d/conns=%d", size.users, size.connsPerUser), func(b *testing.B) {
+			s := newHubState()
+			channelId := uuid.New()
+			populate(s, channelId, size.users, size.connsPerUser)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for b.Loop() {
+				s.topicClients(s.channels, channelId)
+			}
+		})
+	}
+}
+
+func BenchmarkBroadcastChannel(b *testing.B) {
+	for _, size := range fanoutSizes {
+		b.Run(fmt.Sprintf("users=%d/conns=%d", size.users, size.connsPerUser), func(b *testing.B) {
+			h := &Hub{state: newHubState(), replay: newReplayLog(uuid.New(), DefaultConfig.ReplayLogSize)}
+			channelId := uuid.New()
+			peers := populate(h.state, channelId, size.users, size.connsPerUser)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for b.Loop() {
+				h.broadcastChannel(channelId, incomingMessageEvent, nil)
+			}
+			b.StopTimer()
+
+			b.ReportMetric(float64(len(peers)), "deliveries/op")
+		})
+	}
+}
+
+// BenchmarkChurnDuringFanout connects and disconnects users on other channels
+// while fanning out, the shards should keep one from stalling the other
+func BenchmarkChurnDuringFanout(b *testing.B) {
+	h := &Hub{state: newHubState(), replay: newReplayLog(uuid.New(), DefaultConfig.ReplayLogSize)}
+	channelId := uuid.New()
+	populate(h.state, channelId, 1_000, 2)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	var n atomic.Int64
+	b.RunParallel(func(pb *testing.PB) {
+		// Every other goroutine churns, the rest fan out
+		churn := n.Add(1)%2 == 0
+		other := []uuid.UUID{uuid.New()}
+		for pb.Next() {
+			if churn {
+				p := newFakePeer(uuid.New())
+				h.state.addClient(p, other, nil)
+				h.state.removeClient(p)
+			} else {
+				h.broadcastChannel(channelId, incomingMessageEvent, nil)
+			}
+		}
+	})
+}
@@ -0,0 +1,232 @@
+package ws
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"golang.org/x/time/rate"
+)
+
+const (
+	UNKNOWN_OP = "" // bucket for frames that aren't a known op
+)
+
+type opLimit struct {
+	rate  rate.Limit
+	burst int
+}
+
+// Token buckets per op type. A connection gets its own buckets and shares a
+// second set with every other connection of the same user, both must allow
+// the op.
+var (
+	connOpLimits = map[string]opLimit{
+		UNKNOWN_OP:         {rate.Limit(1), 5},
+		AUTH_MESSAGE:       {rate.Limit(1), 3},
+		MESSAGE_CREATE_OP:  {rate.Limit(5), 10},
+		MESSAGE_EDIT_OP:    {rate.Limit(2), 5},
+		MESSAGE_DELETE_OP:  {rate.Limit(2), 5},
+		REACTION_ADD_OP:    {rate.Limit(5), 10},
+		REACTION_REMOVE_OP: {rate.Limit(5), 10},
+	}
+	userOpLimits = map[string]opLimit{
+		MESSAGE_CREATE_OP:  {rate.Limit(10), 20},
+		MESSAGE_EDIT_OP:    {rate.Limit(4), 10},
+		MESSAGE_DELETE_OP:  {rate.Limit(4), 10},
+		REACTION_ADD_OP:    {rate.Limit(10), 20},
+		REACTION_REMOVE_OP: {rate.Limit(10), 20},
+	}
+)
+
+type opLimiter map[string]*rate.Limiter
+
+func newOpLimiter(limits map[string]opLimit) opLimiter {
+	l := make(opLimiter, len(limits))
+	for op, limit := range limits {
+		l[op] = rate.NewLimiter(limit.rate, limit.burst)
+	}
+	return l
+}
+
+func (l opLimiter) allow(op string) bool {
+	limiter, ok := l[op]
+	if !ok {
+		return true
+	}
+	return limiter.Allow()
+}
+
+type connSlot struct {
+	ip     string
+	userId uuid.UUID
+}
+
+// gatewayLimiter caps concurrent connections per ip and per user and holds
+// the op buckets shared by a user's connections for as long as it has one
+type gatewayLimiter struct {
+	maxConnsPerUser int
+	maxConnsPerIP   int
+
+	mu        sync.Mutex
+	conns     map[uuid.UUID]*connSlot
+	ipConns   map[string]int
+	userConns map[uuid.UUID]int
+	userOps   map[uuid.UUID]opLimiter
+}
+
+func newGatewayLimiter(maxConnsPerUser, maxConnsPerIP int) *gatewayLimiter {
+	return &gatewayLimiter{
+		maxConnsPerUser: maxConnsPerUser,
+		maxConnsPerIP:   maxConnsPerIP,
+
+		conns:     make(map[uuid.UUID]*connSlot),
+		ipConns:   make(map[string]int),
+		userConns: make(map[uuid.UUID]int),
+		userOps:   make(map[uuid.UUID]opLimiter),
+	}
+}
+
+func (l *gatewayLimiter) acquireIP(connId uuid.UUID, ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.ipConns[ip] >= l.maxConnsPerIP {
+		return false
+	}
+	l.ipConns[ip]++
+	l.conns[connId] = &connSlot{ip: ip}
+	return true
+}
+
+func (l *gatewayLimiter) acquireUser(connId, userId uuid.UUID) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	slot, ok := l.conns[connId]
+	if !ok || l.userConns[userId] >= l.maxConnsPerUser {
+		return false
+	}
+	slot.userId = userId
+	if l.userConns[userId] == 0 {
+		l.userOps[userId] = newOpLimiter(userOpLimits)
+	}
+	l.userConns[userId]++
+	return true
+}
+
+func (l *gatewayLimiter) allowUser(userId uuid.UUID, op string) bool {
+	l.mu.Lock()
+	ops, ok := l.userOps[userId]
+	l.mu.Unlock()
+	if !ok {
+		return true
+	}
+	return ops.allow(op)
+}
+
+// release frees whatever the connection holds, safe to call more than once
+func (l *gatewayLimiter) release(connId uuid.UUID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	slot, ok := l.conns[connId]
+	if !ok {
+		return
+	}
+	delete(l.conns, connId)
+
+	if l.ipConns[slot.ip]--; l.ipConns[slot.ip] <= 0 {
+		delete(l.ipConns, slot.ip)
+	}
+	if slot.userId != uuid.Nil {
+		if l.userConns[slot.userId]--; l.userConns[slot.userId] <= 0 {
+			delete(l.userConns, slot.userId)
+			delete(l.userOps, slot.userId)
+		}
+	}
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// trustedProxies are the proxies whose X-Forwarded-For and X-Real-IP headers
+// are believed. Anyone else could rotate them to get around the per ip cap.
+type trustedProxies []netip.Prefix
+
+// parseTrustedProxies takes CIDRs or single addresses
+func parseTrustedProxies(entries []string) (trustedProxies, error) {
+	proxies := make(trustedProxies, 0, len(entries))
+	for _, entry := range entries {
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q, expected a CIDR or an address", entry)
+		}
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return proxies, nil
+}
+
+func (t trustedProxies) contains(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range t {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP is the peer address, or when the peer is a trusted proxy the
+// last address in X-Forwarded-For it was not told by another trusted proxy
+func (t trustedProxies) clientIP(r *http.Request) string {
+	ip := remoteIP(r)
+	if !t.contains(ip) {
+		return ip
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			return ip
+		}
+		ip = hop
+		if !t.contains(hop) {
+			return ip
+		}
+	}
+	if len(hops) == 0 {
+		if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+			if _, err := netip.ParseAddr(realIP); err == nil {
+				return realIP
+			}
+		}
+	}
+	return ip
+}
+
+// redactToken keeps tokens out of the logs
+func redactToken(token string) string {
+	return fmt.Sprintf("[redacted %d bytes]", len(token))
+}
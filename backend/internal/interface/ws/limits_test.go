@@ -0,0 +1,294 @@
+package ws
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"golang.org/x/time/rate"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		wantErr bool
+	}{
+		{name: "none", entries: nil},
+		{name: "cidrs and addresses", entries: []string{"10.0.0.0/8", "192.168.1.1", "fd00::/8", "::1"}},
+		{name: "host bits are masked", entries: []string{"10.1.2.3/8"}},
+		{name: "hostname", entries: []string{"proxy.internal"}, wantErr: true},
+		{name: "empty entry", entries: []string{""}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTrustedProxies(tt.entries)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTrustedProxies(%q) err = %v, want error %t", tt.entries, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := parseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string][]string
+		want       string
+	}{
+		{
+			name:       "direct client",
+			remoteAddr: "203.0.113.7:5555",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "untrusted peer cannot spoof forwarded for",
+			remoteAddr: "203.0.113.7:5555",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "untrusted peer cannot spoof real ip",
+			remoteAddr: "203.0.113.7:5555",
+			headers:    map[string][]string{"X-Real-Ip": {"198.51.100.1"}},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "trusted proxy",
+			remoteAddr: "10.0.0.2:5555",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "client prepended hops are ignored",
+			remoteAddr: "10.0.0.2:5555",
+			headers:    map[string][]string{"X-Forwarded-For": {"1.2.3.4, 198.51.100.1"}},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "chain of trusted proxies",
+			remoteAddr: "10.0.0.2:5555",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.1, 192.168.1.1", "10.3.3.3"}},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "only trusted hops",
+			remoteAddr: "10.0.0.2:5555",
+			headers:    map[string][]string{"X-Forwarded-For": {"10.1.1.1, 10.2.2.2"}},
+			want:       "10.1.1.1",
+		},
+		{
+			name:       "garbage hop stops the walk",
+			remoteAddr: "10.0.0.2:5555",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.1, not-an-ip"}},
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "real ip from a trusted proxy",
+			remoteAddr: "192.168.1.1:5555",
+			headers:    map[string][]string{"X-Real-Ip": {"198.51.100.1"}},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "trusted proxy without headers",
+			remoteAddr: "10.0.0.2:5555",
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "ipv4 mapped peer",
+			remoteAddr: "[::ffff:10.0.0.2]:5555",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			want:       "198.51.100.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/ws", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for k, values := range tt.headers {
+				for _, v := range values {
+					r.Header.Add(k, v)
+				}
+			}
+
+			if got := proxies.clientIP(r); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpLimiter(t *testing.T) {
+	limits := map[string]opLimit{
+		MESSAGE_CREATE_OP: {rate.Limit(0), 3},
+		MESSAGE_EDIT_OP:   {rate.Limit(0), 1},
+	}
+
+	tests := []struct {
+		name    string
+		op      string
+		calls   int
+		allowed int
+	}{
+		{name: "burst then denied", op: MESSAGE_CREATE_OP, calls: 5, allowed: 3},
+		{name: "buckets are per op", op: MESSAGE_EDIT_OP, calls: 3, allowed: 1},
+		{name: "op without a bucket is not limited", op: REACTION_ADD_OP, calls: 100, allowed: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newOpLimiter(limits)
+			allowed := 0
+			for range tt.calls {
+				if l.allow(tt.op) {
+					allowed++
+				}
+			}
+			if allowed != tt.allowed {
+				t.Errorf("allowed %d of %d, want %d", allowed, tt.calls, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestOpLimitsCoverEveryOp(t *testing.T) {
+	for _, op := range []string{AUTH_MESSAGE, MESSAGE_CREATE_OP, MESSAGE_EDIT_OP, MESSAGE_DELETE_OP, REACTION_ADD_OP, REACTION_REMOVE_OP, UNKNOWN_OP} {
+		if _, ok := connOpLimits[op]; !ok {
+			t.Errorf("no connection limit for op %q", op)
+		}
+	}
+}
+
+func TestGatewayLimiter(t *testing.T) {
+	const ip = "203.0.113.7"
+	alice, bob := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T, l *gatewayLimiter)
+	}{
+		{
+			name: "ip cap",
+			run: func(t *testing.T, l *gatewayLimiter) {
+				for i := range 3 {
+					if !l.acquireIP(uuid.New(), ip) {
+						t.Fatalf("connection %d refused under the cap", i)
+					}
+				}
+				if l.acquireIP(uuid.New(), ip) {
+					t.Fatal("connection over the ip cap accepted")
+				}
+				if !l.acquireIP(uuid.New(), "198.51.100.1") {
+					t.Fatal("other ip refused")
+				}
+			},
+		},
+		{
+			name: "user cap",
+			run: func(t *testing.T, l *gatewayLimiter) {
+				for i := range 2 {
+					connId := uuid.New()
+					if !l.acquireIP(connId, ip) || !l.acquireUser(connId, alice) {
+						t.Fatalf("connection %d refused under the cap", i)
+					}
+				}
+				connId := uuid.New()
+				if !l.acquireIP(connId, ip) {
+					t.Fatal("ip refused under the cap")
+				}
+				if l.acquireUser(connId, alice) {
+					t.Fatal("connection over the user cap accepted")
+				}
+				if !l.acquireUser(connId, bob) {
+					t.Fatal("other user refused")
+				}
+			},
+		},
+		{
+			name: "user without an ip slot",
+			run: func(t *testing.T, l *gatewayLimiter) {
+				if l.acquireUser(uuid.New(), alice) {
+					t.Fatal("user acquired without an ip slot")
+				}
+			},
+		},
+		{
+			name: "release frees both caps once",
+			run: func(t *testing.T, l *gatewayLimiter) {
+				conns := make([]uuid.UUID, 2)
+				for i := range conns {
+					conns[i] = uuid.New()
+					l.acquireIP(conns[i], ip)
+					l.acquireUser(conns[i], alice)
+				}
+				l.release(conns[0])
+				l.release(conns[0])
+
+				connId := uuid.New()
+				if !l.acquireIP(connId, ip) || !l.acquireUser(connId, alice) {
+					t.Fatal("released slot not reusable")
+				}
+				extra := uuid.New()
+				if !l.acquireIP(extra, ip) {
+					t.Fatal("ip refused under the cap")
+				}
+				if l.acquireUser(extra, alice) {
+					t.Fatal("double release freed a second slot")
+				}
+			},
+		},
+		{
+			name: "user buckets are shared and dropped with the last connection",
+			run: func(t *testing.T, l *gatewayLimiter) {
+				first, second := uuid.New(), uuid.New()
+				for _, connId := range []uuid.UUID{first, second} {
+					l.acquireIP(connId, ip)
+					l.acquireUser(connId, alice)
+				}
+
+				burst := userOpLimits[MESSAGE_CREATE_OP].burst
+				for range burst {
+					l.allowUser(alice, MESSAGE_CREATE_OP)
+				}
+				if l.allowUser(alice, MESSAGE_CREATE_OP) {
+					t.Fatal("user bucket not exhausted after its burst")
+				}
+
+				l.release(first)
+				if l.allowUser(alice, MESSAGE_CREATE_OP) {
+					t.Fatal("user bucket reset while a connection is left")
+				}
+
+				l.release(second)
+				connId := uuid.New()
+				l.acquireIP(connId, ip)
+				l.acquireUser(connId, alice)
+				if !l.allowUser(alice, MESSAGE_CREATE_OP) {
+					t.Fatal("user bucket kept after the last connection left")
+				}
+			},
+		},
+		{
+			name: "unknown user is not limited",
+			run: func(t *testing.T, l *gatewayLimiter) {
+				if !l.allowUser(bob, MESSAGE_CREATE_OP) {
+					t.Fatal("user without a connection limited")
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newGatewayLimiter(2, 3))
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package ws
+
+import "github.com/google/uuid"
+
+// peer is one live connection subscribed through the hub, over either
+// websocket or server-sent events
+type peer interface {
+	connId() uuid.UUID
+	user() uuid.UUID
+	closed() bool
+	deliver(evt hubEvent)
+	Write(eventType string, msg any)
+	Close() error
+	goAway() // closes the connection so the client reconnects elsewhere
+}
+
+// hubEvent is a fanout frame. ID and Seq are only set for frames kept in the
+// replay log and let SSE clients resume with Last-Event-ID.
+type hubEvent struct {
+	ID        string
+	Seq       uint64
+	EventType string
+	Payload   any
+}
+
+// newWsPayload builds the envelope every transport sends, so ws and sse
+// clients on the same version always get identical frames
+func newWsPayload(version int32, eventType, nonce string, payload any) wsPayload {
+	return wsPayload{
+		EventType: eventType,
+		Nonce:     nonce,
+		Payload:   adaptPayload(version, eventType, payload),
+		Version:   version,
+	}
+}
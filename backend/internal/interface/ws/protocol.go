@@ -0,0 +1,174 @@
+package ws
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Protocol versions the gateway can speak. Clients ask for one with the `v`
+// query param, clients that don't ask get WS_MIN_VERSION so they keep the
+// payload shapes they were built against.
+const (
+	WS_MIN_VERSION = 1
+	WS_MAX_VERSION = WS_VERSION
+
+	ENCODING_JSON    = "json"
+	ENCODING_MSGPACK = "msgpack"
+
+	COMPRESS_NONE        = ""
+	COMPRESS_PERMESSAGE  = "permessage-deflate"
+	COMPRESS_ZLIB_STREAM = "zlib-stream"
+)
+
+type protocol struct {
+	Version  int32  `json:"version"`
+	Encoding string `json:"encoding"`
+	Compress string `json:"compress,omitempty"`
+}
+
+// negotiateProtocol reads `v`, `encoding` and `compress` from the connect url,
+// e.g. /ws?v=2&encoding=msgpack&compress=zlib-stream
+func negotiateProtocol(r *http.Request) (protocol, error) {
+	q := r.URL.Query()
+	p := protocol{
+		Version:  WS_MIN_VERSION,
+		Encoding: ENCODING_JSON,
+		Compress: COMPRESS_NONE,
+	}
+
+	if v := q.Get("v"); v != "" {
+		version, err := strconv.Atoi(v)
+		if err != nil || version < WS_MIN_VERSION || version > WS_MAX_VERSION {
+			return p, fmt.Errorf("unsupported gateway version %q, supported %d to %d", v, WS_MIN_VERSION, WS_MAX_VERSION)
+		}
+		p.Version = int32(version)
+	}
+
+	switch encoding := q.Get("encoding"); encoding {
+	case "", ENCODING_JSON:
+	case ENCODING_MSGPACK:
+		p.Encoding = ENCODING_MSGPACK
+	default:
+		return p, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+
+	switch compress := q.Get("compress"); compress {
+	case COMPRESS_NONE:
+	case COMPRESS_PERMESSAGE, COMPRESS_ZLIB_STREAM:
+		p.Compress = compress
+	default:
+		return p, fmt.Errorf("unsupported compression %q", compress)
+	}
+
+	return p, nil
+}
+
+type codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+	MessageType() int
+}
+
+func newCodec(encoding string) codec {
+	if encoding == ENCODING_MSGPACK {
+		return msgpackCodec{}
+	}
+	return jsonCodec{}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+func (jsonCodec) MessageType() int                   { return websocket.TextMessage }
+
+// msgpackCodec goes through the json representation of a value so that both
+// encodings carry exactly the same shapes (ids as strings, camelCase keys,
+// RFC3339 times) and the same structs serve both
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var generic any
+	if err = dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	return msgpack.Marshal(fromJsonNumbers(generic))
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	var generic any
+	if err := msgpack.Unmarshal(data, &generic); err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(generic)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func (msgpackCodec) MessageType() int { return websocket.BinaryMessage }
+
+func fromJsonNumbers(v any) any {
+	switch val := v.(type) {
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i
+		}
+		f, _ := val.Float64()
+		return f
+	case map[string]any:
+		for k, item := range val {
+			val[k] = fromJsonNumbers(item)
+		}
+		return val
+	case []any:
+		for i, item := range val {
+			val[i] = fromJsonNumbers(item)
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+// zlibStream is a single zlib context shared by every frame of a connection.
+// Each frame is sync flushed, so it ends with 00 00 ff ff and the client can
+// inflate frames as they arrive. Only the write pump touches it.
+type zlibStream struct {
+	buf bytes.Buffer
+	w   *zlib.Writer
+}
+
+func newZlibStream() *zlibStream {
+	s := &zlibStream{}
+	s.w = zlib.NewWriter(&s.buf)
+	return s
+}
+
+func (s *zlibStream) compress(data []byte) ([]byte, error) {
+	s.buf.Reset()
+	if _, err := s.w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := s.w.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(s.buf.Bytes()), nil
+}
@@ -0,0 +1,193 @@
+package ws
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestNegotiateProtocol(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    protocol
+		wantErr string
+	}{
+		{name: "defaults", query: "", want: protocol{Version: WS_MIN_VERSION, Encoding: ENCODING_JSON}},
+		{name: "latest version", query: "v=2", want: protocol{Version: 2, Encoding: ENCODING_JSON}},
+		{name: "msgpack and zlib stream", query: "v=1&encoding=msgpack&compress=zlib-stream", want: protocol{Version: 1, Encoding: ENCODING_MSGPACK, Compress: COMPRESS_ZLIB_STREAM}},
+		{name: "explicit json and permessage deflate", query: "encoding=json&compress=permessage-deflate", want: protocol{Version: WS_MIN_VERSION, Encoding: ENCODING_JSON, Compress: COMPRESS_PERMESSAGE}},
+		{name: "version too old", query: "v=0", wantErr: `unsupported gateway version "0"`},
+		{name: "version too new", query: "v=3", wantErr: `unsupported gateway version "3"`},
+		{name: "version not a number", query: "v=latest", wantErr: `unsupported gateway version "latest"`},
+		{name: "unknown encoding", query: "encoding=etf", wantErr: `unsupported encoding "etf"`},
+		{name: "unknown compression", query: "compress=gzip", wantErr: `unsupported compression "gzip"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := negotiateProtocol(httptest.NewRequest("GET", "/ws?"+tt.query, nil))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("protocol = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+type codecFrame struct {
+	Id       string         `json:"id"`
+	Seq      int64          `json:"seq"`
+	Ratio    float64        `json:"ratio"`
+	SentAt   time.Time      `json:"sentAt"`
+	Tags     []string       `json:"tags"`
+	Nested   map[string]int `json:"nested"`
+	Optional *string        `json:"optional"`
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	want := codecFrame{
+		Id:     "0b9c4bd4-8f1e-4c55-9f5e-3c1f4b8a2d11",
+		Seq:    9007199254740993, // past float64 precision
+		Ratio:  0.25,
+		SentAt: time.Date(2026, 10, 19, 12, 30, 0, 0, time.UTC),
+		Tags:   []string{"a", "b"},
+		Nested: map[string]int{"count": 3},
+	}
+
+	tests := []struct {
+		name     string
+		encoding string
+		msgType  int
+	}{
+		{name: "json", encoding: ENCODING_JSON, msgType: websocket.TextMessage},
+		{name: "msgpack", encoding: ENCODING_MSGPACK, msgType: websocket.BinaryMessage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCodec(tt.encoding)
+			if c.MessageType() != tt.msgType {
+				t.Errorf("message type = %d, want %d", c.MessageType(), tt.msgType)
+			}
+			data, err := c.Marshal(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got codecFrame
+			if err = c.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("round trip = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestMsgpackCodecKeepsJsonShapes(t *testing.T) {
+	data, err := msgpackCodec{}.Marshal(codecFrame{Seq: 7, SentAt: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err = msgpack.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	_, isFloat := got["seq"].(float64)
+	if got["sentAt"] != "2026-10-19T00:00:00Z" || fmt.Sprint(got["seq"]) != "7" || isFloat || got["optional"] != nil {
+		t.Errorf("msgpack frame = %#v, want camelCase keys, RFC3339 times and integers", got)
+	}
+}
+
+func TestZlibStream(t *testing.T) {
+	frames := []string{`{"op":"hello"}`, `{"op":"event","d":"` + strings.Repeat("x", 4096) + `"}`, `{"op":"hello"}`}
+
+	s := newZlibStream()
+	var stream bytes.Buffer
+	for _, frame := range frames {
+		data, err := s.compress([]byte(frame))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasSuffix(data, []byte{0, 0, 0xff, 0xff}) {
+			t.Fatalf("frame %q does not end with a sync flush: % x", frame, data[max(0, len(data)-4):])
+		}
+		stream.Write(data)
+	}
+
+	// The client inflates every frame with the same context
+	r, err := zlib.NewReader(&stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, frame := range frames {
+		got := make([]byte, len(frame))
+		if _, err := io.ReadFull(r, got); err != nil {
+			t.Fatalf("inflate %q: %v", frame, err)
+		}
+		if string(got) != frame {
+			t.Errorf("inflated %q, want %q", got, frame)
+		}
+	}
+}
+
+func TestAdaptPayload(t *testing.T) {
+	subscribedFrom := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	initialized := initializedPayload{
+		SubscribedFrom: subscribedFrom,
+		Protocol:       protocol{Version: 2, Encoding: ENCODING_JSON},
+	}
+
+	tests := []struct {
+		name      string
+		version   int32
+		eventType string
+		payload   any
+		want      any
+	}{
+		{name: "initialized at v1 drops the protocol", version: 1, eventType: initializedEvent, payload: initialized, want: map[string]any{"subscribedFrom": subscribedFrom}},
+		{name: "initialized at the latest version", version: WS_MAX_VERSION, eventType: initializedEvent, payload: initialized, want: initialized},
+		{name: "initialized of an unexpected shape", version: 1, eventType: initializedEvent, payload: "raw", want: "raw"},
+		{name: "event without downgrade", version: 1, eventType: "MESSAGE_CREATE", payload: map[string]any{"id": "1"}, want: map[string]any{"id": "1"}},
+	}
+
+	adapted := map[string]bool{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := adaptPayload(tt.version, tt.eventType, tt.payload)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("adaptPayload(%d, %s) = %#v, want %#v", tt.version, tt.eventType, got, tt.want)
+			}
+		})
+		if tt.version < WS_MAX_VERSION && !reflect.DeepEqual(tt.payload, tt.want) {
+			adapted[tt.eventType] = true
+		}
+	}
+
+	// Every downgrade needs a case above
+	for v, byEvent := range downgrades {
+		for eventType := range byEvent {
+			if !adapted[eventType] {
+				t.Errorf("no test of the v%d downgrade of %s", v, eventType)
+			}
+		}
+	}
+}
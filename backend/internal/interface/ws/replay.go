@@ -0,0 +1,89 @@
+package ws
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+type replayEntry struct {
+	channelId uuid.UUID
+	evt       hubEvent
+}
+
+// replayLog keeps the last broadcast frames of this hub so that a client that
+// reconnects with Last-Event-ID can catch up. Ids are "<instance>.<seq>", a
+// client coming back to another instance (or one that restarted) can't resume
+// and has to resync through REST.
+type replayLog struct {
+	mu       sync.Mutex
+	instance uuid.UUID
+	entries  []replayEntry
+	next     uint64
+}
+
+func newReplayLog(instance uuid.UUID, size int) *replayLog {
+	return &replayLog{
+		instance: instance,
+		entries:  make([]replayEntry, size),
+		next:     1,
+	}
+}
+
+func (l *replayLog) append(channelId uuid.UUID, eventType string, payload any) hubEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	seq := l.next
+	l.next++
+	evt := hubEvent{
+		ID:        fmt.Sprintf("%s.%d", l.instance, seq),
+		Seq:       seq,
+		EventType: eventType,
+		Payload:   payload,
+	}
+	l.entries[seq%uint64(len(l.entries))] = replayEntry{channelId, evt}
+	return evt
+}
+
+// since returns the frames after lastId on the given channels, false if the
+// log can't tell what was missed
+func (l *replayLog) since(lastId string, channels uuid.UUIDs) ([]hubEvent, bool) {
+	instance, rawSeq, found := strings.Cut(lastId, ".")
+	if !found || instance != l.instance.String() {
+		return nil, false
+	}
+	seq, err := strconv.ParseUint(rawSeq, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+
+	visible := make(map[uuid.UUID]struct{}, len(channels))
+	for _, cId := range channels {
+		visible[cId] = struct{}{}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	size := uint64(len(l.entries))
+	oldest := uint64(1)
+	if l.next > size {
+		oldest = l.next - size
+	}
+	if seq >= l.next || seq+1 < oldest {
+		return nil, false
+	}
+
+	var res []hubEvent
+	for s := seq + 1; s < l.next; s++ {
+		entry := l.entries[s%size]
+		if _, ok := visible[entry.channelId]; ok {
+			res = append(res, entry.evt)
+		}
+	}
+	return res, true
+}
@@ -0,0 +1,57 @@
+package ws
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestReplayLogSince(t *testing.T) {
+	instance := uuid.New()
+	seen, hidden := uuid.New(), uuid.New()
+	id := func(seq int) string { return fmt.Sprintf("%s.%d", instance, seq) }
+
+	tests := []struct {
+		name     string
+		appended int // frames appended, alternating between seen and hidden
+		lastId   string
+		want     []uint64
+		wantOk   bool
+	}{
+		{name: "nothing missed", appended: 4, lastId: id(4), want: nil, wantOk: true},
+		{name: "only visible channels", appended: 5, lastId: id(1), want: []uint64{3, 5}, wantOk: true},
+		{name: "resume from the oldest kept frame", appended: 10, lastId: id(6), want: []uint64{7, 9}, wantOk: true},
+		{name: "frames overwritten by the ring", appended: 10, lastId: id(5), wantOk: false},
+		{name: "seq from the future", appended: 2, lastId: id(3), wantOk: false},
+		{name: "other instance", appended: 2, lastId: uuid.NewString() + ".1", wantOk: false},
+		{name: "no seq", appended: 2, lastId: instance.String(), wantOk: false},
+		{name: "garbage seq", appended: 2, lastId: instance.String() + ".x", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newReplayLog(instance, 4)
+			for i := range tt.appended {
+				channelId := seen
+				if i%2 == 1 {
+					channelId = hidden
+				}
+				l.append(channelId, incomingMessageEvent, i)
+			}
+
+			got, ok := l.since(tt.lastId, uuid.UUIDs{seen})
+			if ok != tt.wantOk {
+				t.Fatalf("since(%q) ok = %t, want %t", tt.lastId, ok, tt.wantOk)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("since(%q) = %d frames, want %v", tt.lastId, len(got), tt.want)
+			}
+			for i, evt := range got {
+				if evt.Seq != tt.want[i] || evt.ID != id(int(tt.want[i])) {
+					t.Errorf("frame %d = %s, want seq %d", i, evt.ID, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package ws
+
+import (
+	"backend/internal/application/command"
+	"backend/internal/application/interfaces"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	resyncRequiredEvent = "resync_required"
+)
+
+type sseFrame struct {
+	id      string
+	seq     uint64
+	payload wsPayload
+}
+
+// sseClient is the server-sent events fallback for clients that can't open a
+// websocket. It only receives, client actions go through the REST api.
+type sseClient struct {
+	id     uuid.UUID
+	userId uuid.UUID
+	proto  protocol
+
+	writeChan chan sseFrame
+	done      chan struct{}
+	closeOnce sync.Once
+	isClose   atomic.Bool
+
+	unsub chan<- peer
+}
+
+func newSSEClient(id, userId uuid.UUID, proto protocol, writeQueue int, unsub chan<- peer) *sseClient {
+	return &sseClient{
+		id:        id,
+		userId:    userId,
+		proto:     proto,
+		writeChan: make(chan sseFrame, writeQueue),
+		done:      make(chan struct{}),
+		unsub:     unsub,
+	}
+}
+
+func (c *sseClient) connId() uuid.UUID { return c.id }
+func (c *sseClient) user() uuid.UUID   { return c.userId }
+func (c *sseClient) closed() bool      { return c.isClose.Load() }
+
+func (c *sseClient) Close() error {
+	c.closeOnce.Do(func() {
+		c.isClose.Store(true)
+		close(c.done)
+
+		select {
+		case c.unsub <- c:
+		default:
+			go func() { c.unsub <- c }()
+		}
+	})
+	return nil
+}
+
+// goAway ends the stream, EventSource reconnects on its own after Config.SSERetry
+func (c *sseClient) goAway() { c.Close() }
+
+func (c *sseClient) Write(eventType string, msg any) {
+	c.deliver(hubEvent{EventType: eventType, Payload: msg})
+}
+
+// deliver has the same overflow policy as the websocket client
+func (c *sseClient) deliver(evt hubEvent) {
+	if c.isClose.Load() {
+		return
+	}
+
+	select {
+	case <-c.done:
+	case c.writeChan <- sseFrame{evt.ID, evt.Seq, newWsPayload(c.proto.Version, evt.EventType, "", evt.Payload)}:
+	default:
+		stats.framesDropped.Add(1)
+		stats.slowDisconnects.Add(1)
+		slog.Warn("write queue full, disconnecting slow sse client", "conn_id", c.id.String(), "user_id", c.userId.String(), "eventType", evt.EventType)
+		c.Close()
+	}
+}
+
+// ServeSSE streams the same events as /ws as server-sent events. The access
+// token goes in the Authorization header, browsers' EventSource which can't
+// set headers passes a ticket from IssueSSETicket in `ticket` instead. A
+// reconnect with Last-Event-ID replays what was missed, or sends
+// resync_required if that's no longer possible.
+func ServeSSE(hub *Hub, authService interfaces.AuthService, w http.ResponseWriter, r *http.Request) {
+	proto, err := negotiateProtocol(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if proto.Encoding != ENCODING_JSON || proto.Compress != COMPRESS_NONE {
+		http.Error(w, "sse only supports json without compression", http.StatusBadRequest)
+		return
+	}
+
+	var userId uuid.UUID
+	if token := bearerToken(r); token != "" {
+		res, err := authService.Authenticate(r.Context(), command.AuthenticateCommand{AccessToken: token, RequireActive: true})
+		if err != nil || res.UserId == nil {
+			http.Error(w, "Authentication failed", http.StatusUnauthorized)
+			return
+		}
+		userId = *res.UserId
+	} else if ticket := r.URL.Query().Get("ticket"); ticket != "" {
+		res, err := authService.RedeemStreamTicket(r.Context(), command.RedeemStreamTicketCommand{Ticket: ticket})
+		if err != nil {
+			http.Error(w, "Authentication failed", http.StatusUnauthorized)
+			return
+		}
+		userId = res.UserId
+	} else {
+		http.Error(w, "Empty authorization", http.StatusUnauthorized)
+		return
+	}
+
+	connId := uuid.New()
+	if !hub.limiter.acquireIP(connId, hub.proxies.clientIP(r)) {
+		http.Error(w, "Too many connections", http.StatusTooManyRequests)
+		return
+	}
+	c := newSSEClient(connId, userId, proto, hub.cfg.WriteQueue, hub.unsubChan)
+	defer c.Close()
+
+	if !hub.limiter.acquireUser(connId, c.userId) {
+		http.Error(w, "Too many connections", http.StatusTooManyRequests)
+		return
+	}
+
+	chans, err := hub.subscribe(r.Context(), c)
+	if err != nil {
+		slog.Error("Fail to register to the ws hub", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	write := func(frame sseFrame) error {
+		data, err := json.Marshal(frame.payload)
+		if err != nil {
+			return err
+		}
+
+		rc.SetWriteDeadline(time.Now().Add(hub.cfg.WriteWait))
+		if frame.id != "" {
+			if _, err = fmt.Fprintf(w, "id: %s\n", frame.id); err != nil {
+				return err
+			}
+		}
+		if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", frame.payload.EventType, data); err != nil {
+			return err
+		}
+		stats.framesSent.Add(1)
+		return rc.Flush()
+	}
+
+	if _, err = fmt.Fprintf(w, "retry: %d\n\n", hub.cfg.SSERetry.Milliseconds()); err != nil {
+		return
+	}
+
+	// Frames already replayed may also be queued live, lastSeq skips them
+	var lastSeq uint64
+	if lastId := r.Header.Get("Last-Event-ID"); lastId != "" {
+		missed, ok := hub.replay.since(lastId, chans)
+		if !ok {
+			c.Write(resyncRequiredEvent, map[string]any{"lastEventId": lastId})
+		}
+		for _, evt := range missed {
+			if err = write(sseFrame{evt.ID, evt.Seq, newWsPayload(proto.Version, evt.EventType, "", evt.Payload)}); err != nil {
+				return
+			}
+			lastSeq = evt.Seq
+		}
+	}
+	c.Write(initializedEvent, initializedPayload{SubscribedFrom: time.Now(), Protocol: proto})
+
+	ticker := time.NewTicker(hub.cfg.PingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-c.done:
+			return
+		case <-ticker.C:
+			rc.SetWriteDeadline(time.Now().Add(hub.cfg.WriteWait))
+			if _, err = fmt.Fprint(w, ": ping\n\n"); err != nil || rc.Flush() != nil {
+				return
+			}
+		case frame := <-c.writeChan:
+			if frame.seq != 0 && frame.seq <= lastSeq {
+				continue
+			}
+			if err = write(frame); err != nil {
+				slog.Warn("cannot send sse frame", "conn_id", c.id.String(), "error", err)
+				return
+			}
+		}
+	}
+}
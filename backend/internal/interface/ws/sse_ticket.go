@@ -0,0 +1,49 @@
+package ws
+
+import (
+	"backend/internal/application/command"
+	"backend/internal/application/interfaces"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type sseTicketResponse struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func bearerToken(r *http.Request) string {
+	if parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2); len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
+		return parts[1]
+	}
+	return ""
+}
+
+// IssueSSETicket trades the access token of the Authorization header for a
+// single use ticket valid for Config.SSETicketTTL. Browsers' EventSource can't
+// set headers, it opens /sse?ticket=<ticket> instead of putting the access
+// token in the URL where proxies and access logs would keep it.
+func IssueSSETicket(hub *Hub, authService interfaces.AuthService, w http.ResponseWriter, r *http.Request) {
+	token := bearerToken(r)
+	if token == "" {
+		http.Error(w, "Empty authorization", http.StatusUnauthorized)
+		return
+	}
+	auth, err := authService.Authenticate(r.Context(), command.AuthenticateCommand{AccessToken: token, RequireActive: true})
+	if err != nil || auth.UserId == nil {
+		http.Error(w, "Authentication failed", http.StatusUnauthorized)
+		return
+	}
+
+	res, err := authService.IssueStreamTicket(r.Context(), command.IssueStreamTicketCommand{UserId: *auth.UserId, TTL: hub.cfg.SSETicketTTL})
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(sseTicketResponse{Ticket: res.Ticket, ExpiresAt: res.ExpiresAt})
+}
@@ -0,0 +1,78 @@
+package ws
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type Stats struct {
+	FramesSent      uint64
+	FramesDropped   uint64
+	SlowDisconnects uint64
+
+	Users                int
+	Connections          int
+	Channels             int
+	ChannelSubscriptions int // users subscribed, summed over every channel
+	Servers              int
+	ServerSubscriptions  int // users subscribed, summed over every server
+}
+
+type gatewayStats struct {
+	framesSent      atomic.Uint64
+	framesDropped   atomic.Uint64
+	slowDisconnects atomic.Uint64
+}
+
+var stats gatewayStats
+
+func (s *gatewayStats) snapshot() Stats {
+	return Stats{
+		FramesSent:      s.framesSent.Load(),
+		FramesDropped:   s.framesDropped.Load(),
+		SlowDisconnects: s.slowDisconnects.Load(),
+	}
+}
+
+var (
+	framesSentDesc      = statsDesc("frames_sent_total", "Frames written to clients.")
+	framesDroppedDesc   = statsDesc("frames_dropped_total", "Frames dropped because the queue of a slow client was full.")
+	slowDisconnectsDesc = statsDesc("slow_disconnects_total", "Clients disconnected for falling too far behind.")
+	usersDesc           = statsDesc("users", "Users with at least one connection.")
+	connectionsDesc     = statsDesc("connections", "Open WebSocket and SSE connections.")
+	subscribedUsersDesc = prometheus.NewDesc("noncord_ws_subscribed_users",
+		"Users subscribed to channels or servers, summed over every channel or server, by scope. A user counts once per topic however many connections it has.", []string{"scope"}, nil)
+	topicsDesc = prometheus.NewDesc("noncord_ws_topics",
+		"Channels or servers with at least one subscriber, by scope.", []string{"scope"}, nil)
+)
+
+func statsDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc("noncord_ws_"+name, help, nil, nil)
+}
+
+type statsCollector struct {
+	hub *Hub
+}
+
+// NewStatsCollector exposes the Stats of hub as metrics, read on every scrape.
+func NewStatsCollector(hub *Hub) prometheus.Collector {
+	return statsCollector{hub}
+}
+
+func (c statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c statsCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.hub.Stats()
+	ch <- prometheus.MustNewConstMetric(framesSentDesc, prometheus.CounterValue, float64(s.FramesSent))
+	ch <- prometheus.MustNewConstMetric(framesDroppedDesc, prometheus.CounterValue, float64(s.FramesDropped))
+	ch <- prometheus.MustNewConstMetric(slowDisconnectsDesc, prometheus.CounterValue, float64(s.SlowDisconnects))
+	ch <- prometheus.MustNewConstMetric(usersDesc, prometheus.GaugeValue, float64(s.Users))
+	ch <- prometheus.MustNewConstMetric(connectionsDesc, prometheus.GaugeValue, float64(s.Connections))
+	ch <- prometheus.MustNewConstMetric(subscribedUsersDesc, prometheus.GaugeValue, float64(s.ChannelSubscriptions), "channel")
+	ch <- prometheus.MustNewConstMetric(subscribedUsersDesc, prometheus.GaugeValue, float64(s.ServerSubscriptions), "server")
+	ch <- prometheus.MustNewConstMetric(topicsDesc, prometheus.GaugeValue, float64(s.Channels), "channel")
+	ch <- prometheus.MustNewConstMetric(topicsDesc, prometheus.GaugeValue, float64(s.Servers), "server")
+}
@@ -0,0 +1,34 @@
+package ws
+
+import "time"
+
+type initializedPayload struct {
+	SubscribedFrom time.Time `json:"subscribedFrom"`
+	Protocol       protocol  `json:"protocol"`
+}
+
+type downgrade func(payload any) any
+
+// downgrades is keyed by the protocol version that changed an event's payload.
+// A client on an older version gets the payload passed through every
+// downgrade above its version, newest first, so it keeps the old shape.
+var downgrades = map[int32]map[string]downgrade{
+	2: {
+		initializedEvent: func(payload any) any {
+			p, ok := payload.(initializedPayload)
+			if !ok {
+				return payload
+			}
+			return map[string]any{"subscribedFrom": p.SubscribedFrom}
+		},
+	},
+}
+
+func adaptPayload(version int32, eventType string, payload any) any {
+	for v := int32(WS_MAX_VERSION); v > version; v-- {
+		if d, ok := downgrades[v][eventType]; ok {
+			payload = d(payload)
+		}
+	}
+	return payload
+}
@@ -3,80 +3,308 @@ package relayer
 import (
 	"backend/internal/application/ports"
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
-	"strconv"
+	"math/rand/v2"
+	"slices"
+	"sync/atomic"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("backend/internal/processes/relayer")
+
+var (
+	published = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "noncord",
+		Subsystem: "relayer",
+		Name:      "published_total",
+		Help:      "Outbox events published to the event bus.",
+	}, []string{"event_type"})
+
+	publishFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "noncord",
+		Subsystem: "relayer",
+		Name:      "publish_failures_total",
+		Help:      "Failed publishes of outbox events, by whether the event is retried or dead lettered.",
+	}, []string{"event_type", "outcome"})
 )
 
 type Config struct {
-	BatchSize    int32
-	StaleAfter   time.Duration // lease duration, e.g. 60 * time.Second
-	MaxAttempts  int32         // e.g. 8
-	PollInterval time.Duration // e.g. 100 * time.Millisecond
-	ShardCount   int32
+	BatchSize    int32         `yaml:"batch_size" env:"RELAYER_BATCH_SIZE"`
+	StaleAfter   time.Duration `yaml:"stale_after" env:"RELAYER_STALE_AFTER"`     // lease duration, e.g. 60 * time.Second
+	MaxAttempts  int32         `yaml:"max_attempts" env:"RELAYER_MAX_ATTEMPTS"`   // e.g. 8, the record is dead lettered after that
+	PollInterval time.Duration `yaml:"poll_interval" env:"RELAYER_POLL_INTERVAL"` // e.g. 100 * time.Millisecond, or seconds with Listen
+	BackoffBase  time.Duration `yaml:"backoff_base" env:"RELAYER_BACKOFF_BASE"`   // e.g. time.Second, delay before the first retry
+	BackoffMax   time.Duration `yaml:"backoff_max" env:"RELAYER_BACKOFF_MAX"`     // e.g. 5 * time.Minute
+
+	// ShardCount splits the outbox by aggregate so several relayers can run
+	// side by side, 0 relays the whole outbox from this instance.
+	ShardCount    int32         `yaml:"shard_count" env:"RELAYER_SHARD_COUNT" validate:"nonnegative"`
+	LeaseDuration time.Duration `yaml:"lease_duration" env:"RELAYER_LEASE_DURATION"` // e.g. 15 * time.Second, renewed every third of it
+	InstanceId    string        `yaml:"-"`                                           // lease owner, unique per running relayer
+
+	// Listen wakes the relayer as soon as events are committed, PollInterval
+	// then only matters as a safety net and can be seconds long. When the
+	// listen connection drops the relayer polls until it is back.
+	Listen      bool          `yaml:"listen" env:"RELAYER_LISTEN"`
+	ListenRetry time.Duration `yaml:"listen_retry" env:"RELAYER_LISTEN_RETRY"` // e.g. 5 * time.Second between reconnects
+}
+
+var DefaultConfig = Config{
+	BatchSize:     100,
+	StaleAfter:    time.Minute,
+	MaxAttempts:   8,
+	PollInterval:  2 * time.Second,
+	BackoffBase:   time.Second,
+	BackoffMax:    5 * time.Minute,
+	ShardCount:    16,
+	LeaseDuration: 15 * time.Second,
+	Listen:        true,
+	ListenRetry:   5 * time.Second,
 }
 
 type Relayer struct {
-	reader ports.OutboxReader
-	broker ports.EventPublisher
-	cfg    Config
+	reader   ports.OutboxReader
+	broker   ports.EventPublisher
+	leaser   ports.ShardLeaser
+	listener ports.OutboxListener
+	cfg      Config
+
+	shards    []int32
+	lastClaim atomic.Int64 // unix nanoseconds, 0 until the first claim
+}
+
+func New(reader ports.OutboxReader, broker ports.EventPublisher, leaser ports.ShardLeaser, listener ports.OutboxListener, config Config) *Relayer {
+	return &Relayer{reader: reader, broker: broker, leaser: leaser, listener: listener, cfg: config}
+}
+
+// listen returns the wake up channel, or nil and a timer for the next try
+// when the listen connection cannot be opened.
+func (r *Relayer) listen(ctx context.Context) (<-chan struct{}, <-chan time.Time) {
+	wakeCh, err := r.listener.Listen(ctx)
+	if err != nil {
+		slog.Default().Error("cannot listen for outbox events, polling only", "err", err)
+		return nil, time.After(r.cfg.ListenRetry)
+	}
+	return wakeCh, nil
 }
 
-func New(reader ports.OutboxReader, broker ports.EventPublisher, config Config) *Relayer {
-	return &Relayer{reader, broker, config}
+func (r *Relayer) sharded() bool {
+	return r.cfg.ShardCount > 0
+}
+
+func (r *Relayer) refreshLeases(ctx context.Context) {
+	shards, err := r.leaser.Acquire(ctx, r.cfg.InstanceId, r.cfg.ShardCount, r.cfg.LeaseDuration)
+	if err != nil {
+		// Leases may have expired under us, stop relaying until they are back
+		slog.Default().Error("cannot refresh shard leases", "err", err)
+		r.shards = nil
+		return
+	}
+	if !slices.Equal(shards, r.shards) {
+		slog.Default().Info("Shard leases changed", "shards", shards)
+	}
+	r.shards = shards
+	if len(shards) == 0 {
+		// Other instances relay every shard, this one has nothing to claim
+		r.claimed()
+	}
+}
+
+func (r *Relayer) claimed() {
+	r.lastClaim.Store(time.Now().UnixNano())
+}
+
+// LastClaim is when the relayer last claimed a batch from the outbox, even an
+// empty one, or the zero time if it never did. A relayer holding no shard
+// counts as claiming whenever it renews its leases.
+func (r *Relayer) LastClaim() time.Time {
+	if at := r.lastClaim.Load(); at != 0 {
+		return time.Unix(0, at)
+	}
+	return time.Time{}
+}
+
+func (r *Relayer) releaseLeases(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := r.leaser.Release(ctx, r.cfg.InstanceId); err != nil {
+		slog.Default().Error("cannot release shard leases", "err", err)
+	}
 }
 
 func (r *Relayer) step(ctx context.Context) (int32, error) {
-	records, err := r.reader.ClaimBatch(ctx, r.cfg.BatchSize, r.cfg.StaleAfter)
+	if r.sharded() && len(r.shards) == 0 {
+		return 0, nil
+	}
+
+	ctx, span := tracer.Start(ctx, "Relayer.step")
+	delivered, err := r.relay(ctx)
+	span.SetAttributes(attribute.Int("outbox.delivered", int(delivered)))
+	endSpan(span, err)
+	return delivered, err
+}
+
+func (r *Relayer) relay(ctx context.Context) (int32, error) {
+	records, err := r.reader.ClaimBatch(ctx, r.cfg.BatchSize, r.cfg.StaleAfter, r.cfg.ShardCount, r.shards)
 	if err != nil {
 		return 0, err
 	}
+	r.claimed()
 
 	if len(records) == 0 {
 		return 0, nil
 	}
 
+	msgs := make([]ports.EventMessage, len(records))
+	spans := make([]trace.Span, len(records))
 	for i, rec := range records {
-		header := map[string]any{
-			"event_type":     rec.EventType,
-			"aggregate_name": rec.AggregateName,
-			"schema_version": strconv.Itoa(int(rec.SchemaVersion)),
-			"occurred_at":    rec.OccurredAt.UTC().Format(time.RFC3339Nano),
-			"event_id":       rec.ID.String(),
-		}
+		msgs[i] = rec.Message()
+		spans[i] = startPublish(ctx, rec, msgs[i])
+	}
+	publishErrs := r.broker.PublishBatch(ctx, msgs)
+	for i, span := range spans {
+		endSpan(span, publishErrs[i])
+	}
 
-		err = r.broker.Publish(ctx, ports.EventMessage{
-			AggregateId: rec.AggregateID,
-			EventType:   rec.EventType,
-			Payload:     rec.Payload,
-			Headers:     header,
-		})
+	// Records belong to distinct aggregates, a failed publish only holds back
+	// its own aggregate until the record is retried
+	var delivered int32
+	var errs []error
+	for i, rec := range records {
+		err := publishErrs[i]
+		if errors.Is(err, ports.ErrUnroutable) {
+			// Nobody subscribes to it, publishing again would not change that
+			slog.Default().Warn("outbox event has no subscriber", "event_id", rec.ID, "event_type", rec.EventType)
+			err = nil
+		}
 		if err != nil {
-			if rec.Attempts >= r.cfg.MaxAttempts {
-				r.reader.MarkFailed(ctx, rec.ID)
-			}
-			return int32(i), err
+			r.retryLater(ctx, rec, err)
+		} else {
+			published.WithLabelValues(rec.EventType).Inc()
+			err = r.reader.MarkDispatched(ctx, rec.ID)
 		}
-
-		err = r.reader.MarkDispatched(ctx, rec.ID)
 		if err != nil {
-			return int32(i), err
+			errs = append(errs, fmt.Errorf("event %s: %w", rec.ID, err))
+			continue
 		}
+		delivered++
 	}
 
-	return int32(len(records)), nil
+	return delivered, errors.Join(errs...)
+}
+
+// startPublish starts the producer span of rec as a child of the trace that
+// wrote it, linked to the step, and puts its context in the headers of msg.
+func startPublish(ctx context.Context, rec ports.OutboxRecord, msg ports.EventMessage) trace.Span {
+	link := trace.LinkFromContext(ctx)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(rec.TraceContext))
+	ctx, span := tracer.Start(ctx, "publish "+rec.EventType,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithLinks(link),
+		trace.WithAttributes(
+			attribute.String("messaging.destination.name", rec.EventType),
+			attribute.String("messaging.message.id", rec.ID.String()),
+		),
+	)
+	otel.GetTextMapPropagator().Inject(ctx, ports.HeaderCarrier(msg.Headers))
+	return span
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (r *Relayer) retryLater(ctx context.Context, rec ports.OutboxRecord, cause error) {
+	var err error
+	if rec.Attempts >= r.cfg.MaxAttempts {
+		// A dead lettered record no longer blocks the later events of its aggregate
+		slog.Default().Warn("outbox event dead lettered", "event_id", rec.ID, "attempts", rec.Attempts, "err", cause)
+		publishFailures.WithLabelValues(rec.EventType, "dead_lettered").Inc()
+		err = r.reader.DeadLetter(ctx, rec.ID, cause.Error())
+	} else {
+		publishFailures.WithLabelValues(rec.EventType, "retried").Inc()
+		err = r.reader.Retry(ctx, rec.ID, r.backoff(rec.Attempts), cause.Error())
+	}
+	if err != nil {
+		// The record stays inflight and is reclaimed once StaleAfter passes
+		slog.Default().Error("cannot reschedule outbox event", "event_id", rec.ID, "err", err)
+	}
+}
+
+// backoff doubles the delay on every attempt up to BackoffMax, with jitter so
+// records that failed together do not retry together.
+func (r *Relayer) backoff(attempts int32) time.Duration {
+	// Compare before shifting, BackoffBase<<shift can overflow into a
+	// negative delay that rand.N panics on
+	delay := r.cfg.BackoffMax
+	if shift := max(attempts-1, 0); shift < 32 && r.cfg.BackoffBase <= r.cfg.BackoffMax>>shift {
+		delay = r.cfg.BackoffBase << shift
+	}
+	return delay/2 + rand.N(delay/2+1)
 }
 
 func (r *Relayer) Run(ctx context.Context) error {
 	defer r.broker.Close(ctx)
 	tickerCh := time.Tick(r.cfg.PollInterval)
 
+	// A batch holds one event per aggregate, keep stepping without waiting
+	// for the ticker while there is a backlog behind those heads
+	immediate := make(chan time.Time)
+	close(immediate)
+	nextCh := tickerCh
+
+	var leaseCh <-chan time.Time
+	if r.sharded() {
+		defer r.releaseLeases(ctx)
+		r.refreshLeases(ctx)
+		leaseCh = time.Tick(r.cfg.LeaseDuration / 3)
+	}
+
+	var wakeCh <-chan struct{}
+	var relistenCh <-chan time.Time
+	if r.cfg.Listen {
+		wakeCh, relistenCh = r.listen(ctx)
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-tickerCh:
+		case _, ok := <-wakeCh:
+			if !ok {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				wakeCh, relistenCh = nil, time.After(r.cfg.ListenRetry)
+				continue
+			}
+			nextCh = immediate
+		case <-relistenCh:
+			wakeCh, relistenCh = r.listen(ctx)
+			// Events committed while nobody listened are only seen by a step
+			nextCh = immediate
+		case <-leaseCh:
+			r.refreshLeases(ctx)
+		case <-nextCh:
 			count, err := r.step(ctx)
+			nextCh = tickerCh
+			if count > 0 {
+				nextCh = immediate
+			}
 			if err != nil {
 				slog.Default().Error("relayer step failed", "err", err, "count", count)
 			} else if count > 0 {
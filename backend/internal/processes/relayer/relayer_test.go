@@ -0,0 +1,38 @@
+package relayer
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name      string
+		base, max time.Duration
+		attempts  int32
+		want      time.Duration // delay before jitter, the result is within [want/2, want]
+	}{
+		{name: "no attempt yet", base: time.Second, max: 5 * time.Minute, attempts: 0, want: time.Second},
+		{name: "first retry", base: time.Second, max: 5 * time.Minute, attempts: 1, want: time.Second},
+		{name: "doubles", base: time.Second, max: 5 * time.Minute, attempts: 4, want: 8 * time.Second},
+		{name: "capped", base: time.Second, max: 5 * time.Minute, attempts: 10, want: 5 * time.Minute},
+		{name: "last shift", base: time.Nanosecond, max: math.MaxInt64, attempts: 32, want: 1 << 31},
+		{name: "shift past 31", base: time.Nanosecond, max: math.MaxInt64, attempts: 33, want: math.MaxInt64},
+		{name: "shift would overflow", base: time.Hour, max: math.MaxInt64, attempts: 31, want: math.MaxInt64},
+		{name: "max attempts", base: time.Second, max: 5 * time.Minute, attempts: math.MaxInt32, want: 5 * time.Minute},
+		{name: "base equals max", base: time.Minute, max: time.Minute, attempts: 3, want: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(nil, nil, nil, nil, Config{BackoffBase: tt.base, BackoffMax: tt.max})
+			for range 100 {
+				got := r.backoff(tt.attempts)
+				if got < tt.want/2 || got > tt.want {
+					t.Fatalf("backoff(%d) = %s, want within [%s, %s]", tt.attempts, got, tt.want/2, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package replay
+
+import (
+	"backend/internal/application/ports"
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type Config struct {
+	BatchSize  int32
+	FromOutbox bool // replay the events still in the outbox after the archived ones
+}
+
+// Replayer feeds history to a target, archived events first and then those
+// retained in the outbox. An event in both is fed once, an event archived
+// twice, imported and then purged again, once per archive file holding it.
+type Replayer struct {
+	reader  ports.OutboxReader
+	archive ports.EventArchive // nil replays the outbox only
+	cfg     Config
+}
+
+func New(reader ports.OutboxReader, archive ports.EventArchive, config Config) *Replayer {
+	return &Replayer{reader: reader, archive: archive, cfg: config}
+}
+
+// Message is rec as a subscriber gets it, marked as a replay. run keys the
+// inbox records of the replay apart from the live delivery, uuid.Nil keeps
+// the live key so an inbox skips what it already processed.
+func Message(rec ports.OutboxRecord, run uuid.UUID) ports.EventMessage {
+	msg := rec.Message()
+	msg.Headers[ports.HEADER_REPLAY] = "true"
+	if run != uuid.Nil {
+		msg.Headers[ports.HEADER_REPLAY_RUN] = run.String()
+	}
+	return msg
+}
+
+// Replay calls deliver with every event matching filter in the order they
+// were written, and stops at the first error. It returns how many events it
+// delivered.
+func (r *Replayer) Replay(ctx context.Context, filter ports.EventFilter, deliver func(context.Context, ports.OutboxRecord) error) (int, error) {
+	count := 0
+	replayOne := func(rec ports.OutboxRecord) error {
+		if err := deliver(ctx, rec); err != nil {
+			return fmt.Errorf("replay event %s: %w", rec.ID, err)
+		}
+		count++
+		return nil
+	}
+
+	// An event imported back from an archive is in both, it is delivered from
+	// the archive and its outbox copy skipped. The archive is checked against
+	// the outbox one batch at a time, so only the ids of imported events still
+	// in the outbox are kept rather than every archived one.
+	imported := map[uuid.UUID]struct{}{}
+	if r.archive != nil {
+		files, err := r.archive.Files(ctx, filter.Since, filter.Until)
+		if err != nil {
+			return count, err
+		}
+
+		batch := make([]ports.OutboxRecord, 0, r.cfg.BatchSize)
+		flush := func() error {
+			if r.cfg.FromOutbox && len(batch) > 0 {
+				ids := make([]uuid.UUID, len(batch))
+				for i, rec := range batch {
+					ids[i] = rec.ID
+				}
+				retained, err := r.reader.Retained(ctx, ids)
+				if err != nil {
+					return err
+				}
+				for _, id := range retained {
+					imported[id] = struct{}{}
+				}
+			}
+			for _, rec := range batch {
+				if err := replayOne(rec); err != nil {
+					return err
+				}
+			}
+			batch = batch[:0]
+			return nil
+		}
+
+		for _, path := range files {
+			err = r.archive.Read(ctx, path, func(rec ports.OutboxRecord) error {
+				if !filter.Match(rec) {
+					return nil
+				}
+				if batch = append(batch, rec); len(batch) < cap(batch) {
+					return nil
+				}
+				return flush()
+			})
+			if err != nil {
+				return count, err
+			}
+		}
+		if err = flush(); err != nil {
+			return count, err
+		}
+	}
+
+	if !r.cfg.FromOutbox {
+		return count, nil
+	}
+	var afterSeq int64
+	for {
+		batch, err := r.reader.List(ctx, filter, afterSeq, r.cfg.BatchSize)
+		if err != nil {
+			return count, err
+		}
+		for _, rec := range batch {
+			if _, ok := imported[rec.ID]; ok {
+				delete(imported, rec.ID)
+				continue
+			}
+			if err = replayOne(rec); err != nil {
+				return count, err
+			}
+		}
+		if len(batch) < int(r.cfg.BatchSize) {
+			return count, nil
+		}
+		afterSeq = batch[len(batch)-1].Seq
+	}
+}
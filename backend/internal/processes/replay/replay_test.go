@@ -0,0 +1,153 @@
+package replay
+
+import (
+	"backend/internal/application/ports"
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memOutbox struct {
+	ports.OutboxReader
+	records []ports.OutboxRecord
+	// largest id batch looked up with Retained
+	lookups, maxLookup int
+}
+
+func (o *memOutbox) List(ctx context.Context, filter ports.EventFilter, afterSeq int64, limit int32) ([]ports.OutboxRecord, error) {
+	var batch []ports.OutboxRecord
+	for _, rec := range o.records {
+		if rec.Seq > afterSeq && filter.Match(rec) && len(batch) < int(limit) {
+			batch = append(batch, rec)
+		}
+	}
+	return batch, nil
+}
+
+func (o *memOutbox) Retained(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error) {
+	o.lookups++
+	o.maxLookup = max(o.maxLookup, len(ids))
+	var retained []uuid.UUID
+	for _, rec := range o.records {
+		if slices.Contains(ids, rec.ID) {
+			retained = append(retained, rec.ID)
+		}
+	}
+	return retained, nil
+}
+
+// memArchive holds one file per slice of records
+type memArchive struct {
+	ports.EventArchive
+	files [][]ports.OutboxRecord
+}
+
+func (a *memArchive) Files(ctx context.Context, since, until time.Time) ([]string, error) {
+	paths := make([]string, len(a.files))
+	for i := range a.files {
+		paths[i] = string(rune('a' + i))
+	}
+	return paths, nil
+}
+
+func (a *memArchive) Read(ctx context.Context, path string, fn func(ports.OutboxRecord) error) error {
+	for _, rec := range a.files[path[0]-'a'] {
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestReplay(t *testing.T) {
+	aggregate := uuid.New()
+	event := func(seq int64, eventType string) ports.OutboxRecord {
+		return ports.OutboxRecord{ID: uuid.New(), Seq: seq, AggregateID: aggregate, EventType: eventType, OccurredAt: time.Unix(seq, 0)}
+	}
+	archived := []ports.OutboxRecord{event(1, "a"), event(2, "b"), event(3, "a"), event(4, "b"), event(5, "a")}
+	retained := []ports.OutboxRecord{event(6, "a"), event(7, "b"), event(8, "a")}
+	// imported back into the outbox with a later seq
+	reimported := archived[1]
+	reimported.Seq = 9
+
+	tests := []struct {
+		name       string
+		files      [][]ports.OutboxRecord
+		outbox     []ports.OutboxRecord
+		fromOutbox bool
+		filter     ports.EventFilter
+		want       []ports.OutboxRecord
+	}{
+		{
+			name:       "archive then outbox",
+			files:      [][]ports.OutboxRecord{archived[:3], archived[3:]},
+			outbox:     retained,
+			fromOutbox: true,
+			want:       append(slices.Clone(archived), retained...),
+		},
+		{
+			name:   "archive only",
+			files:  [][]ports.OutboxRecord{archived},
+			outbox: retained,
+			want:   archived,
+		},
+		{
+			name:       "outbox only",
+			outbox:     retained,
+			fromOutbox: true,
+			want:       retained,
+		},
+		{
+			name:       "imported event is replayed once from the archive",
+			files:      [][]ports.OutboxRecord{archived},
+			outbox:     append(slices.Clone(retained), reimported),
+			fromOutbox: true,
+			want:       append(slices.Clone(archived), retained...),
+		},
+		{
+			name:       "filter applies to both",
+			files:      [][]ports.OutboxRecord{archived},
+			outbox:     append(slices.Clone(retained), reimported),
+			fromOutbox: true,
+			filter:     ports.EventFilter{EventTypes: []string{"b"}},
+			want:       []ports.OutboxRecord{archived[1], archived[3], retained[1]},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outbox := &memOutbox{records: tt.outbox}
+			var archive ports.EventArchive
+			if tt.files != nil {
+				archive = &memArchive{files: tt.files}
+			}
+			r := New(outbox, archive, Config{BatchSize: 2, FromOutbox: tt.fromOutbox})
+
+			var got []ports.OutboxRecord
+			count, err := r.Replay(context.Background(), tt.filter, func(ctx context.Context, rec ports.OutboxRecord) error {
+				got = append(got, rec)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != len(tt.want) || len(got) != len(tt.want) {
+				t.Fatalf("replayed %d (count %d), want %d", len(got), count, len(tt.want))
+			}
+			for i := range got {
+				if got[i].ID != tt.want[i].ID {
+					t.Errorf("event %d is seq %d, want seq %d", i, got[i].Seq, tt.want[i].Seq)
+				}
+			}
+			if outbox.maxLookup > 2 {
+				t.Errorf("looked up %d ids at once, want at most the batch size", outbox.maxLookup)
+			}
+			if !tt.fromOutbox && outbox.lookups > 0 {
+				t.Errorf("looked up the outbox %d times without replaying it", outbox.lookups)
+			}
+		})
+	}
+}
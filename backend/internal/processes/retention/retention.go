@@ -0,0 +1,107 @@
+package retention
+
+import (
+	"backend/internal/application/ports"
+	"context"
+	"log/slog"
+	"time"
+)
+
+type Config struct {
+	RetainFor time.Duration `yaml:"retain_for" env:"OUTBOX_RETENTION" validate:"nonnegative"` // e.g. 7 * 24 * time.Hour, delivered events older than that are purged, 0 keeps them
+	BatchSize int32         `yaml:"batch_size" env:"OUTBOX_RETENTION_BATCH_SIZE"`             // records deleted per transaction
+	Interval  time.Duration `yaml:"interval" env:"OUTBOX_RETENTION_INTERVAL"`                 // e.g. time.Hour between two purges
+}
+
+// DefaultConfig keeps every event, purging is opt-in since what it deletes
+// without an archive can no longer be replayed.
+var DefaultConfig = Config{
+	RetainFor: 0,
+	BatchSize: 1000,
+	Interval:  time.Hour,
+}
+
+// Retention keeps the outbox small by purging delivered events, archiving
+// them first when it has an archive.
+type Retention struct {
+	store   ports.OutboxRetention
+	archive ports.EventArchive // nil purges without archiving
+	cfg     Config
+}
+
+func New(store ports.OutboxRetention, archive ports.EventArchive, config Config) *Retention {
+	return &Retention{store: store, archive: archive, cfg: config}
+}
+
+// Purge deletes every delivered event older than RetainFor, one batch per
+// transaction, and returns how many it deleted.
+func (r *Retention) Purge(ctx context.Context) (int, error) {
+	var archive func([]ports.OutboxRecord) error
+	if r.archive != nil {
+		archive = func(records []ports.OutboxRecord) error {
+			return r.archive.Write(ctx, records)
+		}
+	}
+
+	cutoff := time.Now().Add(-r.cfg.RetainFor)
+	total := 0
+	for {
+		count, err := r.store.Purge(ctx, cutoff, r.cfg.BatchSize, archive)
+		total += count
+		if err != nil || count < int(r.cfg.BatchSize) {
+			return total, err
+		}
+	}
+}
+
+// Run purges every Interval until ctx is done.
+func (r *Retention) Run(ctx context.Context) error {
+	if r.archive == nil {
+		slog.Default().Warn("Purging the outbox without an archive, purged events can no longer be replayed", "retain_for", r.cfg.RetainFor)
+	}
+
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		count, err := r.Purge(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Default().Error("cannot purge the outbox", "err", err)
+		} else if count > 0 {
+			slog.Default().Info("Purged the outbox", "events", count, "archived", r.archive != nil)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Import puts the events of an archive file back into the outbox, skipping
+// those it still holds. With redeliver they are delivered to subscribers
+// again, otherwise they only restore the history.
+func (r *Retention) Import(ctx context.Context, path string, redeliver bool) (imported, skipped int, err error) {
+	err = r.archive.Read(ctx, path, func(rec ports.OutboxRecord) error {
+		if redeliver {
+			rec.Status, rec.PublishedAt = "pending", nil
+		} else {
+			rec.Status = "dispatched"
+			if rec.PublishedAt == nil {
+				rec.PublishedAt = &rec.OccurredAt
+			}
+		}
+
+		inserted, err := r.store.Import(ctx, rec)
+		if err != nil {
+			return err
+		}
+		if inserted {
+			imported++
+		} else {
+			skipped++
+		}
+		return nil
+	})
+	return imported, skipped, err
+}